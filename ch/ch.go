@@ -41,6 +41,10 @@ const (
 	Tdir          // map[string]string, directory entry
 	Tzx           // zx protocol msg
 	Tusr          // first user defined type value
+
+	// Types defined after Tusr, kept at the end of the type space,
+	// so that they don't move Tusr nor collide with user types.
+	Trec uint16 = 0xFF00 // record, ordered named fields
 )
 
 const (
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Applets are clive commands linked into a program, which may
+// run them within its own unix process, each one on its own context,
+// instead of starting new unix processes for them.
+// They start faster and their IO chans carry messages directly.
+//
+// The main function of an applet must keep all its state in its
+// own stack or data, and must not call os.Exit, because
+// the applet may run more than once, perhaps concurrently.
+// It should terminate by returning or calling Exit or Fatal, as usual.
+var (
+	applets  = map[string]func(){}
+	appletlk sync.Mutex
+
+	ErrNoApplet = errors.New("no such applet")
+)
+
+// Define an applet with the given command name(s) and main function.
+func DefApplet(main func(), names ...string) {
+	appletlk.Lock()
+	defer appletlk.Unlock()
+	for _, n := range names {
+		applets[n] = main
+	}
+}
+
+// Return the main function for the named applet, or nil.
+func Applet(name string) func() {
+	appletlk.Lock()
+	defer appletlk.Unlock()
+	return applets[name]
+}
+
+// Return the sorted list of applet names.
+func Applets() []string {
+	appletlk.Lock()
+	defer appletlk.Unlock()
+	ns := []string{}
+	for n := range applets {
+		ns = append(ns, n)
+	}
+	sort.Strings(ns)
+	return ns
+}
+
+// Run the applet named by args[0] with the given args in a new context
+// and return the context (see Ctx.Waitc to wait for it).
+// The new context has its own name space, dot, and environment,
+// initialized from the current ones, and a dup of the IO chans.
+// If adjust is not nil, it is called before the applet runs, to
+// adjust the new context (eg., its IO chans).
+// A panic in the applet terminates just the applet, with an error status.
+func RunApplet(adjust func(*Ctx), args ...string) (*Ctx, error) {
+	if len(args) == 0 {
+		return nil, errors.New("no applet name")
+	}
+	fn := Applet(args[0])
+	if fn == nil {
+		return nil, fmt.Errorf("%s: %s", args[0], ErrNoApplet)
+	}
+	wc := make(chan bool)
+	c := New(func() {
+		ForkEnv()
+		ForkNS()
+		ForkDot()
+		defer func() {
+			if r := recover(); r != nil {
+				if s, ok := r.(string); ok && strings.HasPrefix(s, "appexit") {
+					panic(r)
+				}
+				Warn("panic: %v", r)
+				appexit(fmt.Sprintf("panic: %v", r))
+			}
+		}()
+		fn()
+	}, wc)
+	c.lk.Lock()
+	c.Args = append([]string{}, args...)
+	c.lk.Unlock()
+	if adjust != nil {
+		adjust(c)
+	}
+	close(wc)
+	return c, nil
+}
+
+// Interrupt the applet running on c, by closing the IO chans set for it
+// from unix files (see SetUXIn and SetUXOut) with ErrIntr.
+// Applets blocked doing IO through them get the error and
+// are expected to terminate.
+func (c *Ctx) Intr() {
+	c.lk.Lock()
+	io := c.io
+	c.lk.Unlock()
+	io.intr()
+}
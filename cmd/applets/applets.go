@@ -0,0 +1,10 @@
+/*
+	Clive commands that may run as applets (see cmd.DefApplet).
+
+	Each command here is also a unix command, built from
+	its own directory in clive/cmd, whose main calls the function
+	exported here for it.
+	Programs importing this package may run them in-process,
+	like ql does.
+*/
+package applets
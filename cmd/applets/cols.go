@@ -0,0 +1,170 @@
+package applets
+
+import (
+	"bytes"
+	"clive/cmd"
+	"clive/cmd/opt"
+	"clive/cmd/tty"
+	"clive/zx"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// cols state
+struct cols {
+	opts       *opt.Flags
+	wid, ncols int
+	words      []string
+	maxwid     int
+	ux, jflag  bool
+	recs       []cmd.Rec
+}
+
+func init() {
+	cmd.DefApplet(Cols, "cols")
+}
+
+func (x *cols) col() {
+	if x.wid == 0 {
+		x.wid, _ = tty.Cols()
+		if x.wid == 0 {
+			x.wid = 70
+		}
+	}
+	colwid := x.maxwid + 2
+	if x.ncols == 0 {
+		x.ncols = x.wid / colwid
+	}
+	if x.ncols == 0 {
+		x.ncols = 1
+	}
+	var buf bytes.Buffer
+	for i, w := range x.words {
+		nw := utf8.RuneCountInString(w)
+		spcs := ""
+		if nw < colwid-1 {
+			spcs = strings.Repeat(" ", colwid-1-nw)
+		}
+		fmt.Fprintf(&buf, "%s%s", w, spcs)
+		if (i+1)%x.ncols == 0 {
+			fmt.Fprintf(&buf, "\n")
+		}
+	}
+	if len(x.words)%x.ncols != 0 {
+		fmt.Fprintf(&buf, "\n")
+	}
+	cmd.Out("out") <- buf.Bytes()
+}
+
+// Print the records as a table with names from the first one.
+func (x *cols) table() {
+	names := x.recs[0].Names()
+	wids := make([]int, len(names))
+	row := func(r cmd.Rec) []string {
+		vs := make([]string, len(names))
+		for i, n := range names {
+			vs[i], _ = r.Get(n)
+		}
+		return vs
+	}
+	rows := [][]string{names}
+	for _, r := range x.recs {
+		rows = append(rows, row(r))
+	}
+	for _, vs := range rows {
+		for i, v := range vs {
+			if n := utf8.RuneCountInString(v); n > wids[i] {
+				wids[i] = n
+			}
+		}
+	}
+	var buf bytes.Buffer
+	for _, vs := range rows {
+		for i, v := range vs {
+			if i == len(vs)-1 {
+				fmt.Fprintf(&buf, "%s\n", v)
+				break
+			}
+			spcs := strings.Repeat(" ", wids[i]-utf8.RuneCountInString(v)+2)
+			fmt.Fprintf(&buf, "%s%s", v, spcs)
+		}
+	}
+	cmd.Out("out") <- buf.Bytes()
+}
+
+func (x *cols) jsonRecs() {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "[\n")
+	for i, r := range x.recs {
+		sep := ","
+		if i == len(x.recs)-1 {
+			sep = ""
+		}
+		fmt.Fprintf(&buf, "\t%s%s\n", r.JSON(), sep)
+	}
+	fmt.Fprintf(&buf, "]\n")
+	cmd.Out("out") <- buf.Bytes()
+}
+
+func (x *cols) add(ws ...string) {
+	for _, w := range ws {
+		if len(w) > x.maxwid {
+			x.maxwid = len(w)
+		}
+	}
+	x.words = append(x.words, ws...)
+}
+
+// Run cols in the current app context.
+func Cols() {
+	x := &cols{opts: opt.New("{file}")}
+	c := cmd.AppCtx()
+	x.opts.NewFlag("D", "debug", &c.Debug)
+	x.opts.NewFlag("w", "wid: set max line width", &x.wid)
+	x.opts.NewFlag("n", "ncols: set number of columns", &x.ncols)
+	x.opts.NewFlag("j", "print records as JSON", &x.jflag)
+	x.opts.NewFlag("u", "use unix output", &x.ux)
+	cmd.UnixIO("err")
+	args := x.opts.Parse()
+	if x.ux {
+		cmd.UnixIO("out")
+	}
+	if len(args) != 0 {
+		cmd.SetIn("in", cmd.Files(args...))
+	}
+	in := cmd.In("in")
+	for m := range in {
+		switch m := m.(type) {
+		default:
+			// ignored & forwarded
+			cmd.Dprintf("got %T\n", m)
+			continue
+		case zx.Dir:
+			cmd.Dprintf("got %T %s\n", m, m["Upath"])
+			x.add(strings.TrimSpace(m["name"]))
+		case cmd.Rec:
+			x.recs = append(x.recs, m)
+		case error:
+			if m != nil {
+				cmd.Warn("%s", m)
+			}
+		case []byte:
+			cmd.Dprintf("got %T [%d]\n", m, len(m))
+			words := strings.Fields(strings.TrimSpace(string(m)))
+			x.add(words...)
+		}
+	}
+	switch {
+	case len(x.recs) > 0 && x.jflag:
+		x.jsonRecs()
+	case len(x.recs) > 0:
+		x.table()
+	}
+	if len(x.words) > 0 || len(x.recs) == 0 {
+		x.col()
+	}
+	if err := cerror(in); err != nil {
+		cmd.Fatal("in %s", err)
+	}
+}
@@ -0,0 +1,78 @@
+package applets
+
+import (
+	"bytes"
+	"clive/cmd"
+	"clive/cmd/opt"
+)
+
+// eco state
+struct eco {
+	nflag, mflag bool
+	ux           bool
+	oname        string
+	iname        string
+	opts         *opt.Flags
+}
+
+func init() {
+	cmd.DefApplet(Eco, "eco")
+}
+
+// Run echo in the current app context.
+func Eco() {
+	x := &eco{oname: "out", opts: opt.New("{arg}")}
+	cmd.UnixIO("err")
+	c := cmd.AppCtx()
+	opts := x.opts
+	opts.NewFlag("D", "debug", &c.Debug)
+	opts.NewFlag("n", "don't add a final newline", &x.nflag)
+	opts.NewFlag("m", "issue one message per arg", &x.mflag)
+	opts.NewFlag("u", "use unix out", &x.ux)
+	opts.NewFlag("o", "chan: output to this chan (for testing other tools)", &x.oname)
+	opts.NewFlag("i", "chan: echo input from this chan (for testing other tools)", &x.iname)
+	args := opts.Parse()
+	if x.ux {
+		cmd.UnixIO(x.oname)
+	}
+	var b bytes.Buffer
+	out := cmd.Out(x.oname)
+	if out == nil {
+		cmd.Fatal("no output chan '%s'", x.oname)
+	}
+	x.mflag = x.mflag || x.iname != ""
+	for i, arg := range args {
+		if x.mflag {
+			ok := out <- []byte(arg)
+			if !ok {
+				cmd.Fatal("out: %s", cerror(out))
+			}
+		} else {
+			b.WriteString(arg)
+			if i < len(args)-1 {
+				b.WriteString(" ")
+			}
+		}
+	}
+	if x.iname != "" {
+		for m := range cmd.In(x.iname) {
+			m := m
+			if b, ok := m.([]byte); ok {
+				ok := out <- []byte(b)
+				if !ok {
+					cmd.Fatal("out: %s", cerror(out))
+				}
+			}
+		}
+	}
+	if x.mflag {
+		cmd.Exit(nil)
+	}
+	if !x.nflag {
+		b.WriteString("\n")
+	}
+	ok := out <- b.Bytes()
+	if !ok {
+		cmd.Fatal("out: %s", cerror(out))
+	}
+}
@@ -0,0 +1,209 @@
+package applets
+
+import (
+	"bytes"
+	"clive/cmd"
+	"clive/cmd/opt"
+	"clive/zx"
+	"fmt"
+	"strings"
+)
+
+// flds state
+struct flds {
+	opts *opt.Flags
+
+	ranges []string
+	one    bool
+	seps   string
+	osep   string
+	addrs  []opt.Range
+	all    bool
+	ux     bool
+
+	rflag, hflag bool
+	nlist        string
+	names        []string // field names for records
+	fnames       []string // fields selected by name
+}
+
+func init() {
+	cmd.DefApplet(Flds, "flds")
+}
+
+func (x *flds) parseRanges() error {
+	for _, r := range x.ranges {
+		a, err := opt.ParseRange(r)
+		if err != nil {
+			return err
+		}
+		if a.P0 == 1 && a.P1 == -1 {
+			x.all = true
+		}
+		x.addrs = append(x.addrs, a)
+	}
+	return nil
+}
+
+func (x *flds) run(in <-chan face{}, out chan<- face{}) {
+	osep := "\t"
+	if x.osep != "" {
+		osep = x.osep
+	} else {
+		if x.seps == "" {
+			osep = "\t"
+		} else {
+			osep = x.seps
+		}
+		if !x.one {
+			osep = osep[:1]
+		}
+	}
+	var hdr []string
+	for m := range in {
+		var r cmd.Rec
+		switch m := m.(type) {
+		case cmd.Rec:
+			r = m
+		case []byte:
+			s := string(m)
+			if len(s) > 0 && s[len(s)-1] == '\n' {
+				s = s[:len(s)-1]
+			}
+			fields := x.split(s)
+			if !x.rflag {
+				x.printFields(fields, osep, out)
+				continue
+			}
+			if x.hflag && hdr == nil {
+				hdr = fields
+				continue
+			}
+			if hdr != nil {
+				r = cmd.NewRec(hdr, fields)
+			} else {
+				r = cmd.NewRec(x.names, fields)
+			}
+		case zx.Dir:
+			if x.hflag {
+				hdr = nil // next file has its own header
+			}
+			out <- m
+			continue
+		default:
+			cmd.Dprintf("got %T\n", m)
+			out <- m
+			continue
+		}
+		r = x.selectRec(r)
+		if x.ux {
+			x.printFields(r.Vals(), osep, out)
+			continue
+		}
+		if ok := out <- r; !ok {
+			cmd.Fatal(cerror(out))
+		}
+	}
+}
+
+func (x *flds) split(s string) []string {
+	if x.one {
+		if x.seps == "" {
+			x.seps = "\t"
+		}
+		return strings.Split(s, x.seps)
+	}
+	if x.seps == "" {
+		return strings.Fields(s)
+	}
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return strings.ContainsRune(x.seps, r)
+	})
+}
+
+func (x *flds) printFields(fields []string, osep string, out chan<- face{}) {
+	if x.all {
+		cmd.Printf("%s\n", strings.Join(fields, osep))
+		return
+	}
+	b := &bytes.Buffer{}
+	sep := ""
+	for na, a := range x.addrs {
+		for i, fld := range fields {
+			nfld := i + 1
+			cmd.Dprintf("tl match %d of %d in a%d %s\n", nfld, len(fields), na, a)
+			if a.Matches(nfld, len(fields)) {
+				fmt.Fprintf(b, "%s%s", sep, fld)
+				sep = osep
+			}
+		}
+	}
+	fmt.Fprintf(b, "\n")
+	if ok := out <- b.Bytes(); !ok {
+		cmd.Fatal(cerror(out))
+	}
+}
+
+// Select the fields named by -n or within the ranges from a record.
+func (x *flds) selectRec(r cmd.Rec) cmd.Rec {
+	if len(x.fnames) > 0 {
+		nr := cmd.Rec{}
+		for _, n := range x.fnames {
+			if i := r.Index(n); i >= 0 {
+				nr = append(nr, r[i])
+			}
+		}
+		return nr
+	}
+	if x.all {
+		return r
+	}
+	nr := cmd.Rec{}
+	for _, a := range x.addrs {
+		for i, f := range r {
+			if a.Matches(i+1, len(r)) {
+				nr = append(nr, f)
+			}
+		}
+	}
+	return nr
+}
+
+// Run print fields in the current app context.
+func Flds() {
+	x := &flds{opts: opt.New("{file}")}
+	cmd.UnixIO("err")
+	c := cmd.AppCtx()
+	x.opts.NewFlag("D", "debug", &c.Debug)
+	x.opts.NewFlag("r", "range: print this range", &x.ranges)
+	x.opts.NewFlag("F", "sep: input field delimiter character(s) (or string under -1)", &x.seps)
+	x.opts.NewFlag("o", "sep: output field delimiter string", &x.osep)
+	x.opts.NewFlag("1", "fields separated by 1 run of the field delimiter string", &x.one)
+	x.opts.NewFlag("R", "emit records with named fields instead of text", &x.rflag)
+	x.opts.NewFlag("N", "names: comma separated names for the fields in records (-R implied)", &x.nlist)
+	x.opts.NewFlag("H", "the first line of each file names the fields (-R implied)", &x.hflag)
+	x.opts.NewFlag("n", "name: print the field with this name (records)", &x.fnames)
+	x.opts.NewFlag("u", "use unix out", &x.ux)
+	args := x.opts.Parse()
+	if x.nlist != "" {
+		x.names = strings.Split(x.nlist, ",")
+	}
+	x.rflag = x.rflag || x.hflag || len(x.names) > 0
+	if x.ux {
+		cmd.UnixIO("out")
+	}
+	if len(args) != 0 {
+		cmd.SetIn("in", cmd.Files(args...))
+	}
+	if len(x.ranges) == 0 {
+		x.ranges = append(x.ranges, ",")
+	}
+	if err := x.parseRanges(); err != nil {
+		cmd.Fatal(err)
+	}
+	in := cmd.Lines(cmd.In("in"))
+	x.run(in, cmd.Out("out"))
+	if err := cerror(in); err != nil {
+		cmd.Fatal(err)
+	}
+}
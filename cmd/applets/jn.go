@@ -0,0 +1,291 @@
+package applets
+
+import (
+	"clive/cmd"
+	"clive/cmd/opt"
+	"clive/zx"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type jnLine []string
+
+struct jnFile {
+	lines   map[string]jnLine
+	nfields int
+	names   []string // field names, if records
+	nrecs   int      // number of lines that were records
+	nlines  int
+	keyno   int // key field nb. in the last line
+}
+
+// jn state
+struct jn {
+	opts       *opt.Flags
+	ux         bool
+	fld1, fld2 int
+	kname      string
+	one        bool
+	seps       string
+	osep       string
+	files      []*jnFile
+	keys       map[string]bool
+}
+
+func init() {
+	cmd.DefApplet(Jn, "jn")
+}
+
+func (j *jn) setSep() {
+	if j.osep == "" {
+		if j.seps == "" {
+			j.osep = "\t"
+		} else {
+			j.osep = j.seps
+		}
+		if !j.one {
+			j.osep = j.osep[:1]
+		}
+	}
+}
+
+func (j *jn) fields(s string) []string {
+	if j.one {
+		if j.seps == "" {
+			j.seps = "\t"
+		}
+		return strings.Split(s, j.seps)
+	}
+	if j.seps == "" {
+		return strings.Fields(s)
+	}
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return strings.ContainsRune(j.seps, r)
+	})
+}
+
+func (j *jn) getFiles(in <-chan face{}) error {
+	var f *jnFile
+	nfields := 0
+	fldno := j.fld1
+	name := "stdin"
+	var err error
+	for m := range in {
+		switch m := m.(type) {
+		case zx.Dir:
+			name = m["Upath"]
+			if name == "" {
+				name = m["path"]
+			}
+			if f != nil {
+				j.files = append(j.files, f)
+				f = nil
+				fldno = j.fld2
+			}
+		case []byte:
+			if f == nil {
+				f = &jnFile{lines: map[string]jnLine{}}
+			}
+			s := string(m)
+			if len(s) > 0 && s[len(s)-1] == '\n' {
+				s = s[:len(s)-1]
+			}
+			if aerr := j.add(f, name, j.fields(s), nil, fldno, &nfields); aerr != nil {
+				err = aerr
+			}
+		case cmd.Rec:
+			if f == nil {
+				f = &jnFile{lines: map[string]jnLine{}}
+			}
+			no := fldno
+			if j.kname != "" {
+				no = m.Index(j.kname) + 1
+			}
+			if aerr := j.add(f, name, m.Vals(), m.Names(), no, &nfields); aerr != nil {
+				err = aerr
+			}
+		default:
+			cmd.Dprintf("ignored %T\n", m)
+		}
+	}
+	if f != nil {
+		j.files = append(j.files, f)
+	}
+	if err == nil {
+		err = cerror(in)
+	}
+	return err
+}
+
+// Add a line (or record, if names is not nil) to the file, keyed by field fldno.
+func (j *jn) add(f *jnFile, name string, fields, names []string, fldno int, nfields *int) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	if len(fields) > *nfields {
+		*nfields = len(fields)
+	}
+	if fldno < 1 || fldno > len(fields) {
+		cmd.Warn("%s: wrong number of fields in '%s'", name, strings.Join(fields, j.osep))
+		return errors.New("wrong number of fields")
+	}
+	fld := fields[fldno-1]
+	j.keys[fld] = true
+	f.nfields = *nfields
+	f.keyno = fldno
+	f.nlines++
+	if names != nil {
+		f.nrecs++
+	}
+	if len(j.files) > 0 {
+		// remove key from line
+		f.nfields--
+		var nflds jnLine
+		nflds = append(nflds, fields[0:fldno-1]...)
+		nflds = append(nflds, fields[fldno:]...)
+		fields = nflds
+		if names != nil {
+			var nnames []string
+			nnames = append(nnames, names[0:fldno-1]...)
+			names = append(nnames, names[fldno:]...)
+		}
+	}
+	if len(names) > len(f.names) {
+		f.names = names
+	}
+	if f.lines[fld] != nil {
+		cmd.Warn("%s: dup lines for key %s", name, fld)
+	}
+	f.lines[fld] = fields
+	return nil
+}
+
+type asNumbers []string
+
+func (x asNumbers) Len() int      { return len(x) }
+func (x asNumbers) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
+func (x asNumbers) Less(i, j int) bool {
+	// could convert first and then sort, but this suffices by now.
+	n1, _ := strconv.ParseFloat(x[i], 64)
+	n2, _ := strconv.ParseFloat(x[j], 64)
+	return n1 < n2
+}
+
+func (j *jn) keyList() []string {
+	ks := []string{}
+	numbers := true
+	for k := range j.keys {
+		if numbers && k != "" && k != "-" {
+			_, err := strconv.ParseFloat(k, 64)
+			numbers = err == nil
+		}
+		ks = append(ks, k)
+	}
+	if numbers {
+		sort.Sort(asNumbers(ks))
+	} else {
+		sort.Sort(sort.StringSlice(ks))
+	}
+	return ks
+}
+
+func (f jnFile) getLine(k string) jnLine {
+	ln := f.lines[k]
+	for i := len(ln); i < f.nfields; i++ {
+		ln = append(ln, "-")
+	}
+	return ln
+}
+
+func (f jnFile) fakeLine(k string, fno int) jnLine {
+	var ln jnLine
+	for i := 0; i < f.nfields; i++ {
+		if i+1 == fno {
+			ln = append(ln, k)
+		} else {
+			ln = append(ln, "-")
+		}
+	}
+	return ln
+}
+
+// Are all lines in all files records?
+func (j *jn) allRecs() bool {
+	for _, f := range j.files {
+		if f.nrecs == 0 || f.nrecs != f.nlines {
+			return false
+		}
+	}
+	return len(j.files) > 0
+}
+
+func (j *jn) join() error {
+	var names []string
+	if !j.ux && j.allRecs() {
+		for _, f := range j.files {
+			fnames := f.names
+			for i := len(fnames); i < f.nfields; i++ {
+				fnames = append(fnames, "")
+			}
+			names = append(names, fnames...)
+		}
+	}
+	out := cmd.Out("out")
+	for _, k := range j.keyList() {
+		var ln jnLine
+		for i, f := range j.files {
+			var fln jnLine
+			if f.lines[k] == nil && i == 0 {
+				fln = f.fakeLine(k, f.keyno)
+			} else {
+				fln = f.getLine(k)
+			}
+			ln = append(ln, fln...)
+		}
+		if names != nil {
+			if ok := out <- cmd.NewRec(names, ln); !ok {
+				return cerror(out)
+			}
+			continue
+		}
+		if _, err := cmd.Printf("%s\n", strings.Join(ln, j.osep)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run join in the current app context.
+func Jn() {
+	j := &jn{opts: opt.New("{file}"), fld1: 1, fld2: 1}
+	cmd.UnixIO("err")
+	c := cmd.AppCtx()
+	j.opts.NewFlag("D", "debug", &c.Debug)
+	j.opts.NewFlag("u", "unix IO", &j.ux)
+	j.opts.NewFlag("k1", "nb: join on this field nb. for 1st file", &j.fld1)
+	j.opts.NewFlag("k2", "nb: join on this field nb. for 2nd and following files", &j.fld2)
+	j.opts.NewFlag("n", "name: join records on the field with this name", &j.kname)
+	j.opts.NewFlag("i", "isep: input field separator character(s) or string under -1", &j.seps)
+	j.opts.NewFlag("1", "fields are separated by one run of the separator string", &j.one)
+	j.opts.NewFlag("o", "osep: output field delimiter string", &j.osep)
+	args := j.opts.Parse()
+	if j.ux {
+		cmd.UnixIO("out")
+	}
+	j.keys = map[string]bool{}
+	j.setSep()
+	if err := j.getFiles(cmd.Lines(cmd.In("in"))); err != nil {
+		cmd.Fatal(err)
+	}
+	if len(args) > 0 {
+		in := cmd.Lines(cmd.Files(args...))
+		if err := j.getFiles(cmd.Lines(in)); err != nil {
+			cmd.Fatal(err)
+		}
+	}
+	if err := j.join(); err != nil {
+		cmd.Fatal(err)
+	}
+}
@@ -0,0 +1,356 @@
+package applets
+
+import (
+	"clive/cmd"
+	"clive/cmd/opt"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type sKind int
+
+const (
+	sStr  sKind = iota // sort as string
+	sNum               // sort as a number (integer or float)
+	sTime              // sort as a time
+)
+
+struct sKey {
+	from, to int
+	name     string // named field in records (-k)
+	kind     sKind
+	rev      bool
+	all      bool
+}
+
+struct xSort {
+	s     *srt
+	lines []string
+	recs  []cmd.Rec  // records for lines, or nil
+	keys  [][]face{} // field or line keys to sort
+	revs  []bool     // which addr is reverse order?
+}
+
+// srt state
+struct srt {
+	opts             *opt.Flags
+	one, uniq, xflag bool
+	seps             string
+	addrs            []sKey
+	kargs, knames    []string
+	ux               bool
+}
+
+func init() {
+	cmd.DefApplet(Srt, "srt")
+}
+
+func (x *xSort) Len() int {
+	return len(x.lines)
+}
+
+func (x *xSort) Swap(i, j int) {
+	x.lines[i], x.lines[j] = x.lines[j], x.lines[i]
+	x.recs[i], x.recs[j] = x.recs[j], x.recs[i]
+	x.keys[i], x.keys[j] = x.keys[j], x.keys[i]
+}
+
+func (x *xSort) Less(i, j int) (res bool) {
+	ki := x.keys[i]
+	kj := x.keys[j]
+	defer cmd.Dprintf("\t< %v %v -> %v\t\t%v\n", ki, kj, res, x.revs)
+	for n := 0; n < len(ki); n++ {
+		rev := x.revs[n]
+		switch vi := ki[n].(type) {
+		case float64:
+			vj := kj[n].(float64)
+			if rev {
+				vi, vj = vj, vi
+			}
+			if vi < vj {
+				return true
+			}
+			if vi > vj {
+				return false
+			}
+		case string:
+			vj := kj[n].(string)
+			if rev {
+				vi, vj = vj, vi
+			}
+			if vi < vj {
+				return true
+			}
+			if vi > vj {
+				return false
+			}
+		case time.Time:
+			vj := kj[n].(time.Time)
+			if rev {
+				vi, vj = vj, vi
+				continue
+			}
+			if vi.Before(vj) {
+				return true
+			}
+			if vi.After(vj) {
+				return false
+			}
+		}
+	}
+	return false
+}
+
+// Parse the [n|t|s][r] suffix of a key
+func parseKind(r string) (string, sKind, bool) {
+	rev := false
+	kind := sStr
+	if len(r) > 0 && r[len(r)-1] == 'r' {
+		rev = true
+		r = r[:len(r)-1]
+	}
+	if len(r) > 0 {
+		switch r[len(r)-1] {
+		case 's':
+			r = r[:len(r)-1]
+		case 'n':
+			kind = sNum
+			r = r[:len(r)-1]
+		case 't':
+			kind = sTime
+			r = r[:len(r)-1]
+		}
+	}
+	return r, kind, rev
+}
+
+func (s *srt) parseKeys() error {
+	for _, k := range s.knames {
+		name, suff := k, ""
+		if n := strings.LastIndexByte(k, ':'); n >= 0 {
+			name, suff = k[:n], k[n+1:]
+		}
+		rest, kind, rev := parseKind(suff)
+		if name == "" || rest != "" {
+			return fmt.Errorf("%s: bad key", k)
+		}
+		s.addrs = append(s.addrs, sKey{name: name, kind: kind, rev: rev})
+	}
+	for _, r := range s.kargs {
+		r, kind, rev := parseKind(r)
+		if r == "" {
+			a := sKey{kind: kind, rev: rev, all: true}
+			s.addrs = append(s.addrs, a)
+			continue
+		}
+		toks := strings.SplitN(r, ",", 2)
+		if len(toks) == 1 {
+			toks = append(toks, toks[0])
+		}
+		if len(toks[0]) == 0 {
+			toks[0] = "1"
+		}
+		if len(toks[1]) == 0 {
+			toks[1] = "-1"
+		}
+		from, err := strconv.Atoi(toks[0])
+		if err != nil {
+			return fmt.Errorf("%s: %s", r, err)
+		}
+		to, err := strconv.Atoi(toks[1])
+		if err != nil {
+			return fmt.Errorf("%s: %s", r, err)
+		}
+		a := sKey{from: from, to: to, kind: kind, rev: rev}
+		s.addrs = append(s.addrs, a)
+	}
+	return nil
+}
+
+// Return the fields for the i-th line, as a record.
+func (x *xSort) fields(i int) cmd.Rec {
+	if x.recs[i] != nil {
+		return x.recs[i]
+	}
+	ln := x.lines[i]
+	var fields []string
+	if x.s.one {
+		fields = strings.Split(ln, x.s.seps)
+	} else {
+		fields = strings.FieldsFunc(ln, func(r rune) bool {
+			return strings.ContainsRune(x.s.seps, r)
+		})
+	}
+	return cmd.NewRec(nil, fields)
+}
+
+func (x *xSort) initKey(k sKind, fldnb int, name string, rev bool, all bool) {
+	x.revs = append(x.revs, rev)
+	for i := 0; i < len(x.lines); i++ {
+		fld := x.lines[i]
+		if !all {
+			r := x.fields(i)
+			switch {
+			case name != "":
+				fld, _ = r.Get(name)
+			case fldnb >= 1 && fldnb <= len(r):
+				fld = r[fldnb-1].Val
+			default:
+				fld = ""
+			}
+		}
+		switch k {
+		case sNum:
+			nb, err := strconv.ParseFloat(fld, 64)
+			if err != nil {
+				n, err := strconv.Atoi(fld)
+				if err != nil {
+					cmd.Warn("non numeric field '%s'", fld)
+				}
+				nb = float64(n)
+			}
+			x.keys[i] = append(x.keys[i], nb)
+		case sTime:
+			t, err := opt.ParseTime(fld)
+			if err != nil {
+				cmd.Warn("non time field '%s'", fld)
+			}
+			x.keys[i] = append(x.keys[i], t)
+		default:
+			x.keys[i] = append(x.keys[i], fld)
+		}
+	}
+}
+
+func (x *xSort) sort() error {
+	x.keys = make([][]face{}, len(x.lines))
+	for _, a := range x.s.addrs {
+		if a.name != "" {
+			x.initKey(a.kind, 0, a.name, a.rev, false)
+			continue
+		}
+		if a.from < 0 {
+			a.from = len(x.lines) - (-a.from) + 1
+		}
+		if a.to < 0 {
+			a.to = len(x.lines) - (-a.to) + 1
+		}
+		for i := a.from; i <= a.to; i++ {
+			x.initKey(a.kind, i, "", a.rev, a.all)
+		}
+	}
+	cmd.Dprintf("%d lines %d keys %d revs:\n", len(x.lines), len(x.keys), len(x.revs))
+	for _, r := range x.revs {
+		cmd.Dprintf("\t%v", r)
+	}
+	cmd.Dprintf("\n")
+	for _, ks := range x.keys {
+		for _, k := range ks {
+			cmd.Dprintf("\t%v", k)
+		}
+		cmd.Dprintf("\n")
+	}
+
+	sort.Stable(x)
+
+	last := ""
+	for i, ln := range x.lines {
+		ln := ln
+		if x.s.uniq && i > 0 && last == ln {
+			continue
+		}
+		if r := x.recs[i]; r != nil && !x.s.ux {
+			if ok := cmd.Out("out") <- r; !ok {
+				return cerror(cmd.Out("out"))
+			}
+		} else if _, err := cmd.Printf("%s\n", ln); err != nil {
+			return err
+		}
+		last = ln
+	}
+	*x = xSort{s: x.s}
+	return nil
+}
+
+func (s *srt) sortFiles(in <-chan face{}) error {
+	out := cmd.Out("out")
+	x := &xSort{s: s}
+	for m := range in {
+		switch m := m.(type) {
+		case []byte:
+			ln := string(m)
+			if len(ln) > 0 && ln[len(ln)-1] == '\n' {
+				ln = ln[:len(ln)-1]
+			}
+			x.lines = append(x.lines, ln)
+			x.recs = append(x.recs, nil)
+		case cmd.Rec:
+			x.lines = append(x.lines, m.String())
+			x.recs = append(x.recs, m)
+		default:
+			cmd.Dprintf("got %T\n", m)
+			if s.xflag {
+				// else we sort all files in input and
+				// it's not meaningful to fwd dirs and other msgs.
+				if err := x.sort(); err != nil {
+					close(in, err)
+				}
+				if ok := out <- m; !ok {
+					close(in, cerror(out))
+				}
+			}
+		}
+	}
+	if err := x.sort(); err != nil {
+		return err
+	}
+	return cerror(in)
+}
+
+func (s *srt) setSep() {
+	if s.one {
+		if s.seps == "" {
+			s.seps = "\t"
+		}
+	} else {
+		if s.seps == "" {
+			s.seps = " \t"
+		}
+	}
+}
+
+// Run sort lines in the current app context.
+func Srt() {
+	s := &srt{opts: opt.New("{file}")}
+	c := cmd.AppCtx()
+	cmd.UnixIO("err")
+	s.opts.NewFlag("D", "debug", &c.Debug)
+	s.opts.NewFlag("d", "do not print dup lines", &s.uniq)
+	s.opts.NewFlag("r", "key: use this field range as the sort key(s)", &s.kargs)
+	s.opts.NewFlag("k", "name[:nts][r]: use this record field as a sort key", &s.knames)
+	s.opts.NewFlag("F", "sep: input field delimiter character(s) (or string under -1)", &s.seps)
+	s.opts.NewFlag("1", "fields separated by 1 run of the field delimiter string", &s.one)
+	s.opts.NewFlag("x", "sort each extracted text on its own (eg. out from gr -x)", &s.xflag)
+	s.opts.NewFlag("u", "use unix out", &s.ux)
+	args := s.opts.Parse()
+	if s.ux {
+		cmd.UnixIO("out")
+	}
+	if len(args) != 0 {
+		cmd.SetIn("in", cmd.Files(args...))
+	}
+	if len(s.kargs) == 0 && len(s.knames) == 0 {
+		s.kargs = append(s.kargs, ",")
+	}
+	if err := s.parseKeys(); err != nil {
+		cmd.Fatal(err)
+	}
+	s.setSep()
+	err := s.sortFiles(cmd.Lines(cmd.In("in")))
+	if err != nil {
+		cmd.Fatal(err)
+	}
+}
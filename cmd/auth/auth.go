@@ -1,14 +1,35 @@
 /*
 	Create authentication keys for Clive.
 
-	usage: auth [-f] [-d adir] name user secret [group...]
+	usage: auth [-afk] [-d adir] name user secret|pubkey [group...]
+		auth [-d adir] -l name
+		auth [-d adir] -r name user...
+		-a: authorize the public key given for the user
 		-d adir: clive auth dir
-		-f: force write of key file when file already exists
+		-f: force write of key file when the user already exists
+		-k: generate a public key pair for the user
+		-l: list users in the auth domain
+		-r: remove users from the auth domain
 
-	Creates a key file at the clive auth dir for the authdomain name
-	and user given, containing the key corresponding to the given secret.
+	Adds to the key file at the clive auth dir for the authdomain name
+	the user given, with the key corresponding to the given secret.
+	Key files may keep keys for many users, each one with its own groups.
 
-	Under flag -f it rewrites the key file even if it exists.
+	Under flag -f it replaces the key for the user even if it exists.
+
+	Under flag -k, no secret is given, and an ed25519 key pair is generated
+	instead. The private key is added to the private key file for the auth domain
+	and the public key is printed, to be authorized at the servers.
+
+	Under flag -a, the public key given (as printed using -k) is authorized
+	for the user and groups given, by adding it to the authorized keys file
+	for the auth domain.
+
+	Under flag -l, users and their groups are listed for each key file of
+	the auth domain.
+
+	Under flag -r, the users given are removed from all key files of
+	the auth domain.
 */
 package main
 
@@ -17,30 +38,71 @@ import (
 	"clive/cmd/opt"
 	"clive/net/auth"
 	"os"
+	"strings"
 )
 
 var (
-	dir   string
-	force bool
-	opts  = opt.New("name user secret [group...]")
+	dir                 string
+	force, pkflag, akey bool
+	lflag, rflag        bool
+	opts                = opt.New("name user secret|pubkey [group...]")
 )
 
 func main() {
 	cmd.UnixIO()
 	dfltdir := auth.KeyDir()
 	dir = dfltdir
+	opts.AddUsage("\tauth [-d adir] -l name\n")
+	opts.AddUsage("\tauth [-d adir] -r name user...\n")
 	opts.NewFlag("d", "adir: clive auth dir", &dir)
-	opts.NewFlag("f", "force write of key file when file already exists", &force)
+	opts.NewFlag("f", "force write of key file when the user already exists", &force)
+	opts.NewFlag("k", "generate a public key pair for the user", &pkflag)
+	opts.NewFlag("a", "authorize the public key given for the user", &akey)
+	opts.NewFlag("l", "list users in the auth domain", &lflag)
+	opts.NewFlag("r", "remove users from the auth domain", &rflag)
 	args := opts.Parse()
+	nflags := 0
+	for _, f := range []bool{pkflag, akey, lflag, rflag} {
+		if f {
+			nflags++
+		}
+	}
+	if nflags > 1 {
+		opts.Usage()
+	}
+	switch {
+	case lflag:
+		if len(args) != 1 {
+			opts.Usage()
+		}
+		list(args[0])
+		return
+	case rflag:
+		if len(args) < 2 {
+			opts.Usage()
+		}
+		remove(args[0], args[1:]...)
+		return
+	case pkflag:
+		if len(args) < 2 {
+			opts.Usage()
+		}
+		newPrivKey(args[0], args[1], args[2:]...)
+		return
+	}
 	if len(args) < 3 {
 		opts.Usage()
 	}
 	name, user, secret := args[0], args[1], args[2]
 	groups := args[3:]
+	if akey {
+		authorize(name, user, secret, groups...)
+		return
+	}
 	file := auth.KeyFile(dir, name)
-	fi, _ := os.Stat(file)
-	if fi != nil && !force {
-		cmd.Fatal("key file already exists")
+	old, _ := auth.LoadKey(dir, name)
+	if _, ok := auth.FindKey(old, user); ok && !force {
+		cmd.Fatal("%s: user %s already exists", file, user)
 	}
 	err := auth.SaveKey(dir, name, user, secret, groups...)
 	if err != nil {
@@ -50,11 +112,80 @@ func main() {
 	if err != nil {
 		cmd.Fatal("can't load key: %s", err)
 	}
-	for _, k := range ks {
-		if k.Uid == user {
-			cmd.Warn("%s", file)
-			return
-		}
+	if _, ok := auth.FindKey(ks, user); ok {
+		cmd.Warn("%s", file)
+		return
 	}
 	cmd.Fatal("bad user")
 }
+
+func newPrivKey(name, user string, groups ...string) {
+	file := auth.PrivKeyFile(dir, name)
+	old, _ := auth.LoadPrivKey(dir, name)
+	if _, ok := auth.FindKey(old, user); ok && !force {
+		cmd.Fatal("%s: user %s already exists", file, user)
+	}
+	pub, err := auth.NewPrivKey(dir, name, user, groups...)
+	if err != nil {
+		cmd.Fatal("%s: %s", file, err)
+	}
+	cmd.Warn("%s", file)
+	cmd.Printf("%x\n", pub)
+}
+
+func authorize(name, user, pubkey string, groups ...string) {
+	pub, err := auth.ParsePubKey(pubkey)
+	if err != nil {
+		cmd.Fatal("%s", err)
+	}
+	file := auth.PubKeysFile(dir, name)
+	old, _ := auth.LoadPubKeys(dir, name)
+	if _, ok := auth.FindKey(old, user); ok && !force {
+		cmd.Fatal("%s: user %s already exists", file, user)
+	}
+	if err := auth.AuthorizeKey(dir, name, user, pub, groups...); err != nil {
+		cmd.Fatal("%s: %s", file, err)
+	}
+	cmd.Warn("%s", file)
+}
+
+func list(name string) {
+	lists := []struct {
+		kind string
+		load func(string, string) ([]auth.Key, error)
+	}{
+		{"key", auth.LoadKey},
+		{"privkey", auth.LoadPrivKey},
+		{"pubkey", auth.LoadPubKeys},
+	}
+	for _, l := range lists {
+		ks, err := l.load(dir, name)
+		if err != nil && !os.IsNotExist(err) {
+			cmd.Warn("%s: %s", l.kind, err)
+		}
+		for _, k := range ks {
+			cmd.Printf("%s\t%s\t%s\n", l.kind, k.Uid, strings.Join(k.Gids, " "))
+		}
+	}
+}
+
+func remove(name string, users ...string) {
+	dels := []struct {
+		file string
+		del  func(string, string, string) error
+	}{
+		{auth.KeyFile(dir, name), auth.DelKey},
+		{auth.PrivKeyFile(dir, name), auth.DelPrivKey},
+		{auth.PubKeysFile(dir, name), auth.DelPubKey},
+	}
+	for _, d := range dels {
+		if fi, _ := os.Stat(d.file); fi == nil {
+			continue
+		}
+		for _, u := range users {
+			if err := d.del(dir, name, u); err != nil {
+				cmd.Warn("%s: %s", d.file, err)
+			}
+		}
+	}
+}
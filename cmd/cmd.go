@@ -32,9 +32,12 @@
 
 	The name space is constructed by the first method that works:
 		1. Using $NS as the description (see ns.Parse).
-		2. Using the contents of $HOME/NS as the description.
+		2. Using the contents of $HOME/lib/NS or $HOME/NS as the description.
 		3. Using "/"
 	Methods 2 and 3 set $NS to the resulting name space.
+	Changes made with NSCtl may be persistent, in which case they are
+	written to the NS file, and ReloadNS picks up changes made
+	to that file by others.
 */
 package cmd
 
@@ -74,7 +77,8 @@ var (
 	ctxs  = map[int64]*Ctx{}
 	ctxlk sync.Mutex
 
-	ErrIO = errors.New("no such IO chan")
+	ErrIO   = errors.New("no such IO chan")
+	ErrIntr = errors.New("interrupted")
 
 	mainctx *Ctx
 )
@@ -102,12 +106,13 @@ func ctx() *Ctx {
 
 func (c *Ctx) close(sts string) {
 	if c != nil {
+		// flush the output before waiters are awaken
+		c.io.close()
 		if sts != "" {
 			close(c.wc, sts)
 		} else {
 			close(c.wc)
 		}
-		c.io.close()
 		ctxlk.Lock()
 		delete(ctxs, c.id)
 		ctxlk.Unlock()
@@ -509,6 +514,24 @@ func (c *Ctx) SetOut(name string, ioc chan<- face{}) {
 	io.addOut(name, ioc)
 }
 
+// Set the named input chan to read from the unix file f, like
+// a command started as a unix process with f as one of its descriptors would do.
+// The file is not closed by the context.
+func (c *Ctx) SetUXIn(name string, f *os.File) {
+	c.lk.Lock()
+	io := c.io
+	c.lk.Unlock()
+	io.addUXFile(name, f, true)
+}
+
+// Set the named output chan to write to the unix file f (see SetUXIn).
+func (c *Ctx) SetUXOut(name string, f *os.File) {
+	c.lk.Lock()
+	io := c.io
+	c.lk.Unlock()
+	io.addUXFile(name, f, false)
+}
+
 func (c *Ctx) cprintf(name, f string, args ...face{}) (n int, err error) {
 	out := c.Out(name)
 	if out == nil {
@@ -1,9 +1,11 @@
 package cmd
 
 import (
+	"bytes"
 	"clive/u"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestCmd(t *testing.T) {
@@ -34,3 +36,71 @@ func TestCmd(t *testing.T) {
 	Warn("ho")
 	close(out)
 }
+
+func TestAppletIntr(t *testing.T) {
+	DefApplet(func() {
+		in := In("in")
+		for range in {
+		}
+		Exit(cerror(in))
+	}, "intrtest")
+	rd, wr, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+	defer wr.Close()
+	c, err := RunApplet(func(c *Ctx) {
+		c.SetUXIn("in", rd)
+	}, "intrtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	c.Intr()
+	wc := c.Waitc()
+	select {
+	case <-wc:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("applet not interrupted")
+	}
+	if err := cerror(wc); err == nil || err.Error() != ErrIntr.Error() {
+		t.Fatalf("applet sts %v", err)
+	}
+	// nobody else reads from the file
+	wr.Write([]byte("hi\n"))
+	buf := make([]byte, 10)
+	n, _ := rd.Read(buf)
+	if string(buf[:n]) != "hi\n" {
+		t.Fatalf("read %q", buf[:n])
+	}
+}
+
+func TestRec(t *testing.T) {
+	r := NewRec([]string{"name", "", "size"}, []string{"a b", "x", "3"})
+	r.Set("mode", "0644")
+	if s := r.String(); s != "a b\tx\t3\t0644" {
+		t.Fatalf("bad rec %q", s)
+	}
+	if v, ok := r.Get("2"); !ok || v != "x" {
+		t.Fatalf("bad get by number")
+	}
+	if v, _ := r.Get("size"); v != "3" {
+		t.Fatalf("bad get by name")
+	}
+	js := `{"name": "a b", "2": "x", "size": "3", "mode": "0644"}`
+	if s := r.JSON(); s != js {
+		t.Fatalf("bad json %s", s)
+	}
+	var b bytes.Buffer
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	rest, nr, err := UnpackRec(b.Bytes())
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("unpack: %v %d", err, len(rest))
+	}
+	if nr.JSON() != js {
+		t.Fatalf("bad unpacked rec %s", nr.JSON())
+	}
+}
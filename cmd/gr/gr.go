@@ -29,6 +29,7 @@ var (
 	out     chan<- face{}
 
 	sflag, aflag, mflag, vflag, fflag, lflag, xflag, eflag bool
+	iflag, Fflag                                           bool
 )
 
 // update ql/builtin.go bltin table if new aliases are added or some are removed.
@@ -381,6 +382,8 @@ func main() {
 	opts.NewFlag("f", "print addresses for matches in full files (like sam)", &fflag)
 	opts.NewFlag("x", "print selections for further editing commands", &xflag)
 	opts.NewFlag("e", "extend regexps to match all the text", &eflag)
+	opts.NewFlag("i", "ignore case", &iflag)
+	opts.NewFlag("F", "match strings and not regexps", &Fflag)
 	ux := false
 	opts.NewFlag("u", "use unix out", &ux)
 	aliases()
@@ -394,18 +397,28 @@ func main() {
 		cmd.Warn("wrong number or arguments")
 		opts.Usage()
 	}
+	dir := sre.Fwd
+	if iflag {
+		dir |= sre.Fold
+	}
+	if Fflag && !eflag {
+		dir |= sre.Lit
+	}
 	if eflag {
 		for i, a := range args {
+			if Fflag {
+				a = sre.QuoteMeta(a)
+			}
 			args[i] = `(.|\n)*(` + a + `)(.|\n)*`
 		}
 	}
 	var err error
-	re, err = sre.CompileStr(args[0], sre.Fwd)
+	re, err = sre.CompileStr(args[0], dir)
 	if err != nil {
 		cmd.Fatal(err)
 	}
 	if len(args) == 2 {
-		ere, err = sre.CompileStr(args[1], sre.Fwd)
+		ere, err = sre.CompileStr(args[1], dir)
 		if err != nil {
 			cmd.Fatal(err)
 		}
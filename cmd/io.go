@@ -24,6 +24,7 @@ struct ioChan {
 	name  string
 	ux    bool
 	uxfd  int
+	uxf   *os.File // for uxfd, when it's not from our unix process
 }
 
 struct ioSet {
@@ -86,17 +87,30 @@ func (cr *ioChan) start() {
 		return
 	}
 	var fd *os.File
-	switch cr.uxfd {
-	case 0:
+	var rd io.Reader
+	switch {
+	case cr.uxf != nil:
+		fd = cr.uxf // not ours, not to be closed
+		if cr.isIn {
+			// but we must stop reading it when done
+			if r := newUXRdr(fd); r != nil {
+				rd = r
+				cr.fd = r
+			}
+		}
+	case cr.uxfd == 0:
 		fd = os.Stdin
-	case 1:
+	case cr.uxfd == 1:
 		fd = os.Stdout
-	case 2:
+	case cr.uxfd == 2:
 		fd = os.Stderr
 	default:
 		fd = os.NewFile(uintptr(cr.uxfd), cr.name)
 		cr.fd = fd
 	}
+	if rd == nil {
+		rd = fd
+	}
 	if cr.isIn {
 		cr.inc = c
 		rfn := ch.ReadMsgs
@@ -104,7 +118,7 @@ func (cr *ioChan) start() {
 			rfn = ch.ReadBytes
 		}
 		go func() {
-			_, _, err := rfn(fd, c)
+			_, _, err := rfn(rd, c)
 			close(c, err)
 		}()
 	} else {
@@ -188,6 +202,18 @@ func (io *ioSet) addUXOut(name string, fd int) *ioChan {
 	return nc
 }
 
+// Add a chan for the given file, which is not closed by us.
+func (io *ioSet) addUXFile(name string, f *os.File, isIn bool) *ioChan {
+	var nc *ioChan
+	if isIn {
+		nc = io.addUXIn(name, int(f.Fd()))
+	} else {
+		nc = io.addUXOut(name, int(f.Fd()))
+	}
+	nc.uxf = f
+	return nc
+}
+
 func (io *ioSet) del(name string) {
 	io.Lock()
 	defer io.Unlock()
@@ -261,6 +287,27 @@ func (io *ioSet) close() {
 	}
 }
 
+// Stop the IO through chans for unix files not ours (see addUXFile).
+func (io *ioSet) intr() {
+	io.Lock()
+	defer io.Unlock()
+	for _, cr := range io.set {
+		cr.Lock()
+		switch {
+		case cr.uxf == nil:
+		case cr.isIn && cr.inc == nil, !cr.isIn && cr.outc == nil:
+			cr.uxfd = -1 // not started, it never will
+		default:
+			close(cr.inc, ErrIntr)
+			close(cr.outc, ErrIntr)
+			if cr.fd != nil {
+				cr.fd.Close()
+			}
+		}
+		cr.Unlock()
+	}
+}
+
 func (io *ioSet) unixIO(name ...string) {
 	io.Lock()
 	defer io.Unlock()
@@ -8,6 +8,7 @@ import (
 	"clive/net/ink"
 	"clive/sre"
 	"clive/txt"
+	"clive/txt/sam"
 	"clive/zx"
 	"fmt"
 	"io"
@@ -54,6 +55,9 @@ func init() {
 //	d	// delete
 //	u	// undo
 //	r	// redo
+//		// (undo history is kept in $home/lib/ix/undo when
+//		// edits are saved, closed, or dumped, and restored
+//		// when the file is edited again and has not changed)
 //	x	// list edits
 //	x expr	// list edits matching expr ("." means dot)
 //	x [expr] c	// apply cmd c to dots of matching edits.
@@ -64,6 +68,8 @@ func init() {
 //	>...	// like . > ...
 //	< ...	// like . > ...
 //	| ...	// like . | ...
+//	:cmds	// run sam commands (see clive/txt/sam) on dot,
+//		// eg. :,x/old/c/new/
 //
 // builtin() and some of the builtin funcs change the args[] so there is no
 // need to type spaces when using ,>..., >..., |..., etc.
@@ -81,6 +87,8 @@ func builtin(arg0 string) func(*Cmd, ...string) {
 		return bdot
 	}
 	switch arg0[0] {
+	case ':':
+		return bsam
 	case '>':
 		return bpipeTo
 	case '<':
@@ -226,6 +234,7 @@ func bdump(c *Cmd, args ...string) {
 	for i, c := range cols {
 		for _, ed := range c {
 			fmt.Fprintf(&buf, "%d\t%s\n", i, ed.tag)
+			ed.saveHistory()
 		}
 	}
 	if len(args) > 1 {
@@ -331,6 +340,40 @@ func bd(c *Cmd, args ...string) {
 	c.ed.win.DelMark(c.mark)
 }
 
+func bsam(c *Cmd, args ...string) {
+	defer c.ed.win.DelMark(c.mark)
+	dot := c.ed.ix.dot
+	if dot == nil {
+		c.printf("no edit\n")
+		c.printf("--\n")
+		return
+	}
+	var out bytes.Buffer
+	t := dot.win.GetText()
+	vers := t.Vers()
+	s := sam.New(t)
+	s.Name = dot.tag
+	s.Out = &out
+	s.Dot = sre.Range{P0: dot.dot.P0, P1: dot.dot.P1}
+	err := s.Run(strings.TrimPrefix(c.line, ":"))
+	if t.Vers() != vers {
+		dot.win.PutText()
+	} else {
+		dot.win.UngetText()
+	}
+	if err == nil {
+		dot.dot.P0, dot.dot.P1 = s.Dot.P0, s.Dot.P1
+		dot.win.SetSel(dot.dot.P0, dot.dot.P1)
+	}
+	if out.Len() > 0 {
+		c.printf("%s", out.String())
+	}
+	if err != nil {
+		c.printf("%s\n", err)
+	}
+	c.printf("--\n")
+}
+
 func bpipeTo(c *Cmd, args ...string) {
 	if args[0][0] == '>' {
 		args[0] = args[0][1:]
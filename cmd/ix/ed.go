@@ -6,7 +6,9 @@ import (
 	"clive/cmd/run"
 	"clive/net/ink"
 	"clive/txt"
+	"clive/u"
 	"clive/zx"
+	"crypto/sha1"
 	"errors"
 	"fmt"
 	"net/url"
@@ -20,6 +22,7 @@ import (
 struct Cmd {
 	ed    *Ed
 	name  string
+	line  string // command line as typed
 	mark  string
 	hasnl bool
 	p     *run.Proc
@@ -51,6 +54,9 @@ struct Ed {
 
 var notDirty = errors.New("not dirty")
 
+// files this large are kept in a rope (see txt.NewRope)
+const ropeSize = 1024 * 1024
+
 func (d Dot) String() string {
 	return fmt.Sprintf(":#%d,#%d", d.P0, d.P1)
 }
@@ -364,6 +370,7 @@ func (ed *Ed) runCmd(at int, line string) {
 	}
 	c := &Cmd{
 		name:  args[0],
+		line:  ln,
 		ed:    ed,
 		mark:  ed.newMark(at),
 		hasnl: hasnl,
@@ -657,6 +664,7 @@ func (ed *Ed) save() error {
 	if mt, ok := rd["mtime"]; ok {
 		ed.d["mtime"] = mt
 	}
+	ed.saveHistory()
 	return nil
 }
 
@@ -676,6 +684,8 @@ func (ed *Ed) load(nd zx.Dir) error {
 		t.DelAll()
 	}
 	t.DropEdits()
+	sz, _ := strconv.ParseInt(nd["size"], 0, 64)
+	t.SetRope(nd["type"] != "d" && sz >= ropeSize)
 	var dc <-chan []byte
 	if ed.d["type"] == "d" {
 		ed.temp = true
@@ -705,11 +715,61 @@ func (ed *Ed) load(nd zx.Dir) error {
 	err := cerror(dc)
 	if err != nil {
 		ed.ix.Warn("%s: get: %s", what, err)
+	} else if ed.d["type"] == "-" {
+		ed.loadHistory(t)
 	}
 	ed.win.Clean()
 	return err
 }
 
+func histFile(tag string) string {
+	return fpath.Join(u.Home, "lib", "ix", "undo", fmt.Sprintf("%x", sha1.Sum([]byte(tag))))
+}
+
+// Save the undo history for the edit, so it can be restored
+// when the same file is edited again.
+func (ed *Ed) saveHistory() {
+	if ed.iscmd || ed.temp || ed.d["type"] != "-" || dryrun {
+		return
+	}
+	t := ed.win.GetText()
+	h := t.History()
+	ed.win.UngetText()
+	if h == nil || len(h.Edits) == 0 {
+		return
+	}
+	fname := histFile(ed.tag)
+	if _, err := cmd.Stat(fpath.Dir(fname)); err != nil {
+		rc := cmd.Put(fpath.Dir(fname), zx.Dir{"type": "D"}, 0, nil)
+		<-rc
+		if err := cerror(rc); err != nil {
+			cmd.Dprintf("%s: history: %s\n", ed, err)
+			return
+		}
+	}
+	if err := cmd.PutAll(fname, h.Bytes()); err != nil {
+		cmd.Dprintf("%s: history: %s\n", ed, err)
+	}
+}
+
+// Restore the undo history saved for the edit, if it was
+// saved for the text as it is now.
+func (ed *Ed) loadHistory(t *txt.Text) {
+	dat, err := cmd.GetAll(histFile(ed.tag))
+	if err != nil {
+		return
+	}
+	_, h, err := txt.UnpackHistory(dat)
+	if err == nil {
+		err = t.SetHistory(h)
+	}
+	if err != nil {
+		cmd.Dprintf("%s: history: %s\n", ed, err)
+	} else {
+		cmd.Dprintf("%s: history restored\n", ed)
+	}
+}
+
 func (ed *Ed) refreshDot() {
 	if ed.win == nil {
 		return
@@ -747,6 +807,7 @@ func (ed *Ed) editLoop() {
 				cmd.Dprintf("%s w/o views\n", ed)
 			}
 		case "quit":
+			ed.saveHistory()
 			n := ed.ix.delEd(ed)
 			cmd.Dprintf("%s terminated\n", ed)
 			close(c, "quit")
@@ -10,7 +10,9 @@
 	The special command "not" can be used to prevent further
 	rules to match.
 	Back-references may be used to build a command from parts
-	of the matching text.
+	of the matching text, including \<name> for groups
+	named (?P<name>...) in the rule.
+	A rule starting with (?i) matches ignoring case.
 */
 package look
 
@@ -26,7 +28,7 @@ import (
 
 // If the user looks for something matching Rexp, then
 // Cmd leads to a result string.
-// Backquoting to refer to \0...\9 is ok in Cmd.
+// Backquoting to refer to \0...\9 and \<name> is ok in Cmd.
 struct Rule {
 	Rexp string
 	Cmd  string
@@ -66,12 +68,13 @@ func (r *Rule) CmdFor(s string) (string, error) {
 		}
 		r.re = re
 	}
-	outs := r.re.Match(s)
-	dprintf("look: %s: %v\n", r.Rexp, outs)
-	if len(outs) == 0 {
+	m := r.re.Find(s)
+	if m == nil {
+		dprintf("look: %s: no match\n", r.Rexp)
 		return "", ErrNoMatch
 	}
-	return sre.Repl(outs, r.Cmd), nil
+	dprintf("look: %s: %v\n", r.Rexp, m.Strs)
+	return m.Repl(r.Cmd), nil
 }
 
 // Return the command for a user look, if any.
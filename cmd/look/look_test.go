@@ -13,6 +13,24 @@ func TestCmdFor(t *testing.T) {
 	}
 }
 
+func TestCmdForNamed(t *testing.T) {
+	r := &Rule{Rexp: `^(?P<page>[a-zA-Z.]+)\((?P<sect>[0-9]+)\)$`, Cmd: `man \<sect> \<page>`}
+	s, err := r.CmdFor("foo(1)")
+	t.Logf("got %v %v\n", s, err)
+	if s != "man 1 foo" {
+		t.Fatalf("didn't get the expected match")
+	}
+}
+
+func TestCmdForFold(t *testing.T) {
+	r := &Rule{Rexp: `(?i)^http://(.*)$`, Cmd: `web \1`}
+	s, err := r.CmdFor("HTTP://lsub.org")
+	t.Logf("got %v %v\n", s, err)
+	if s != "web lsub.org" {
+		t.Fatalf("didn't get the expected match")
+	}
+}
+
 func TestParse(t *testing.T) {
 	txt := `# example
 
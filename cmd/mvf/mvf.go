@@ -4,6 +4,7 @@
 	mvf a b -> moves a to b (*not* to b/a if b exists and is a dir)
 	mvf d moves all dirs with paths .../name in stdin to be d/name, where d is the destination dir.
 	mv a b... c takes c as the target parent dir for a, b...
+	Moves across servers copy the files and then remove the sources.
 */
 package main
 
@@ -63,18 +64,10 @@ func mvf(in <-chan face{}, dst zx.Dir, todir bool) error {
 	dpath := dst["path"]
 	dupath := dst["Upath"]
 	drpath := dst["Rpath"]
-	daddr := dst.SAddr()
 	for x := range in {
 		switch d := x.(type) {
 		case zx.Dir:
 			cmd.Dprintf("got %T %s\n", d, d["Upath"])
-			if daddr != d.SAddr() {
-				cmd.Warn("%s: cross server move", d["Upath"])
-				if err == nil {
-					err = errors.New("cross server move")
-				}
-				continue
-			}
 			fd := dst.Dup()
 			base := fpath.Base(d["Rpath"])
 			if todir {
@@ -118,6 +111,11 @@ func main() {
 		cmd.UnixIO("out")
 	}
 	c.Verb = c.Verb || dry
+	if c.Verb && !dry {
+		cmd.NS().Progress = func(from, to string, size int64) {
+			cmd.VWarn("copied %s %s", from, to)
+		}
+	}
 	if len(args) == 0 {
 		cmd.Warn("missing argument")
 		opts.Usage()
@@ -6,11 +6,18 @@ import (
 	"clive/u"
 	"clive/zx"
 	"fmt"
+	"io/ioutil"
 	"os"
 	fpath "path"
 	"sync"
 )
 
+var (
+	nslk   sync.Mutex
+	nsfile string      // file the NS was read from, if any
+	nsfi   os.FileInfo // for nsfile when read
+)
+
 struct cwd {
 	path string // "" means use the OS one.
 	sync.Mutex
@@ -83,6 +90,11 @@ func mkNS() *ns.NS {
 		}
 		SetEnv("NS", s)
 	}
+	if fi, err := os.Stat(s); err == nil && fi.Mode().IsRegular() {
+		nslk.Lock()
+		nsfile, nsfi = s, fi
+		nslk.Unlock()
+	}
 	n, err := ns.Parse(s)
 	if err != nil {
 		dbg.Warn("mkNS: %s", err)
@@ -90,3 +102,80 @@ func mkNS() *ns.NS {
 	}
 	return n
 }
+
+// Return the file the NS was read from, or "" if it was not read from a file.
+func NSFile() string {
+	nslk.Lock()
+	defer nslk.Unlock()
+	return nsfile
+}
+
+// If the file the NS was read from has changed since it was read,
+// reload the NS from it, keeping the changes made at run time
+// (see ns.NS.Reload).
+// Returns true if the NS was reloaded.
+func ReloadNS() (bool, error) {
+	nslk.Lock()
+	defer nslk.Unlock()
+	if nsfile == "" {
+		return false, nil
+	}
+	fi, err := os.Stat(nsfile)
+	if err != nil || os.SameFile(fi, nsfi) &&
+		fi.ModTime().Equal(nsfi.ModTime()) && fi.Size() == nsfi.Size() {
+		return false, nil
+	}
+	nsfi = fi
+	return true, NS().Reload(nsfile)
+}
+
+// Change the NS using the given ctl commands (see ns.NS.Ctl).
+// If persist is set, the change is made instead to the file the NS
+// was read from, which is saved atomically, and the NS is reloaded from it.
+// If the NS was not read from a file, $HOME/lib/NS is written with
+// the NS as described by $NS and becomes the NS file, but it's an error
+// if that file already exists (and was not the NS source).
+// Other processes using the same file see the change when they call ReloadNS.
+func NSCtl(cmds string, persist bool) error {
+	if !persist {
+		return NS().Ctl(cmds)
+	}
+	nslk.Lock()
+	defer nslk.Unlock()
+	file := nsfile
+	desc := GetEnv("NS")
+	if file == "" {
+		file = fpath.Join(u.Home, "lib", "NS")
+		if _, err := os.Stat(file); err == nil {
+			return fmt.Errorf("%s exists and the NS was not read from it", file)
+		}
+		if err := os.MkdirAll(fpath.Dir(file), 0755); err != nil {
+			return err
+		}
+	} else {
+		dat, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		desc = string(dat)
+	}
+	fns, err := ns.Parse(desc)
+	if err != nil {
+		return err
+	}
+	if err := fns.Ctl(cmds); err != nil {
+		return err
+	}
+	if err := fns.Save(file); err != nil {
+		return err
+	}
+	fi, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	if nsfile == "" {
+		SetEnv("NS", file)
+	}
+	nsfile, nsfi = file, fi
+	return NS().Reload(file)
+}
@@ -27,7 +27,7 @@ var (
 	printf = cmd.Printf
 	odir   string
 
-	hflag, notux, lflag, pflag, nflag, iflag, dflag, aflag, fflag, sflag, wflag, wwflag bool
+	hflag, notux, jflag, lflag, pflag, nflag, iflag, dflag, aflag, fflag, sflag, wflag, wwflag bool
 )
 
 func (w *wFile) start(d zx.Dir) error {
@@ -115,6 +115,7 @@ func main() {
 	opts.NewFlag("p", "print just paths", &pflag)
 	opts.NewFlag("l", "long list for dirs", &lflag)
 	opts.NewFlag("i", "print also ignored data", &iflag)
+	opts.NewFlag("j", "print records as JSON", &jflag)
 	opts.NewFlag("u", "don't use unix out", &notux)
 	opts.NewFlag("h", "serve the output as a page sent to ink", &hflag)
 	opts.NewFlag("a", "print addresses", &aflag)
@@ -201,6 +202,17 @@ func main() {
 			if _, werr := printf("%s", string(m)); werr != nil {
 				ok = false
 			}
+		case cmd.Rec:
+			if dflag {
+				continue
+			}
+			s := m.String()
+			if jflag {
+				s = m.JSON()
+			}
+			if _, werr := printf("%s\n", s); werr != nil {
+				ok = false
+			}
 		case ch.Ign:
 			if dflag || !iflag {
 				continue
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"clive/cmd"
+	"clive/cmd/tty"
+	"clive/u"
+	"os"
+	fpath "path"
+	"sort"
+	"strings"
+)
+
+// Input from a terminal using a line editor, for ql -i
+struct edRdr {
+	ed     *tty.Editor
+	prompt string
+	hist   string // history file
+	left   []rune
+}
+
+const nHist = 1000
+
+// Return the history file, $HOME/lib/qlhist or $HOME/.qlhist
+func histFile() string {
+	if fi, err := os.Stat(fpath.Join(u.Home, "lib")); err == nil && fi.IsDir() {
+		return fpath.Join(u.Home, "lib", "qlhist")
+	}
+	return fpath.Join(u.Home, ".qlhist")
+}
+
+func newEdRdr(prompt string) *edRdr {
+	r := &edRdr{
+		ed:     tty.NewEditor(os.Stdin, os.Stdout),
+		prompt: prompt,
+		hist:   histFile(),
+	}
+	r.ed.Complete = complete
+	if fd, err := os.Open(r.hist); err == nil {
+		lns := []string{}
+		scn := bufio.NewScanner(fd)
+		for scn.Scan() {
+			lns = append(lns, scn.Text())
+		}
+		fd.Close()
+		if len(lns) > nHist {
+			lns = lns[len(lns)-nHist:]
+		}
+		for _, ln := range lns {
+			r.ed.AddHistory(ln)
+		}
+	}
+	return r
+}
+
+func (r *edRdr) Name() string {
+	return "in"
+}
+
+func (r *edRdr) addHist(ln string) {
+	if strings.TrimSpace(ln) == "" {
+		return
+	}
+	r.ed.AddHistory(ln)
+	fd, err := os.OpenFile(r.hist, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		cmd.Dprintf("history: %s\n", err)
+		return
+	}
+	fd.WriteString(ln + "\n")
+	fd.Close()
+}
+
+func (r *edRdr) ReadRune() (rune, int, error) {
+	for len(r.left) == 0 {
+		ln, err := r.ed.ReadLine(r.prompt)
+		if err != nil {
+			return 0, 0, err
+		}
+		r.addHist(ln)
+		r.left = []rune(ln + "\n")
+	}
+	c := r.left[0]
+	r.left = r.left[1:]
+	return c, len(string(c)), nil
+}
+
+// Complete the path name in word using the name space.
+func complete(word string) []string {
+	dir, pref := fpath.Split(word)
+	adir := cmd.Dot()
+	if dir != "" {
+		adir = cmd.AbsPath(dir)
+	}
+	ws := []string{}
+	for d := range cmd.NS().Find(adir, "depth==1", "/", "/", 0) {
+		name := d["name"]
+		if d["err"] != "" || !strings.HasPrefix(name, pref) {
+			continue
+		}
+		if pref == "" && strings.HasPrefix(name, ".") {
+			continue
+		}
+		w := dir + name
+		if d["type"] == "d" {
+			w += "/"
+		}
+		ws = append(ws, w)
+	}
+	sort.Strings(ws)
+	return ws
+}
@@ -42,6 +42,122 @@ func init() {
 	builtins["exit"] = bexit
 	builtins["break"] = bbreak
 	builtins["shift"] = bshift
+	builtins["local"] = blocal
+	builtins["mount"] = bmount
+	builtins["unmount"] = bunmount
+}
+
+// Split leading -xyz flags in args (known ones given in fl) and return the
+// flags set and the remaining args.
+func nsFlags(fl string, args []string) (map[rune]bool, []string, error) {
+	set := map[rune]bool{}
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		for _, r := range args[0][1:] {
+			if !strings.ContainsRune(fl, r) {
+				return nil, nil, fmt.Errorf("unknown flag -%c", r)
+			}
+			set[r] = true
+		}
+		args = args[1:]
+	}
+	return set, args, nil
+}
+
+func nsSts(x *xEnv, name string, err error) error {
+	if err != nil {
+		x.Eprintf("%s: %s\n", name, err)
+		cmd.SetEnv("sts", err.Error())
+	} else {
+		cmd.SetEnv("sts", "")
+	}
+	return nil
+}
+
+// mount [-abrp] path addr [opts] | mount [-p] dir | mount [-j]
+// Without args, print the ns; -j prints its journal.
+// -p makes the change persistent.
+func bmount(x *xEnv, args ...string) error {
+	fl, args, err := nsFlags("abrpj", args[1:])
+	if err != nil {
+		return nsSts(x, "mount", err)
+	}
+	if len(args) == 0 {
+		if fl['j'] {
+			for _, c := range cmd.NS().Journal() {
+				x.Printf("%s\n", c)
+			}
+		} else {
+			x.Printf("%s", cmd.NS())
+		}
+		return nsSts(x, "mount", nil)
+	}
+	ctl := "mount "
+	switch {
+	case fl['b']:
+		ctl += "-b "
+	case fl['r']:
+		ctl += "-r "
+	}
+	ctl += strings.Join(args, " ")
+	return nsSts(x, "mount", cmd.NSCtl(ctl, fl['p']))
+}
+
+// unmount [-p] path [addr]
+func bunmount(x *xEnv, args ...string) error {
+	fl, args, err := nsFlags("p", args[1:])
+	if err == nil && (len(args) < 1 || len(args) > 2) {
+		err = errors.New("usage: unmount [-p] path [addr]")
+	}
+	if err != nil {
+		return nsSts(x, "unmount", err)
+	}
+	ctl := "unmount " + strings.Join(args, " ")
+	return nsSts(x, "unmount", cmd.NSCtl(ctl, fl['p']))
+}
+
+// A function call frame.
+// Variables local to the call get back their previous values
+// when the call returns.
+struct frame {
+	sync.Mutex
+	saved map[string]string
+}
+
+func newFrame() *frame {
+	return &frame{saved: map[string]string{}}
+}
+
+// Make name local to the frame and set its value.
+func (f *frame) set(name, val string) {
+	f.Lock()
+	if _, ok := f.saved[name]; !ok {
+		f.saved[name] = cmd.GetEnv(name)
+	}
+	f.Unlock()
+	cmd.SetEnv(name, val)
+}
+
+func (f *frame) restore() {
+	f.Lock()
+	defer f.Unlock()
+	for n, v := range f.saved {
+		cmd.SetEnv(n, v)
+	}
+}
+
+// local name...
+// Make the variables local to the running function, initially unset.
+func blocal(x *xEnv, args ...string) error {
+	if x.fn == nil {
+		cmd.Warn("local: not in a function")
+		cmd.SetEnv("sts", "not in a function")
+		return nil
+	}
+	for _, n := range args[1:] {
+		x.fn.set(n, "")
+	}
+	cmd.SetEnv("sts", "")
+	return nil
 }
 
 func bshift(x *xEnv, args ...string) error {
@@ -71,6 +187,10 @@ func btype(x *xEnv, args ...string) error {
 			x.Printf("%s: builtin\n", a)
 			found = true
 		}
+		if cmd.Applet(a) != nil {
+			x.Printf("%s: applet\n", a)
+			found = true
+		}
 		if p := cmd.LookPath(a); p != "" {
 			x.Printf("%s: %s\n", a, p)
 		} else if !found {
@@ -159,14 +279,35 @@ func bfork(x *xEnv, args ...string) error {
 	return nil
 }
 
+// wait [job...]: wait for the jobs given and set the
+// status to that of the last one.
+// With no jobs, wait for all of them and set the status to that of
+// the last one that failed.
 func bwait(x *xEnv, args ...string) error {
 	if len(args) == 1 {
-		args = append(args, "")
+		bgcmds.wait("")
+		sts := ""
+		for _, j := range bgcmds.reap() {
+			if !j.isDone() {
+				continue
+			}
+			if s := j.wait(); s != "" {
+				sts = s
+			}
+		}
+		cmd.SetEnv("sts", sts)
+		return nil
 	}
+	sts := ""
 	for _, a := range args[1:] {
-		bgcmds.wait(a)
+		if j := bgcmds.lookup(a); j != nil {
+			sts = j.wait()
+			bgcmds.forget(j)
+		} else {
+			bgcmds.wait(a)
+		}
 	}
-	cmd.SetEnv("sts", "")
+	cmd.SetEnv("sts", sts)
 	return nil
 }
 
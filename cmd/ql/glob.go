@@ -0,0 +1,113 @@
+package main
+
+import (
+	"clive/cmd"
+	"clive/zx"
+	"fmt"
+	fpath "path"
+	"strings"
+)
+
+func hasMeta(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// Does the names node contain an unquoted name with glob meta chars?
+func (nd *Nd) globs() bool {
+	switch nd.typ {
+	case Nname:
+		return !nd.quoted && hasMeta(strings.SplitN(nd.Args[0], ",", 2)[0])
+	case Napp, Nnames:
+		for _, c := range nd.Child {
+			if c.globs() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Quote s to be used as a value in a predicate.
+// Predicates have no escapes, so s can't have both quote characters.
+func predQuote(s string) (string, bool) {
+	switch {
+	case !strings.ContainsRune(s, '"'):
+		return `"` + s + `"`, true
+	case !strings.ContainsRune(s, '\''):
+		return `'` + s + `'`, true
+	}
+	return "", false
+}
+
+// Expand a glob pattern, "path,pred", against the name space.
+// The path may have glob meta chars in any element, the predicate
+// (as used by cmd.Dirs, with ',' meaning '&') selects among
+// the matching entries.
+// The names returned mimic the pattern as given (relative or not).
+// If there are no matches, including when the directory does not
+// exist, the pattern is returned as is.
+func glob(name string) ([]string, error) {
+	if len(name) == 0 || name[0] == '|' || name[0] == '-' {
+		return []string{name}, nil
+	}
+	toks := strings.SplitN(name, ",", 2)
+	if !hasMeta(toks[0]) {
+		return []string{name}, nil
+	}
+	// split the pattern at the dir before the first meta char
+	pat := toks[0]
+	udir := ""
+	if i := strings.IndexAny(pat, "*?["); i > 0 {
+		if j := strings.LastIndex(pat[:i], "/"); j >= 0 {
+			udir, pat = pat[:j], pat[j+1:]
+			if udir == "" {
+				udir = "/"
+			}
+		}
+	}
+	dir := "."
+	if udir != "" {
+		dir = udir
+	}
+	dir = cmd.AbsPath(dir)
+	qpat, ok := predQuote(fpath.Join(dir, pat))
+	if !ok {
+		return nil, fmt.Errorf("%s: can't use both ' and \" in patterns", name)
+	}
+	p := "path~" + qpat
+	if len(toks) > 1 && toks[1] != "" {
+		p += "&(" + strings.Replace(toks[1], ",", "&", -1) + ")"
+	}
+	var names []string
+	dc := cmd.NS().Find(dir, p, "/", "/", 0)
+	for d := range dc {
+		if d["err"] != "" {
+			continue
+		}
+		rel := strings.TrimPrefix(zx.Suffix(d["path"], dir), "/")
+		if udir != "" {
+			rel = fpath.Join(udir, rel)
+		}
+		names = append(names, rel)
+	}
+	if err := cerror(dc); err != nil && !zx.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	if len(names) == 0 {
+		return []string{name}, nil
+	}
+	return names, nil
+}
+
+// Expand the glob patterns in names.
+func globAll(names []string) ([]string, error) {
+	var xs []string
+	for _, n := range names {
+		gs, err := glob(n)
+		if err != nil {
+			return nil, err
+		}
+		xs = append(xs, gs...)
+	}
+	return xs, nil
+}
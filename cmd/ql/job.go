@@ -0,0 +1,372 @@
+package main
+
+import (
+	"clive/cmd"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// A job is a pipe run by ql.
+// Background jobs are kept in bgcmds until their status is reported.
+// Unix processes started by background jobs are placed in their own
+// process group, so that interrupts from the terminal reach only
+// the foreground job.
+struct job {
+	sync.Mutex
+	id    int    // for bg jobs, 0 for fg jobs
+	tag   string // bg tag, "" for fg jobs
+	what  string // command line (approx.)
+	procs map[*os.Process]bool
+	apps  map[*cmd.Ctx]bool // applets running
+	done  bool
+	sts   string
+	donec chan bool
+}
+
+// Number of jobs done kept for wait when not interactive.
+const nKeptJobs = 32
+
+var sigs = map[string]os.Signal{
+	"int":  syscall.SIGINT,
+	"term": syscall.SIGTERM,
+	"kill": syscall.SIGKILL,
+	"hup":  syscall.SIGHUP,
+}
+
+func init() {
+	builtins["jobs"] = bjobs
+	builtins["kill"] = bkill
+	builtins["fg"] = bfg
+}
+
+func (b *bgCmds) newJob(nd *Nd, tag string) *job {
+	j := &job{
+		tag:   tag,
+		what:  nd.cmdText(),
+		procs: map[*os.Process]bool{},
+		apps:  map[*cmd.Ctx]bool{},
+		donec: make(chan bool),
+	}
+	if tag == "" {
+		return j
+	}
+	b.Lock()
+	defer b.Unlock()
+	b.lastid++
+	j.id = b.lastid
+	b.jobs[j.id] = j
+	return j
+}
+
+// Make j the foreground job and return the previous one.
+func (b *bgCmds) setFg(j *job) *job {
+	b.Lock()
+	defer b.Unlock()
+	old := b.fg
+	b.fg = j
+	return old
+}
+
+// Forward an interrupt to the foreground job.
+// Unix processes started in the foreground got it from the terminal,
+// but applets run by ql did not.
+func (b *bgCmds) intr() {
+	b.Lock()
+	j := b.fg
+	b.Unlock()
+	switch {
+	case j == nil:
+	case j.tag != "":
+		cmd.Dprintf("intr job %d\n", j.id)
+		j.kill(os.Interrupt)
+	default:
+		j.intrApps()
+	}
+}
+
+// Lookup a job by number (perhaps as %n) or tag.
+// If several jobs have the tag, the most recent one is used.
+// The empty name means the last job still running.
+func (b *bgCmds) lookup(name string) *job {
+	b.Lock()
+	defer b.Unlock()
+	if name == "" {
+		var last *job
+		for _, j := range b.jobs {
+			if !j.isDone() && (last == nil || j.id > last.id) {
+				last = j
+			}
+		}
+		return last
+	}
+	if n, err := strconv.Atoi(strings.TrimPrefix(name, "%")); err == nil {
+		return b.jobs[n]
+	}
+	var last *job
+	for _, j := range b.jobs {
+		if j.tag == name && (last == nil || j.id > last.id) {
+			last = j
+		}
+	}
+	return last
+}
+
+// Return the jobs sorted by id, removing those that are done.
+func (b *bgCmds) reap() []*job {
+	b.Lock()
+	defer b.Unlock()
+	js := []*job{}
+	for id, j := range b.jobs {
+		js = append(js, j)
+		if j.isDone() {
+			delete(b.jobs, id)
+		}
+	}
+	sort.Sort(byId(js))
+	return js
+}
+
+// Forget the jobs that are done but for the last nKeptJobs ones.
+// Used when not interactive, where nobody reports the jobs done but
+// the script may still wait for them.
+func (b *bgCmds) forgetOld() {
+	b.Lock()
+	defer b.Unlock()
+	js := []*job{}
+	for _, j := range b.jobs {
+		if j.isDone() {
+			js = append(js, j)
+		}
+	}
+	if len(js) <= nKeptJobs {
+		return
+	}
+	sort.Sort(byId(js))
+	for _, j := range js[:len(js)-nKeptJobs] {
+		delete(b.jobs, j.id)
+	}
+}
+
+func (b *bgCmds) forget(j *job) {
+	b.Lock()
+	defer b.Unlock()
+	delete(b.jobs, j.id)
+}
+
+// Report jobs that are done since the last time
+func (b *bgCmds) notify() {
+	for _, j := range b.reap() {
+		if j.isDone() {
+			cmd.Eprintf("%s\n", j)
+		} else {
+			b.Lock()
+			b.jobs[j.id] = j
+			b.Unlock()
+		}
+	}
+}
+
+type byId []*job
+
+func (b byId) Len() int           { return len(b) }
+func (b byId) Less(i, j int) bool { return b[i].id < b[j].id }
+func (b byId) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+func (j *job) String() string {
+	j.Lock()
+	defer j.Unlock()
+	st := "running"
+	switch {
+	case j.done && j.sts != "":
+		st = "failed: " + j.sts
+	case j.done:
+		st = "done"
+	}
+	tag := ""
+	if j.tag != "&" {
+		tag = " &" + j.tag
+	}
+	return fmt.Sprintf("[%d]%s\t%s\t%s", j.id, tag, st, j.what)
+}
+
+func (j *job) add(p *os.Process) {
+	if j != nil {
+		j.Lock()
+		j.procs[p] = true
+		j.Unlock()
+	}
+}
+
+func (j *job) del(p *os.Process) {
+	if j != nil {
+		j.Lock()
+		delete(j.procs, p)
+		j.Unlock()
+	}
+}
+
+func (j *job) addApp(c *cmd.Ctx) {
+	if j != nil {
+		j.Lock()
+		j.apps[c] = true
+		j.Unlock()
+	}
+}
+
+func (j *job) delApp(c *cmd.Ctx) {
+	if j != nil {
+		j.Lock()
+		delete(j.apps, c)
+		j.Unlock()
+	}
+}
+
+func (j *job) intrApps() {
+	j.Lock()
+	defer j.Unlock()
+	for c := range j.apps {
+		c.Intr()
+	}
+}
+
+func (j *job) finish(sts string) {
+	j.Lock()
+	defer j.Unlock()
+	if !j.done {
+		j.done = true
+		j.sts = sts
+		close(j.donec)
+	}
+}
+
+func (j *job) isDone() bool {
+	j.Lock()
+	defer j.Unlock()
+	return j.done
+}
+
+// Post the signal to the unix processes of the job.
+// Applets run by ql are interrupted instead (see cmd.Ctx.Intr),
+// and builtins and functions run by ql can't be signaled.
+func (j *job) kill(sig os.Signal) error {
+	j.Lock()
+	defer j.Unlock()
+	if j.done {
+		return errors.New("job is done")
+	}
+	for c := range j.apps {
+		c.Intr()
+	}
+	var err error
+	for p := range j.procs {
+		var perr error
+		if s, ok := sig.(syscall.Signal); ok && j.tag != "" {
+			perr = syscall.Kill(-p.Pid, s) // its process group
+		} else {
+			perr = p.Signal(sig)
+		}
+		if err == nil {
+			err = perr
+		}
+	}
+	return err
+}
+
+// Wait for the job and return its status.
+func (j *job) wait() string {
+	<-j.donec
+	j.Lock()
+	defer j.Unlock()
+	return j.sts
+}
+
+// Propagate interrupts to the foreground job.
+func intrs() {
+	for range intrc {
+		bgcmds.intr()
+	}
+}
+
+// jobs: list bg jobs, forgetting those that are done.
+func bjobs(x *xEnv, args ...string) error {
+	if len(args) > 1 {
+		err := errors.New("usage: jobs")
+		x.Eprintf("jobs: %s\n", err)
+		cmd.SetEnv("sts", err.Error())
+		return nil
+	}
+	for _, j := range bgcmds.reap() {
+		x.Printf("%s\n", j)
+	}
+	cmd.SetEnv("sts", "")
+	return nil
+}
+
+// kill [-int|-term|-kill|-hup] job...
+func bkill(x *xEnv, args ...string) error {
+	args = args[1:]
+	sig := sigs["term"]
+	ok := true
+	if len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		sig, ok = sigs[args[0][1:]]
+		args = args[1:]
+	}
+	var err error
+	if !ok || len(args) == 0 {
+		err = errors.New("usage: kill [-int|-term|-kill|-hup] job...")
+	}
+	for _, a := range args {
+		j := bgcmds.lookup(a)
+		if j == nil {
+			err = fmt.Errorf("%s: no such job", a)
+		} else if kerr := j.kill(sig); kerr != nil {
+			err = fmt.Errorf("%s: %s", a, kerr)
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err != nil {
+		x.Eprintf("kill: %s\n", err)
+		cmd.SetEnv("sts", err.Error())
+	} else {
+		cmd.SetEnv("sts", "")
+	}
+	return nil
+}
+
+// fg [job]: wait for the job with interrupts going to it.
+func bfg(x *xEnv, args ...string) error {
+	var err error
+	var j *job
+	switch len(args) {
+	case 1, 2:
+		name := ""
+		if len(args) == 2 {
+			name = args[1]
+		}
+		if j = bgcmds.lookup(name); j == nil {
+			err = errors.New("no such job")
+		}
+	default:
+		err = errors.New("usage: fg [job]")
+	}
+	if err != nil {
+		x.Eprintf("fg: %s\n", err)
+		cmd.SetEnv("sts", err.Error())
+		return nil
+	}
+	x.Printf("%s\n", j.what)
+	old := bgcmds.setFg(j)
+	sts := j.wait()
+	bgcmds.setFg(old)
+	bgcmds.forget(j)
+	cmd.SetEnv("sts", sts)
+	return nil
+}
@@ -45,11 +45,12 @@ struct lex {
 var (
 	ErrIntr  = errors.New("interrupted")
 	keywords = map[string]int{
-		"for":   FOR,
-		"while": WHILE,
-		"fn":    FUNC,
-		"cond":  COND,
-		"or":    OR,
+		"for":    FOR,
+		"while":  WHILE,
+		"fn":     FUNC,
+		"cond":   COND,
+		"or":     OR,
+		"switch": SWITCH,
 	}
 )
 
@@ -161,6 +162,7 @@ func (l *lex) getval() string {
 }
 
 func (l *lex) Lex(lval *yySymType) int {
+	lval.bval = false // set for quoted names
 	t := l.lex(lval)
 	l.Dprintf("%s: tok %s\n", l.Addr, tokstr(t, lval))
 	return t
@@ -281,6 +283,8 @@ func (l *lex) lex(lval *yySymType) int {
 		return IREDIR
 	case '>':
 		switch c = l.get(); c {
+		case '&':
+			return l.scanDup(lval, "out")
 		case '>':
 			if c2 := l.get(); c2 == '[' {
 				l.scanQuote(']', lval, "[")
@@ -311,6 +315,7 @@ func (l *lex) scanQuote(q rune, lval *yySymType, what string) int {
 		if c == q {
 			l.val = l.val[:len(l.val)-1]
 			lval.sval = l.getval()
+			lval.bval = true
 			return NAME
 		}
 		if c == 0 {
@@ -331,9 +336,69 @@ func isPunct(c rune) bool {
 	return unicode.IsSpace(c) || strings.ContainsRune("\\'←`<>{}&;[]|#^()", c)
 }
 
+// Unix fd numbers used in redirections name the standard chans.
+func fdName(fd string) string {
+	switch fd {
+	case "0":
+		return "in"
+	case "1":
+		return "out"
+	case "2":
+		return "err"
+	}
+	return fd
+}
+
+// Only the fds for the standard chans may be used as in 2>file;
+// other words, like 10 in "echo 10>x", are just words.
+func isFd(s string) bool {
+	return s == "0" || s == "1" || s == "2"
+}
+
+// After >& in a redirection for the named chan, scan the fd it dups.
+func (l *lex) scanDup(lval *yySymType, cname string) int {
+	l.val = l.val[:0]
+	for {
+		c := l.get()
+		if c < '0' || c > '9' {
+			l.unget()
+			break
+		}
+	}
+	fd := l.getval()
+	if fd == "" {
+		l.Errs("missing fd in >& redirection")
+		panic(parseErr)
+	}
+	lval.sval = cname + "=" + fdName(fd)
+	return OREDIR
+}
+
+// Scan n>, n>>, or n>&m redirections (n is 0, 1, or 2), after n and the >.
+func (l *lex) scanFdRedir(lval *yySymType, fd string) int {
+	cname := fdName(fd)
+	switch c := l.get(); c {
+	case '&':
+		return l.scanDup(lval, cname)
+	case '>':
+		lval.sval = cname
+		return APP
+	default:
+		l.unget()
+	}
+	lval.sval = cname
+	return OREDIR
+}
+
 func (l *lex) scanName(lval *yySymType) int {
 	for {
 		c := l.get()
+		if c == '>' && isFd(string(l.val[:len(l.val)-1])) {
+			// 2>file, 2>>file, 2>&1
+			fd := string(l.val[:len(l.val)-1])
+			l.notfirst = true
+			return l.scanFdRedir(lval, fd)
+		}
 		if isPunct(c) || !l.notfirst && c == '=' {
 			l.unget()
 			lval.sval = l.getval()
@@ -366,6 +431,8 @@ func tokstr(tok int, lval *yySymType) string {
 		return "while"
 	case FUNC:
 		return "fn"
+	case SWITCH:
+		return "switch"
 	case NL:
 		return "nl"
 	case NAME:
@@ -18,7 +18,7 @@ type NdType int
 // we use (...) to mean args and {...} to mean children nodes
 // In short:
 //	toplevel -> pipe | src | func
-//	pipe chilren are: cmd, blk, for, while, cond, set
+//	pipe chilren are: cmd, blk, for, while, cond, switch, set
 //
 // Nname[NAME]			x
 // Nval[NAME]			$x
@@ -47,6 +47,8 @@ type NdType int
 // Nfunc[NAME]{pipe...}		func a { ... }
 // Ncond{or..., redirs}			cond { ... } or {... } ... or {...}
 // Nor{pipe...}
+// Nswitch{names, case..., redirs}		switch $x { a* { ... } ... }
+// Ncase{names, block}			a* b* { ... }
 // Nsrc{name}			source, < name
 const (
 	Nnone NdType = iota
@@ -69,6 +71,8 @@ const (
 	Nor
 	Nioblk
 	Nsrc
+	Nswitch
+	Ncase
 )
 
 struct NdAddr {
@@ -87,6 +91,7 @@ struct Nd {
 	Child []*Nd
 	NdAddr
 	Redirs []*Redir
+	quoted bool // for names, not to be globbed
 }
 
 func newNd(typ NdType, args ...string) *Nd {
@@ -153,6 +158,10 @@ func (t NdType) String() string {
 		return "ioblk"
 	case Nsrc:
 		return "source"
+	case Nswitch:
+		return "switch"
+	case Ncase:
+		return "case"
 	default:
 		return fmt.Sprintf("BADTYPE<%d>", t)
 	}
@@ -166,6 +175,50 @@ func (n *Nd) String() string {
 	return fmt.Sprintf("%s", n.typ)
 }
 
+// Return an approximate command line for the node, for job listings.
+func (n *Nd) cmdText() string {
+	if n == nil {
+		return ""
+	}
+	txts := func(nds []*Nd, sep string) string {
+		ts := []string{}
+		for _, c := range nds {
+			if t := c.cmdText(); t != "" {
+				ts = append(ts, t)
+			}
+		}
+		return strings.Join(ts, sep)
+	}
+	switch n.typ {
+	case Nname:
+		return strings.Join(n.Args, " ")
+	case Nval:
+		return "$" + n.Args[0]
+	case Nsingle:
+		return "$^" + n.Args[0]
+	case Nlen:
+		return "$#" + n.Args[0]
+	case Napp:
+		return txts(n.Child, "^")
+	case Nnames, Ncmd:
+		return txts(n.Child, " ")
+	case Npipe:
+		s := txts(n.Child, " | ")
+		if bg := n.Args[0]; bg == "&" {
+			s += " &"
+		} else if bg != "" {
+			s += " &" + bg
+		}
+		return s
+	case Nblock, Nioblk:
+		return "{" + txts(n.Child, "; ") + "}"
+	case Nset, Nsetmap:
+		return n.Args[0] + " = ..."
+	default:
+		return n.typ.String() + " ..."
+	}
+}
+
 // debug
 func (n *Nd) writeTo(w io.Writer, lvl int) {
 	pref := strings.Repeat("    ", lvl)
@@ -4,13 +4,13 @@
 	other toks: = { } ; [ ] ^ = $ ( )
 */
 
-%token FOR WHILE FUNC NL OR AND LEN SINGLE ERROR COND OR
+%token FOR WHILE FUNC NL OR AND LEN SINGLE ERROR COND OR SWITCH
 
 %token <sval> PIPE IREDIR OREDIR BG APP NAME INBLK OUTBLK
 
 %type <nd> name names cmd optnames list nameel mapels
 %type <nd> bgpipe pipe cmd redir spipe
-%type <nd> blkcmds func cond setvar optname
+%type <nd> blkcmds func cond setvar optname switch cases case
 %type <sval> optbg
 %type <bval> optin
 %type <redirs> redirs optredirs
@@ -156,6 +156,11 @@ cmd
 		$$ = $1
 		$1.Redirs = $2
 	}
+	| switch optredirs
+	{
+		$$ = $1
+		$1.Redirs = $2
+	}
 	| setvar
 	;
 
@@ -193,6 +198,32 @@ cond
 		$$ = $1.Add(nd)
 	}
 	;
+switch
+	: SWITCH names '{' optsep cases optsep '}'
+	{
+		$$ = $5
+		$$.Child = append([]*Nd{$2}, $$.Child...)
+	}
+	;
+
+cases
+	: cases sep case
+	{
+		$$ = $1.Add($3)
+	}
+	| case
+	{
+		$$ = newList(Nswitch, $1)
+	}
+	;
+
+case
+	: names '{' optsep blkcmds optsep '}'
+	{
+		$$ = newList(Ncase, $1, $4)
+	}
+	;
+
 blkcmds
 	: blkcmds sep bgpipe
 	{
@@ -342,6 +373,7 @@ name
 	: NAME
 	{
 		$$ = newNd(Nname, $1)
+		$$.quoted = $<bval>1
 	}
 	| '$' NAME
 	{
@@ -7,6 +7,7 @@ package main
 
 import (
 	"clive/cmd"
+	_ "clive/cmd/applets"
 	"clive/cmd/opt"
 	"clive/cmd/tty"
 	"clive/zx"
@@ -154,11 +155,18 @@ func main() {
 	cmd.SetEnv("argv0", c.Args[0])
 	cmd.SetEnvList("argv", c.Args[1:])
 	dotql()
-	in := &inRdr{name: "in", inc: cmd.In("in")}
-	yylex = newLex(in)
+	if iflag && !cflag && len(args) == 0 && tty.IsTTY(os.Stdin) {
+		in := newEdRdr("> ")
+		yylex = newLex(in)
+		yylex.prompt = "" // the editor prompts
+	} else {
+		in := &inRdr{name: "in", inc: cmd.In("in")}
+		yylex = newLex(in)
+	}
 	yylex.interactive = iflag
 	if iflag {
 		intrc = cmd.HandleIntr()
+		go intrs()
 	} else {
 		intrc = make(chan os.Signal)
 	}
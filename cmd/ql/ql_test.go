@@ -4,6 +4,7 @@ import (
 	"clive/cmd"
 	"clive/cmd/test"
 	"clive/dbg"
+	"clive/zx"
 	"testing"
 )
 
@@ -127,6 +128,38 @@ a b c
 			Line: `{lf -u 1 ; lf -u fdsafdsfa } >[out,err]/tmp/errs ; cat /tmp/errs`,
 			Out: `- rw-r--r--      0 /tmp/cmdtest/1
 lf: stat /tmp/cmdtest/fdsafdsfa: no such file or directory
+`,
+		},
+		test.Run{
+			Line: `lf -u fdsafdsfa >[err=out] | wc -l`,
+			Out: `       1
+`,
+		},
+		test.Run{
+			Line: `{echo a ; lf -u fdsafdsfa} 2>&1 | wc -l`,
+			Out: `       2
+`,
+		},
+		test.Run{
+			Line: `lf -u fdsafdsfa >/tmp/errs 2>&1 ; cat /tmp/errs`,
+			Out: `lf: stat /tmp/cmdtest/fdsafdsfa: no such file or directory
+`,
+		},
+		test.Run{
+			Line: `lf -u fdsafdsfa 2>&1 >/tmp/errs ; cnt -lu /tmp/errs`,
+			Out: `lf: stat /tmp/cmdtest/fdsafdsfa: no such file or directory
+       0  /tmp/errs
+`,
+		},
+		test.Run{
+			Line: `echo 10>/tmp/errs ; cat /tmp/errs`,
+			Out: `10
+`,
+		},
+		test.Run{
+			Line: `{echo a ; echo b >&2} 2>/tmp/errs ; cat /tmp/errs`,
+			Out: `a
+b
 `,
 		},
 		test.Run{
@@ -215,6 +248,73 @@ c ---------      0 |<in3
 			Line: `fn f { echo x $argv0 $#argv $argv y } ; f a b c ; f c d e `,
 			Out: `x f 3 a b c y
 x f 3 c d e y
+`,
+		},
+		test.Run{
+			Line: `fn f { echo $1 $2 $3 ; f2 x } ; fn f2 { echo $1 $2 $argv } ; f a b ; echo $1 $argv`,
+			Out: `a b
+x x
+-c fn f { echo $1 $2 $3 ; f2 x } ; fn f2 { echo $1 $2 $argv } ; f a b ; echo $1 $argv
+`,
+		},
+		test.Run{
+			Line: `fn f { local y z ; y = 2 ; echo $y $z } ; y = 1 ; z = 3 ; f ; echo $y $z`,
+			Out: `2
+1 3
+`,
+		},
+		test.Run{
+			Line: `local y >[err] /dev/null ; echo $sts`,
+			Out: `not in a function
+`,
+		},
+		test.Run{
+			Line: `x=ab.c ; switch $x { *.go { echo go } a*.c b* { echo c $match } * { echo other } }`,
+			Out: `c ab.c
+`,
+		},
+		test.Run{
+			Line: `switch /a/b.c { *.go { echo go } /a/*.c { echo c $match } }`,
+			Out: `c /a/b.c
+`,
+		},
+		test.Run{
+			Line: `switch a/b/c { a?b { echo ab } *x { echo x } * { echo other $match } }`,
+			Out: `other a/b/c
+`,
+		},
+		test.Run{
+			Line: "switch k=v { `~^([a-z]+)=(.*)$` { echo $match[1] is $match[2] } }",
+			Out: `k is v
+`,
+		},
+		test.Run{
+			Line: `switch x { a { echo a } } ; echo $sts`,
+			Out: `no match
+`,
+		},
+		test.Run{
+			Line: `echo a/a* ; echo /tmp/cmdtest/*/b`,
+			Out: `a/a1 a/a2
+/tmp/cmdtest/a/b
+`,
+		},
+		test.Run{
+			Line: `echo nodir/*.go a/zz"*`,
+			Out: `nodir/*.go a/zz"*
+`,
+		},
+		test.Run{
+			Line: `echo a/*,type=d ; echo [12] a/b/*/c?`,
+			Out: `a/b
+1 2 a/b/c/c3
+`,
+		},
+		test.Run{
+			Line: `x = a/* ; echo $#x ; echo 'a/*' a/zz* ; switch a1 { a* { echo $match } }`,
+			Out: `3
+a/* a/zz*
+a1
 `,
 		},
 		test.Run{
@@ -245,3 +345,60 @@ func TestPath(t *testing.T) {
 		t.Fatalf("sh is %q\n", p)
 	}
 }
+
+func TestJobs(t *testing.T) {
+	b := &bgCmds{jobs: map[int]*job{}}
+	for i := 1; i <= nKeptJobs+10; i++ {
+		j := &job{id: i, tag: "x", donec: make(chan bool)}
+		if i%2 == 0 {
+			j.tag = "y"
+		}
+		if i < nKeptJobs+5 {
+			j.finish("")
+		}
+		b.jobs[i] = j
+	}
+	if j := b.lookup("x"); j == nil || j.id != nKeptJobs+9 {
+		t.Fatalf("lookup x gave %v", j)
+	}
+	if j := b.lookup("%3"); j == nil || j.id != 3 {
+		t.Fatalf("lookup %%3 gave %v", j)
+	}
+	b.forgetOld()
+	if len(b.jobs) != nKeptJobs+6 {
+		t.Fatalf("%d jobs kept", len(b.jobs))
+	}
+	if b.jobs[4] != nil || b.jobs[5] == nil {
+		t.Fatalf("wrong jobs forgotten")
+	}
+}
+
+func TestAppletPipe(t *testing.T) {
+	d0 := zx.Dir{"name": "x", "type": "-"}
+	var got face{}
+	cmd.DefApplet(func() {
+		cmd.Out("out") <- d0
+	}, "tdirout")
+	cmd.DefApplet(func() {
+		for m := range cmd.In("in") {
+			got = m
+		}
+	}, "tdirin")
+	inc := make(chan face{}, 2)
+	inc <- zx.Dir{"path": "-c", "Upath": "-c", "type": "c"}
+	inc <- []byte("tdirout | tdirin\n")
+	close(inc)
+	yylex = newLex(&inRdr{name: "in", inc: inc})
+	if err := parse(); err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	d, ok := got.(zx.Dir)
+	if !ok {
+		t.Fatalf("got %T", got)
+	}
+	// it's the same dir, not a copy made by reading a unix pipe
+	d["seen"] = "yes"
+	if d0["seen"] != "yes" {
+		t.Fatalf("dir was copied")
+	}
+}
@@ -118,8 +118,16 @@ func (nd *Nd) addPipeRedirs(stdin bool) {
 // tag can be "" or "in", "out", "in,out,foo,..."
 // nd is the target of the redir
 // name can be nil for >, in which case it's a dup.
+// Dups are >[x:y] or >[x=y] (also 2>&1), to make x go where y goes.
 func newRedir(what, tag string, name *Nd) *Nd {
 	tag = strings.TrimSpace(tag)
+	if flds := fields(tag, "="); len(flds) == 2 && what == ">" {
+		if name != nil {
+			yylex.Errs("dup redirection '%s' followed by a name", tag)
+			panic(parseErr)
+		}
+		tag = fdName(flds[0]) + ":" + fdName(flds[1])
+	}
 	if what == ">" && name == nil {
 		what = ">:"
 	}
@@ -246,24 +254,53 @@ func outTo(path string, app bool) (*os.File, chan bool, error) {
 	return wr, dc, nil
 }
 
+func isPipeRedir(rd *Redir) bool {
+	return rd.nd != nil && (rd.nd.Args[0] == "<|" || rd.nd.Args[0] == ">|")
+}
+
 // Called for each pipe child to apply its redirs, including those for the pipeline
+// Those for the pipeline are applied first, and then the rest in order,
+// so that a >f >[err=out] sends both out and err to f, but a >[err=out] >f
+// sends err to where out was going before sending out to f.
 func (c *Nd) applyRedirs(x, cx *xEnv, pipes map[string]pFd) ([]io.Closer, error) {
 	var pcloses []io.Closer
+	rdrs := []*Redir{}
+	for _, rd := range c.Redirs {
+		if isPipeRedir(rd) {
+			rdrs = append(rdrs, rd)
+		}
+	}
 	for _, rd := range c.Redirs {
+		if !isPipeRedir(rd) {
+			rdrs = append(rdrs, rd)
+		}
+	}
+	applied := map[*Nd]bool{}
+	for _, rd := range rdrs {
 		r := rd.nd
+		if applied[r] {
+			continue // >[out,err] has a redir per chan, but all use the same file.
+		}
 		if r == nil { // dup
 			flds := fields(rd.name, ":")
 			nfd, ofd := flds[0], flds[1]
+			if nfd == ofd {
+				continue
+			}
 			xfd := cx.fds[ofd]
-			if xfd != nil {
-				xfd.ref++
+			if xfd == nil {
+				err := fmt.Errorf("dup: no chan '%s'", ofd)
+				cmd.Warn("redir: %s", err)
+				return pcloses, err
 			}
+			xfd.addref()
 			if fd, ok := cx.fds[nfd]; ok {
 				fd.Close()
 			}
 			cx.fds[nfd] = xfd
 			continue
 		}
+		applied[r] = true
 		paths, err := r.Child[0].expand1(x)
 		if err != nil {
 			cmd.Warn("expand: %s", err)
@@ -272,6 +309,7 @@ func (c *Nd) applyRedirs(x, cx *xEnv, pipes map[string]pFd) ([]io.Closer, error)
 		path := paths[0]
 		kind, tag := r.Args[0], r.Args[1]
 		var osfd *os.File
+		var xc chan face{}
 		var dc chan bool
 		cnames := fields(tag, ",")
 		switch kind {
@@ -311,16 +349,19 @@ func (c *Nd) applyRedirs(x, cx *xEnv, pipes map[string]pFd) ([]io.Closer, error)
 				pcloses = append(pcloses, p.r, p.w)
 				pipes[path] = p
 			}
-			if kind[0] == '>' {
+			switch {
+			case p.c != nil:
+				xc = p.c
+			case kind[0] == '>':
 				osfd = p.w
-			} else {
+			default:
 				osfd = p.r
 			}
 		default:
 			panic("bad kind")
 		}
 		isin := kind[0] == '<'
-		xfd := &xFd{fd: osfd, path: path, ref: 0, isIn: isin}
+		xfd := &xFd{fd: osfd, c: xc, path: path, ref: 0, isIn: isin}
 		for _, cname := range cnames {
 			xfd.ref++
 			if fd, ok := cx.fds[cname]; ok {
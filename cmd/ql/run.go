@@ -3,38 +3,47 @@ package main
 import (
 	"clive/ch"
 	"clive/cmd"
+	"clive/sre"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 )
 
 struct xFd {
 	sync.Mutex
 	ref  int
 	fd   *os.File
+	c    chan face{} // instead of fd, for pipes between applets
 	path string
 	isIn bool
 }
 
 struct pFd {
 	r, w *os.File
+	c    chan face{} // for pipes between applets
 }
 
 struct bgCmds {
 	sync.Mutex
-	cmds  map[*xEnv]bool
-	waits map[string]chan bool
-	wall  chan bool
+	cmds   map[*xEnv]bool
+	waits  map[string]chan bool
+	wall   chan bool
+	jobs   map[int]*job
+	lastid int
+	fg     *job // foreground job
 }
 
 // Execution environment for nodes.
-// We use unix processes to run clive commands, so for now we
-// use actual file descriptors for cmd IO.
+// We use unix processes to run most commands, so for now we
+// use actual file descriptors for cmd IO, but for pipes between
+// applets, which use chans.
 // The IO environment is named in clive, 0, 1, 2 are "in", "out", "err",
 // other names can be passed using environment variables that map
 // the name to the unix file descriptor.
@@ -44,12 +53,15 @@ struct xEnv {
 	bgtag string
 	isbg  bool // this cmd is a child of a bg command
 	xctx  *cmd.Ctx
+	job   *job
+	fn    *frame // call frame for the running function, if any
 }
 
 var bgcmds = bgCmds{
 	cmds:  map[*xEnv]bool{},
 	waits: map[string]chan bool{},
 	wall:  make(chan bool),
+	jobs:  map[int]*job{},
 }
 
 func (x *xEnv) Printf(fmts string, arg ...face{}) (int, error) {
@@ -83,7 +95,12 @@ func (xfd *xFd) Close() error {
 	if xfd.ref > 0 {
 		xfd.ref--
 		if xfd.ref == 0 {
-			xfd.fd.Close()
+			if xfd.fd != nil {
+				xfd.fd.Close()
+			}
+			if xfd.c != nil {
+				close(xfd.c)
+			}
 		}
 	}
 	xfd.Unlock()
@@ -206,6 +223,8 @@ func (x *xEnv) dup() *xEnv {
 	ne := &xEnv{
 		fds:  map[string]*xFd{},
 		isbg: x.isbg,
+		job:  x.job,
+		fn:   x.fn,
 	}
 	for k, f := range x.fds {
 		f.addref()
@@ -226,6 +245,16 @@ func (nd *Nd) run() error {
 		yylex.nerrors = 0
 		return nil
 	}
+	if yylex.interactive {
+		bgcmds.notify()
+		if ok, err := cmd.ReloadNS(); err != nil {
+			cmd.Warn("ns: %s", err)
+		} else if ok {
+			cmd.VWarn("ns reloaded")
+		}
+	} else {
+		bgcmds.forgetOld()
+	}
 	x := newEnv()
 	// bgpipe or func
 	var err error
@@ -302,6 +331,12 @@ func (nd *Nd) mkChildEnvs(x *xEnv) ([]*xEnv, error) {
 	nc := len(nd.Child)
 	cxs := make([]*xEnv, nc)
 	pipes := map[string]pFd{}
+	for i := 0; i < nc-1 && !dry; i++ {
+		// pipe i goes from child i to child i+1 (see addPipeRedirs)
+		if nd.Child[i].isApplet() && nd.Child[i+1].isApplet() {
+			pipes[fmt.Sprintf("|%d", i)] = pFd{c: make(chan face{})}
+		}
+	}
 	defer func() {
 		if err != nil {
 			for _, x := range pcloses {
@@ -348,8 +383,15 @@ func (x *xEnv) bg(tag string) {
 		wc := x.xctx.Waitc()
 		go func() {
 			<-wc
+			sts := ""
+			if err := cerror(wc); err != nil {
+				sts = err.Error()
+			}
+			x.job.finish(sts)
 			bgcmds.del(x)
 		}()
+	} else {
+		x.job.finish("")
 	}
 	cmd.SetEnv("sts", "")
 }
@@ -357,11 +399,17 @@ func (x *xEnv) bg(tag string) {
 // children may be cmd, block, for, while, cond, set
 func (nd *Nd) runPipe(x *xEnv) error {
 	nd.chk(Npipe)
+	bg := nd.Args[0]
+	if x.job == nil || bg != "" {
+		x.job = bgcmds.newJob(nd, bg)
+	}
 	cxs, err := nd.mkChildEnvs(x)
 	if err != nil {
+		if bg != "" {
+			x.job.finish(err.Error())
+		}
 		return err
 	}
-	bg := nd.Args[0]
 	for i, c := range nd.Child {
 		c := c
 		cx := cxs[i]
@@ -386,6 +434,8 @@ func (nd *Nd) runPipe(x *xEnv) error {
 				err = c.runWhile(cx)
 			case Ncond:
 				err = c.runCond(cx)
+			case Nswitch:
+				err = c.runSwitch(cx)
 			case Nset:
 				err = c.runSet(cx)
 			case Nsetmap:
@@ -403,15 +453,21 @@ func (nd *Nd) runPipe(x *xEnv) error {
 		})
 	}
 	if err != nil {
+		if bg != "" {
+			x.job.finish(err.Error())
+		}
 		return err
 	}
 	cx := cxs[len(nd.Child)-1]
 	if bg != "" {
 		cx.bg(bg)
-	} else {
-		if err := cx.wait(); isBreak(err) || isExit(err) {
-			return err
-		}
+		return nil
+	}
+	old := bgcmds.setFg(x.job)
+	err = cx.wait()
+	bgcmds.setFg(old)
+	if isBreak(err) || isExit(err) {
+		return err
 	}
 	return nil
 }
@@ -703,16 +759,24 @@ func (nd *Nd) expand1(x *xEnv) (nargs []string, err error) {
 }
 
 // expand names: children can be name, app, len, single, val, ioblnk
+// Unquoted names with glob patterns are expanded against the ns.
 func (nd *Nd) expand(x *xEnv) ([]string, error) {
+	return nd.expandNames(x, true)
+}
+
+func (nd *Nd) expandNames(x *xEnv, globs bool) ([]string, error) {
 	nd.chk(Nnames)
 	xs := []string{}
 	for _, c := range nd.Child {
 		var nargs []string
 		var err error
 		if c.typ == Nnames {
-			nargs, err = c.expand(x)
+			nargs, err = c.expandNames(x, globs)
 		} else {
 			nargs, err = c.expand1(x)
+			if err == nil && globs && !dry && c.globs() {
+				nargs, err = globAll(nargs)
+			}
 		}
 		if err != nil {
 			return nil, err
@@ -723,11 +787,28 @@ func (nd *Nd) expand(x *xEnv) ([]string, error) {
 	return xs, nil
 }
 
+// Run a function with the given args.
+// argv0, argv, and $1...$n are local to the call; other variables
+// can be made local using the local builtin.
 func (nd *Nd) eval(x *xEnv, argv ...string) error {
 	nd.chk(Nfunc)
-	cmd.SetEnv("argv0", argv[0])
-	e := cmd.ListEnv(argv[1:])
-	cmd.SetEnv("argv", e)
+	f := newFrame()
+	old := x.fn
+	x.fn = f
+	defer func() {
+		f.restore()
+		x.fn = old
+	}()
+	f.set("argv0", argv[0])
+	f.set("argv", cmd.ListEnv(argv[1:]))
+	// clear also $n args left by an outer call
+	for i := 1; i < len(argv) || cmd.GetEnv(strconv.Itoa(i)) != ""; i++ {
+		v := ""
+		if i < len(argv) {
+			v = argv[i]
+		}
+		f.set(strconv.Itoa(i), v)
+	}
 	return nd.Child[0].runBlock(x)
 }
 
@@ -772,6 +853,9 @@ func (nd *Nd) runCmd(x *xEnv) error {
 	if bfn := builtins[args[0]]; bfn != nil {
 		return bfn(x, args...)
 	}
+	if !strings.Contains(args[0], "/") && cmd.Applet(args[0]) != nil {
+		return x.runApplet(args...)
+	}
 	xc := exec.Command(args[0], args[1:]...)
 	xc.Dir = cmd.Dot()
 	xc.Env = cleanenv(cmd.OSEnv())
@@ -802,11 +886,14 @@ func (nd *Nd) runCmd(x *xEnv) error {
 	xc.Env = append(xc.Env, ev)
 	if x.isbg {
 		xc.Env = append(xc.Env, "clivebg=y")
+		xc.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 	}
 	if err := xc.Start(); err != nil {
 		cmd.Warn("%s", err)
 		return nil
 	}
+	x.job.add(xc.Process)
+	defer x.job.del(xc.Process)
 	if err := xc.Wait(); err != nil {
 		cmd.SetEnv("sts", err.Error())
 		return nil
@@ -816,6 +903,58 @@ func (nd *Nd) runCmd(x *xEnv) error {
 	return nil
 }
 
+// Is nd a command running an applet (see runApplet)?
+// Only literal command names are considered.
+func (nd *Nd) isApplet() bool {
+	if nd.typ != Ncmd || len(nd.Child) != 1 || len(nd.Child[0].Child) == 0 {
+		return false
+	}
+	c := nd.Child[0].Child[0]
+	if c.typ != Nname || len(c.Args) == 0 || c.globs() {
+		return false
+	}
+	name := c.Args[0]
+	return name != "builtin" && !strings.Contains(name, "/") &&
+		getFunc(name) == nil && builtins[name] == nil && cmd.Applet(name) != nil
+}
+
+// Run a clive command linked into ql (see cmd.DefApplet) within ql.
+// Pipes between applets are chans, and messages go through them
+// as they are.
+// Other IO chans use the unix files for the command, like they would
+// if it was run as a unix process, but its data is not copied
+// through another process.
+func (x *xEnv) runApplet(args ...string) error {
+	xc, err := cmd.RunApplet(func(c *cmd.Ctx) {
+		for cname, xfd := range x.fds {
+			switch {
+			case xfd.c != nil && xfd.isIn:
+				c.SetIn(cname, xfd.c)
+			case xfd.c != nil:
+				c.SetOut(cname, xfd.c)
+			case xfd.isIn:
+				c.SetUXIn(cname, xfd.fd)
+			default:
+				c.SetUXOut(cname, xfd.fd)
+			}
+		}
+	}, args...)
+	if err != nil {
+		cmd.Warn("%s", err)
+		return nil
+	}
+	x.job.addApp(xc)
+	defer x.job.delApp(xc)
+	wc := xc.Waitc()
+	<-wc
+	if err := cerror(wc); err != nil {
+		cmd.SetEnv("sts", err.Error())
+	} else {
+		cmd.SetEnv("sts", "")
+	}
+	return nil
+}
+
 // block cmds are pipes or sources, there're also io blocks
 func (nd *Nd) runBlock(x *xEnv) error {
 	nd.chk(Nblock, Nioblk)
@@ -982,6 +1121,75 @@ func (nd *Nd) runCond(x *xEnv) error {
 	return err
 }
 
+// Match a switch pattern against val.
+// Patterns starting with ~ are sres (unanchored), others are globs.
+// Unlike in file names, * and ? in switch globs match '/' too,
+// so "*" matches any value, including paths.
+// Return the whole match and the sre groups, or nil if no match.
+func matchPat(pat, val string) ([]string, error) {
+	if strings.HasPrefix(pat, "~") {
+		return sre.Match(pat[1:], val)
+	}
+	p, v := pat, val
+	if !strings.ContainsRune(p, 0) && !strings.ContainsRune(v, 0) {
+		// hide the '/'s from filepath.Match
+		p = strings.Replace(p, "/", "\x00", -1)
+		v = strings.Replace(v, "/", "\x00", -1)
+	}
+	m, err := filepath.Match(p, v)
+	if err != nil || !m {
+		return nil, err
+	}
+	return []string{val}, nil
+}
+
+// child 0 is the names for the value, the rest are Ncases with
+// the pattern names and the block to run.
+// The first case matching runs with $match set to the match
+// and its sre groups.
+func (nd *Nd) runSwitch(x *xEnv) error {
+	nd.chk(Nswitch)
+	if len(nd.Child) < 2 {
+		panic("bad switch children")
+	}
+	vals, err := nd.Child[0].expand(x)
+	if err != nil {
+		cmd.Warn("expand: %s", err)
+		return err
+	}
+	val := strings.Join(vals, " ")
+	for _, c := range nd.Child[1:] {
+		c.chk(Ncase)
+		pats, err := c.Child[0].expandNames(x, false)
+		if err != nil {
+			cmd.Warn("expand: %s", err)
+			return err
+		}
+		for _, p := range pats {
+			m, err := matchPat(p, val)
+			if err != nil {
+				cmd.Warn("switch: %s: %s", p, err)
+				cmd.SetEnv("sts", err.Error())
+				return nil
+			}
+			if m == nil {
+				continue
+			}
+			cmd.SetEnvList("match", m)
+			cmd.SetEnv("sts", "")
+			cx := x.dup()
+			defer cx.Close()
+			err = c.Child[1].runBlock(cx)
+			if isBreak(err) || isExit(err) {
+				return err
+			}
+			return nil
+		}
+	}
+	cmd.SetEnv("sts", "no match")
+	return nil
+}
+
 func (nd *Nd) runSet(x *xEnv) error {
 	nd.chk(Nset)
 	if len(nd.Args) == 0 {
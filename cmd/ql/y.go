@@ -23,14 +23,15 @@ const LEN = 57352
 const SINGLE = 57353
 const ERROR = 57354
 const COND = 57355
-const PIPE = 57356
-const IREDIR = 57357
-const OREDIR = 57358
-const BG = 57359
-const APP = 57360
-const NAME = 57361
-const INBLK = 57362
-const OUTBLK = 57363
+const SWITCH = 57356
+const PIPE = 57357
+const IREDIR = 57358
+const OREDIR = 57359
+const BG = 57360
+const APP = 57361
+const NAME = 57362
+const INBLK = 57363
+const OUTBLK = 57364
 
 var yyToknames = [...]string{
 	"$end",
@@ -46,6 +47,7 @@ var yyToknames = [...]string{
 	"SINGLE",
 	"ERROR",
 	"COND",
+	"SWITCH",
 	"PIPE",
 	"IREDIR",
 	"OREDIR",
@@ -72,7 +74,7 @@ const yyEofCode = 1
 const yyErrCode = 2
 const yyInitialStackSize = 16
 
-//line parse.y:367
+//line parse.y:399
 
 //line yacctab:1
 var yyExca = [...]int{
@@ -83,12 +85,13 @@ var yyExca = [...]int{
 	10, 16,
 	11, 16,
 	13, 16,
-	19, 16,
+	14, 16,
 	20, 16,
 	21, 16,
-	23, 16,
-	25, 16,
-	32, 16,
+	22, 16,
+	24, 16,
+	26, 16,
+	33, 16,
 	-2, 0,
 	-1, 1,
 	1, -1,
@@ -100,160 +103,172 @@ var yyExca = [...]int{
 	10, 16,
 	11, 16,
 	13, 16,
-	19, 16,
+	14, 16,
 	20, 16,
 	21, 16,
-	23, 16,
-	25, 16,
-	32, 16,
+	22, 16,
+	24, 16,
+	26, 16,
+	33, 16,
 	-2, 0,
-	-1, 103,
-	24, 47,
+	-1, 108,
+	25, 52,
 	-2, 16,
 }
 
-const yyNprod = 70
+const yyNprod = 75
 const yyPrivate = 57344
 
 var yyTokenNames []string
 var yyStates []string
 
-const yyLast = 275
+const yyLast = 281
 
 var yyAct = [...]int{
 
-	35, 48, 28, 83, 4, 62, 4, 55, 51, 38,
-	8, 114, 56, 6, 113, 6, 110, 16, 17, 21,
-	63, 11, 64, 65, 29, 31, 32, 64, 65, 39,
-	49, 25, 24, 73, 36, 59, 122, 123, 25, 24,
-	37, 41, 42, 58, 30, 12, 40, 22, 95, 70,
-	71, 72, 86, 23, 74, 139, 57, 138, 49, 77,
-	23, 137, 78, 80, 81, 69, 133, 128, 127, 87,
-	49, 82, 126, 90, 116, 85, 92, 93, 101, 61,
-	46, 67, 98, 99, 66, 52, 53, 88, 54, 49,
-	102, 60, 104, 105, 106, 103, 91, 94, 52, 53,
-	45, 54, 20, 44, 111, 112, 43, 117, 115, 103,
-	103, 26, 14, 103, 69, 121, 125, 47, 129, 76,
-	103, 14, 9, 18, 75, 49, 130, 131, 132, 2,
-	1, 103, 103, 103, 124, 49, 50, 49, 3, 141,
-	142, 15, 96, 97, 13, 19, 100, 79, 134, 34,
-	136, 33, 25, 24, 5, 27, 108, 52, 53, 107,
-	54, 22, 41, 42, 68, 0, 0, 40, 0, 0,
-	25, 24, 0, 0, 23, 0, 118, 119, 120, 22,
-	41, 42, 0, 0, 0, 40, 25, 24, 140, 0,
-	0, 0, 23, 0, 0, 22, 41, 42, 0, 0,
-	0, 40, 25, 24, 135, 25, 24, 0, 23, 0,
-	0, 22, 41, 42, 22, 41, 42, 40, 84, 109,
-	40, 25, 24, 0, 23, 0, 0, 23, 0, 0,
-	22, 41, 42, 0, 0, 0, 40, 25, 24, 0,
-	0, 0, 0, 23, 0, 7, 22, 41, 42, 10,
-	11, 0, 89, 0, 0, 0, 0, 14, 9, 23,
-	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 12,
+	57, 53, 129, 66, 40, 58, 6, 41, 6, 120,
+	16, 17, 87, 4, 21, 4, 28, 31, 32, 119,
+	36, 50, 11, 25, 24, 86, 37, 38, 67, 8,
+	68, 69, 116, 39, 43, 44, 77, 30, 76, 42,
+	71, 25, 24, 100, 74, 75, 23, 12, 160, 78,
+	51, 22, 43, 44, 81, 61, 63, 42, 152, 82,
+	84, 85, 60, 151, 23, 91, 68, 69, 131, 132,
+	150, 149, 95, 29, 142, 97, 98, 137, 96, 99,
+	51, 103, 104, 136, 135, 122, 51, 107, 90, 109,
+	110, 111, 108, 113, 51, 89, 106, 64, 48, 70,
+	101, 102, 117, 118, 105, 59, 121, 108, 108, 47,
+	46, 108, 65, 127, 51, 45, 73, 112, 108, 26,
+	134, 123, 20, 14, 9, 139, 140, 141, 14, 143,
+	108, 108, 108, 49, 144, 124, 125, 126, 54, 55,
+	93, 56, 80, 18, 138, 79, 154, 153, 7, 62,
+	2, 51, 10, 11, 51, 1, 52, 54, 55, 159,
+	56, 14, 9, 13, 108, 25, 24, 51, 73, 51,
+	3, 156, 157, 15, 19, 22, 43, 44, 12, 145,
+	158, 42, 25, 24, 25, 24, 128, 130, 23, 133,
+	54, 55, 22, 56, 22, 43, 44, 34, 83, 35,
+	42, 25, 24, 33, 5, 23, 146, 23, 148, 27,
+	114, 22, 43, 44, 72, 25, 24, 42, 130, 0,
+	155, 0, 0, 0, 23, 22, 43, 44, 0, 25,
+	24, 42, 25, 24, 147, 0, 0, 0, 23, 22,
+	43, 44, 22, 43, 44, 42, 92, 115, 42, 25,
+	24, 0, 23, 0, 0, 23, 0, 25, 24, 22,
+	43, 44, 0, 88, 0, 42, 0, 22, 43, 44,
+	0, 0, 23, 94, 0, 0, 0, 0, 0, 0,
+	23,
 }
 var yyPact = [...]int{
 
-	243, -1000, 243, -1000, 14, 14, -1000, 116, 85, 28,
-	92, -1000, -1000, 21, -1000, -1000, -1000, -1000, -1000, -1000,
-	-1000, -1000, -1000, 87, 84, 81, 57, 103, -1000, 142,
-	14, 211, 98, 83, -1000, -1000, 56, -7, 62, 59,
-	211, 14, 14, 24, 6, -1000, 14, 112, -1000, -1000,
-	70, -1000, 28, 28, 28, 107, -1000, 195, 52, -1000,
-	29, 14, 227, 28, -1000, -1000, 211, 211, 22, 211,
-	107, 107, 28, 28, 107, 21, -1000, -1000, -1000, -1000,
-	-1000, -1000, 14, -1000, 14, 14, 14, 107, 211, 192,
-	-12, -1000, -1000, -1000, -1000, -1000, 14, 14, -14, -17,
-	14, -1000, 50, 107, 107, 107, 107, 14, 10, 211,
-	-2, 48, 44, -1000, -1000, 43, 70, -1000, 14, 14,
-	14, 42, -1000, 211, 176, 211, -1000, -1000, -1000, -1000,
-	37, 33, 31, -1000, 160, -1000, 211, 70, 70, -1000,
-	-1000, -1000, -1000,
+	146, -1000, 146, -1000, 15, 15, -1000, 136, 104, 172,
+	99, -1000, -1000, 13, -1000, -1000, -1000, -1000, -1000, -1000,
+	-1000, -1000, -1000, 95, 90, 89, 74, 118, -1000, 174,
+	15, 31, 113, 141, 122, -1000, -1000, 73, 31, 0,
+	76, 17, 31, 15, 15, 10, 8, -1000, 15, 135,
+	-1000, -1000, 122, -1000, 172, 172, 172, 108, -1000, 239,
+	71, -1000, 64, -1000, 15, 222, 247, 172, -1000, -1000,
+	31, 31, 16, 31, 108, 108, 172, 172, 108, 13,
+	-1000, -1000, -1000, -1000, -1000, -1000, 15, -1000, 15, 15,
+	15, 108, 15, 31, 219, 3, -1000, -1000, -1000, -1000,
+	-1000, 15, 15, -10, -20, 15, -1000, 60, 108, 108,
+	108, 108, 15, 31, 41, 31, 36, 59, 58, -1000,
+	-1000, 52, 122, -1000, 15, 15, 15, 49, 15, -1000,
+	155, -1000, 31, 205, 31, -1000, -1000, -1000, -1000, 46,
+	45, 38, -1000, 33, 31, 15, 191, -1000, 31, 122,
+	122, -1000, -1000, -1000, 108, -1000, -1000, -1000, 15, 23,
+	-1000,
 }
 var yyPgo = [...]int{
 
-	0, 9, 24, 2, 164, 29, 0, 156, 3, 10,
-	8, 155, 71, 154, 151, 149, 147, 145, 144, 136,
-	1, 130, 129, 138, 12, 7, 124, 5,
+	0, 4, 73, 16, 214, 7, 20, 210, 12, 29,
+	1, 209, 25, 204, 203, 199, 198, 197, 186, 2,
+	174, 163, 156, 21, 155, 150, 170, 5, 0, 145,
+	3,
 }
 var yyR1 = [...]int{
 
-	0, 21, 21, 22, 22, 23, 23, 23, 23, 13,
-	8, 8, 17, 17, 9, 18, 18, 11, 11, 26,
-	26, 3, 3, 3, 3, 3, 3, 15, 15, 15,
-	27, 27, 14, 14, 12, 12, 20, 20, 19, 19,
-	10, 10, 10, 16, 16, 24, 24, 25, 25, 2,
-	2, 6, 6, 5, 5, 5, 5, 5, 5, 5,
-	7, 7, 4, 4, 1, 1, 1, 1, 1, 1,
+	0, 24, 24, 25, 25, 26, 26, 26, 26, 13,
+	8, 8, 20, 20, 9, 21, 21, 11, 11, 29,
+	29, 3, 3, 3, 3, 3, 3, 3, 15, 15,
+	15, 30, 30, 14, 14, 17, 18, 18, 19, 12,
+	12, 23, 23, 22, 22, 10, 10, 10, 16, 16,
+	27, 27, 28, 28, 2, 2, 6, 6, 5, 5,
+	5, 5, 5, 5, 5, 7, 7, 4, 4, 1,
+	1, 1, 1, 1, 1,
 }
 var yyR2 = [...]int{
 
 	0, 1, 0, 2, 1, 2, 2, 1, 2, 7,
 	2, 2, 1, 0, 2, 1, 0, 4, 1, 1,
-	0, 2, 6, 8, 8, 2, 1, 3, 5, 6,
-	1, 1, 6, 7, 3, 1, 1, 0, 2, 1,
-	2, 2, 2, 1, 0, 1, 1, 1, 0, 2,
-	1, 1, 1, 3, 3, 3, 3, 3, 5, 5,
-	4, 3, 1, 0, 1, 2, 2, 5, 5, 2,
+	0, 2, 6, 8, 8, 2, 2, 1, 3, 5,
+	6, 1, 1, 6, 7, 7, 3, 1, 6, 3,
+	1, 1, 0, 2, 1, 2, 2, 2, 1, 0,
+	1, 1, 1, 0, 2, 1, 1, 1, 3, 3,
+	3, 3, 3, 5, 5, 4, 3, 1, 0, 1,
+	2, 2, 5, 5, 2,
 }
 var yyChk = [...]int{
 
-	-1000, -21, -22, -23, -8, -13, -24, 2, -9, 15,
-	6, 7, 31, -18, 14, -23, -24, -24, 7, -17,
-	17, -1, 19, 32, 11, 10, 19, -11, -3, -2,
-	23, 4, 5, -14, -15, -6, 13, 19, -1, -5,
-	25, 20, 21, 19, 19, 19, 23, 14, -20, -6,
-	-19, -10, 15, 16, 18, -25, -24, -2, -9, -20,
-	8, 23, -27, 27, 29, 30, 22, 22, -4, -2,
-	-25, -25, 27, 27, -25, -26, 7, -10, -1, -16,
-	-1, -1, -12, -8, 23, 23, 23, -25, -2, 25,
-	-1, -5, -1, -1, -5, 26, -12, -12, -1, -1,
-	-12, -3, -25, -24, -25, -25, -25, -12, -7, 27,
-	28, -25, -25, 28, 28, -25, 24, -8, -12, -12,
-	-12, -25, 26, 27, -2, -27, 24, 24, 24, -20,
-	-25, -25, -25, 24, -2, 28, -2, 24, 24, 24,
-	28, -20, -20,
+	-1000, -24, -25, -26, -8, -13, -27, 2, -9, 16,
+	6, 7, 32, -21, 15, -26, -27, -27, 7, -20,
+	18, -1, 20, 33, 11, 10, 20, -11, -3, -2,
+	24, 4, 5, -14, -17, -15, -6, 13, 14, 20,
+	-1, -5, 26, 21, 22, 20, 20, 20, 24, 15,
+	-23, -6, -22, -10, 16, 17, 19, -28, -27, -2,
+	-9, -23, 8, -23, 24, -2, -30, 28, 30, 31,
+	23, 23, -4, -2, -28, -28, 28, 28, -28, -29,
+	7, -10, -1, -16, -1, -1, -12, -8, 24, 24,
+	24, -28, 24, -2, 26, -1, -5, -1, -1, -5,
+	27, -12, -12, -1, -1, -12, -3, -28, -27, -28,
+	-28, -28, -12, -28, -7, 28, 29, -28, -28, 29,
+	29, -28, 25, -8, -12, -12, -12, -28, -18, -19,
+	-2, 27, 28, -2, -30, 25, 25, 25, -23, -28,
+	-28, -28, 25, -28, -27, 24, -2, 29, -2, 25,
+	25, 25, 25, -19, -28, 29, -23, -23, -12, -28,
+	25,
 }
 var yyDef = [...]int{
 
 	-2, -2, -2, 4, 0, 0, 7, 0, 13, 0,
-	0, 45, 46, 0, 15, 3, 5, 6, 8, 10,
-	12, 11, 64, 0, 0, 0, 0, 14, 18, 37,
-	48, 0, 16, 37, 26, 50, 0, 64, 51, 52,
-	63, 48, 48, 65, 66, 69, 48, 20, 21, 49,
-	36, 39, 0, 44, 0, 16, 47, 0, 0, 25,
-	0, 48, 0, 0, 30, 31, 0, 0, 0, 62,
-	16, 16, 0, 0, 16, 0, 19, 38, 40, 41,
-	43, 42, 48, 35, 48, 48, 48, 16, 27, 63,
-	0, 54, 55, 56, 57, 53, 48, 48, 0, 0,
-	48, 17, 0, -2, 16, 16, 16, 48, 0, 0,
-	0, 0, 0, 67, 68, 0, 37, 34, 48, 48,
-	48, 0, 28, 0, 0, 0, 58, 59, 9, 22,
-	0, 0, 0, 32, 0, 61, 29, 37, 37, 33,
-	60, 23, 24,
+	0, 50, 51, 0, 15, 3, 5, 6, 8, 10,
+	12, 11, 69, 0, 0, 0, 0, 14, 18, 42,
+	53, 0, 16, 42, 42, 27, 55, 0, 0, 69,
+	56, 57, 68, 53, 53, 70, 71, 74, 53, 20,
+	21, 54, 41, 44, 0, 49, 0, 16, 52, 0,
+	0, 25, 0, 26, 53, 0, 0, 0, 31, 32,
+	0, 0, 0, 67, 16, 16, 0, 0, 16, 0,
+	19, 43, 45, 46, 48, 47, 53, 40, 53, 53,
+	53, 16, 53, 28, 68, 0, 59, 60, 61, 62,
+	58, 53, 53, 0, 0, 53, 17, 0, -2, 16,
+	16, 16, 53, 0, 0, 0, 0, 0, 0, 72,
+	73, 0, 42, 39, 53, 53, 53, 0, 53, 37,
+	0, 29, 0, 0, 0, 63, 64, 9, 22, 0,
+	0, 0, 33, 0, 52, 53, 0, 66, 30, 42,
+	42, 34, 35, 36, 16, 65, 23, 24, 53, 0,
+	38,
 }
 var yyTok1 = [...]int{
 
 	1, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
-	3, 3, 3, 3, 3, 3, 32, 3, 3, 3,
-	25, 26, 3, 3, 3, 3, 3, 3, 3, 3,
-	3, 3, 3, 3, 3, 3, 3, 3, 3, 31,
-	3, 29, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 33, 3, 3, 3,
+	26, 27, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 32,
+	3, 30, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
-	3, 27, 3, 28, 22, 3, 3, 3, 3, 3,
+	3, 28, 3, 29, 23, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
-	3, 3, 3, 23, 3, 24,
+	3, 3, 3, 24, 3, 25,
 }
 var yyTok2 = [...]int{
 
 	2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
 	12, 13, 14, 15, 16, 17, 18, 19, 20, 21,
+	22,
 }
 var yyTok3 = [...]int{
-	8592, 30, 0,
+	8592, 31, 0,
 }
 
 var yyErrorMessages = [...]struct {
@@ -717,152 +732,184 @@ yydefault:
 			yyVAL.nd = yyDollar[1].nd
 			yyDollar[1].nd.Redirs = yyDollar[2].redirs
 		}
-	case 27:
+	case 26:
+		yyDollar = yyS[yypt-2 : yypt+1]
+		//line parse.y:160
+		{
+			yyVAL.nd = yyDollar[1].nd
+			yyDollar[1].nd.Redirs = yyDollar[2].redirs
+		}
+	case 28:
 		yyDollar = yyS[yypt-3 : yypt+1]
-		//line parse.y:164
+		//line parse.y:169
 		{
 			yyVAL.nd = newNd(Nset, yyDollar[1].sval).Add(yyDollar[3].nd)
 		}
-	case 28:
+	case 29:
 		yyDollar = yyS[yypt-5 : yypt+1]
-		//line parse.y:168
+		//line parse.y:173
 		{
 			yyVAL.nd = yyDollar[4].nd
 			yyVAL.nd.Args = []string{yyDollar[1].sval}
 		}
-	case 29:
+	case 30:
 		yyDollar = yyS[yypt-6 : yypt+1]
-		//line parse.y:173
+		//line parse.y:178
 		{
 			yyVAL.nd = newNd(Nset, yyDollar[1].sval).Add(yyDollar[3].nd).Add(yyDollar[6].nd)
 		}
-	case 32:
+	case 33:
 		yyDollar = yyS[yypt-6 : yypt+1]
-		//line parse.y:184
+		//line parse.y:189
 		{
 			nd := yyDollar[4].nd
 			nd.typ = Nor
 			yyVAL.nd = newList(Ncond, nd)
 		}
-	case 33:
+	case 34:
 		yyDollar = yyS[yypt-7 : yypt+1]
-		//line parse.y:190
+		//line parse.y:195
 		{
 			nd := yyDollar[5].nd
 			nd.typ = Nor
 			yyVAL.nd = yyDollar[1].nd.Add(nd)
 		}
-	case 34:
+	case 35:
+		yyDollar = yyS[yypt-7 : yypt+1]
+		//line parse.y:203
+		{
+			yyVAL.nd = yyDollar[5].nd
+			yyVAL.nd.Child = append([]*Nd{yyDollar[2].nd}, yyVAL.nd.Child...)
+		}
+	case 36:
 		yyDollar = yyS[yypt-3 : yypt+1]
-		//line parse.y:198
+		//line parse.y:211
 		{
 			yyVAL.nd = yyDollar[1].nd.Add(yyDollar[3].nd)
 		}
-	case 35:
+	case 37:
 		yyDollar = yyS[yypt-1 : yypt+1]
-		//line parse.y:202
+		//line parse.y:215
+		{
+			yyVAL.nd = newList(Nswitch, yyDollar[1].nd)
+		}
+	case 38:
+		yyDollar = yyS[yypt-6 : yypt+1]
+		//line parse.y:222
+		{
+			yyVAL.nd = newList(Ncase, yyDollar[1].nd, yyDollar[4].nd)
+		}
+	case 39:
+		yyDollar = yyS[yypt-3 : yypt+1]
+		//line parse.y:229
+		{
+			yyVAL.nd = yyDollar[1].nd.Add(yyDollar[3].nd)
+		}
+	case 40:
+		yyDollar = yyS[yypt-1 : yypt+1]
+		//line parse.y:233
 		{
 			yyVAL.nd = newList(Nblock, yyDollar[1].nd)
 		}
-	case 36:
+	case 41:
 		yyDollar = yyS[yypt-1 : yypt+1]
-		//line parse.y:209
+		//line parse.y:240
 		{
 			yyVAL.redirs = yyDollar[1].redirs
 		}
-	case 37:
+	case 42:
 		yyDollar = yyS[yypt-0 : yypt+1]
-		//line parse.y:213
+		//line parse.y:244
 		{
 			yyVAL.redirs = nil
 		}
-	case 38:
+	case 43:
 		yyDollar = yyS[yypt-2 : yypt+1]
-		//line parse.y:220
+		//line parse.y:251
 		{
 			yyVAL.redirs = yyDollar[1].redirs
 			yyVAL.redirs = yyDollar[2].nd.addRedirTo(yyVAL.redirs)
 		}
-	case 39:
+	case 44:
 		yyDollar = yyS[yypt-1 : yypt+1]
-		//line parse.y:225
+		//line parse.y:256
 		{
 			yyVAL.redirs = nil
 			yyVAL.redirs = yyDollar[1].nd.addRedirTo(yyVAL.redirs)
 		}
-	case 40:
+	case 45:
 		yyDollar = yyS[yypt-2 : yypt+1]
-		//line parse.y:233
+		//line parse.y:264
 		{
 			yyVAL.nd = newRedir("<", yyDollar[1].sval, yyDollar[2].nd)
 		}
-	case 41:
+	case 46:
 		yyDollar = yyS[yypt-2 : yypt+1]
-		//line parse.y:237
+		//line parse.y:268
 		{
 			yyVAL.nd = newRedir(">", yyDollar[1].sval, yyDollar[2].nd)
 		}
-	case 42:
+	case 47:
 		yyDollar = yyS[yypt-2 : yypt+1]
-		//line parse.y:240
+		//line parse.y:271
 		{
 			yyVAL.nd = newRedir(">>", yyDollar[1].sval, yyDollar[2].nd)
 		}
-	case 44:
+	case 49:
 		yyDollar = yyS[yypt-0 : yypt+1]
-		//line parse.y:248
+		//line parse.y:279
 		{
 			yyVAL.nd = nil
 		}
-	case 49:
+	case 54:
 		yyDollar = yyS[yypt-2 : yypt+1]
-		//line parse.y:264
+		//line parse.y:295
 		{
 			yyVAL.nd = yyDollar[1].nd.Add(yyDollar[2].nd)
 		}
-	case 50:
+	case 55:
 		yyDollar = yyS[yypt-1 : yypt+1]
-		//line parse.y:268
+		//line parse.y:299
 		{
 			yyVAL.nd = newList(Nnames, yyDollar[1].nd)
 		}
-	case 53:
+	case 58:
 		yyDollar = yyS[yypt-3 : yypt+1]
-		//line parse.y:279
+		//line parse.y:310
 		{
 			yyVAL.nd = yyDollar[2].nd
 		}
-	case 54:
+	case 59:
 		yyDollar = yyS[yypt-3 : yypt+1]
-		//line parse.y:283
+		//line parse.y:314
 		{
 			nd := newList(Nnames, yyDollar[1].nd)
 			yyVAL.nd = newList(Napp, nd, yyDollar[3].nd)
 		}
-	case 55:
+	case 60:
 		yyDollar = yyS[yypt-3 : yypt+1]
-		//line parse.y:288
+		//line parse.y:319
 		{
 			nd1 := newList(Nnames, yyDollar[1].nd)
 			nd2 := newList(Nnames, yyDollar[3].nd)
 			yyVAL.nd = newList(Napp, nd1, nd2)
 		}
-	case 56:
+	case 61:
 		yyDollar = yyS[yypt-3 : yypt+1]
-		//line parse.y:294
+		//line parse.y:325
 		{
 			nd := newList(Nnames, yyDollar[3].nd)
 			yyVAL.nd = newList(Napp, yyDollar[1].nd, nd)
 		}
-	case 57:
+	case 62:
 		yyDollar = yyS[yypt-3 : yypt+1]
-		//line parse.y:299
+		//line parse.y:330
 		{
 			yyVAL.nd = newList(Napp, yyDollar[1].nd, yyDollar[3].nd)
 		}
-	case 58:
+	case 63:
 		yyDollar = yyS[yypt-5 : yypt+1]
-		//line parse.y:303
+		//line parse.y:334
 		{
 			yyVAL.nd = yyDollar[3].nd
 			yyDollar[3].nd.Args = []string{"<"}
@@ -871,9 +918,9 @@ yydefault:
 			}
 			yyDollar[3].nd.typ = Nioblk
 		}
-	case 59:
+	case 64:
 		yyDollar = yyS[yypt-5 : yypt+1]
-		//line parse.y:312
+		//line parse.y:343
 		{
 			yyVAL.nd = yyDollar[3].nd
 			if yyDollar[1].sval == "" {
@@ -882,58 +929,59 @@ yydefault:
 			yyDollar[3].nd.Args = []string{">", yyDollar[1].sval}
 			yyDollar[3].nd.typ = Nioblk
 		}
-	case 60:
+	case 65:
 		yyDollar = yyS[yypt-4 : yypt+1]
-		//line parse.y:324
+		//line parse.y:355
 		{
 			yyVAL.nd = yyDollar[1].nd.Add(yyDollar[3].nd)
 		}
-	case 61:
+	case 66:
 		yyDollar = yyS[yypt-3 : yypt+1]
-		//line parse.y:328
+		//line parse.y:359
 		{
 			// the parent adds Args with the var name
 			yyVAL.nd = newList(Nsetmap, yyDollar[2].nd)
 		}
-	case 63:
+	case 68:
 		yyDollar = yyS[yypt-0 : yypt+1]
-		//line parse.y:337
+		//line parse.y:368
 		{
 			yyVAL.nd = newList(Nnames)
 		}
-	case 64:
+	case 69:
 		yyDollar = yyS[yypt-1 : yypt+1]
-		//line parse.y:343
+		//line parse.y:374
 		{
 			yyVAL.nd = newNd(Nname, yyDollar[1].sval)
+			yyVAL.nd.quoted = yyDollar[1].bval
 		}
-	case 65:
+	case 70:
 		yyDollar = yyS[yypt-2 : yypt+1]
-		//line parse.y:347
+		//line parse.y:379
 		{
 			yyVAL.nd = newNd(Nval, yyDollar[2].sval)
 		}
-	case 66:
+	case 71:
 		yyDollar = yyS[yypt-2 : yypt+1]
-		//line parse.y:351
+		//line parse.y:383
 		{
 			yyVAL.nd = newNd(Nsingle, yyDollar[2].sval)
 		}
-	case 67:
+	case 72:
 		yyDollar = yyS[yypt-5 : yypt+1]
-		//line parse.y:355
+		//line parse.y:387
 		{
 			yyVAL.nd = newNd(Nval, yyDollar[2].sval).Add(yyDollar[4].nd)
 		}
-	case 68:
+	case 73:
 		yyDollar = yyS[yypt-5 : yypt+1]
-		//line parse.y:359
+		//line parse.y:391
 		{
 			yyVAL.nd = newNd(Nsingle, yyDollar[2].sval).Add(yyDollar[4].nd)
 		}
-	case 69:
+	case 74:
 		yyDollar = yyS[yypt-2 : yypt+1]
-		//line parse.y:363
+		//line parse.y:395
 		{
 			yyVAL.nd = newNd(Nlen, yyDollar[2].sval)
 		}
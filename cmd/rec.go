@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"bytes"
+	"clive/ch"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A named field in a record
+struct Fld {
+	Name, Val string
+}
+
+// A record message, an ordered list of named fields.
+// Text tools may send records instead of lines so that commands
+// reading them can refer to fields by name and do not have to split
+// text again.
+// When printed as text, records are lines with their values separated by tabs.
+type Rec []Fld
+
+func init() {
+	ch.DefType(Rec{})
+}
+
+// Make a record with the given names and values.
+// Missing names are set to the field number (1, 2, ...).
+func NewRec(names, vals []string) Rec {
+	r := make(Rec, 0, len(vals))
+	for i, v := range vals {
+		n := strconv.Itoa(i + 1)
+		if i < len(names) && names[i] != "" {
+			n = names[i]
+		}
+		r = append(r, Fld{Name: n, Val: v})
+	}
+	return r
+}
+
+// Make a dup of the record.
+func (r Rec) Dup() Rec {
+	nr := make(Rec, len(r))
+	copy(nr, r)
+	return nr
+}
+
+// Return the field names in order.
+func (r Rec) Names() []string {
+	ns := make([]string, len(r))
+	for i, f := range r {
+		ns[i] = f.Name
+	}
+	return ns
+}
+
+// Return the field values in order.
+func (r Rec) Vals() []string {
+	vs := make([]string, len(r))
+	for i, f := range r {
+		vs[i] = f.Val
+	}
+	return vs
+}
+
+// Return the index for the field named or numbered (from 1) by key, or -1.
+// Names are tried first.
+func (r Rec) Index(key string) int {
+	for i, f := range r {
+		if f.Name == key {
+			return i
+		}
+	}
+	if n, err := strconv.Atoi(key); err == nil && n >= 1 && n <= len(r) {
+		return n - 1
+	}
+	return -1
+}
+
+// Return the value for the field named or numbered by key (see Index).
+func (r Rec) Get(key string) (string, bool) {
+	if i := r.Index(key); i >= 0 {
+		return r[i].Val, true
+	}
+	return "", false
+}
+
+// Set the value for the named field, adding it if it's not there.
+func (r *Rec) Set(name, val string) {
+	for i := range *r {
+		if (*r)[i].Name == name {
+			(*r)[i].Val = val
+			return
+		}
+	}
+	*r = append(*r, Fld{Name: name, Val: val})
+}
+
+// Return the values separated by sep.
+func (r Rec) Text(sep string) string {
+	return strings.Join(r.Vals(), sep)
+}
+
+func (r Rec) String() string {
+	return r.Text("\t")
+}
+
+// Return the record as a JSON object, with fields in order.
+func (r Rec) JSON() string {
+	var b bytes.Buffer
+	b.WriteString("{")
+	for i, f := range r {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		n, _ := json.Marshal(f.Name)
+		v, _ := json.Marshal(f.Val)
+		b.Write(n)
+		b.WriteString(": ")
+		b.Write(v)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+func (r Rec) TypeId() uint16 {
+	return ch.Trec
+}
+
+func (r Rec) WriteTo(w io.Writer) (n int64, err error) {
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(r))); err != nil {
+		return 0, err
+	}
+	n = 4
+	for _, f := range r {
+		nw, err := ch.WriteStringTo(w, f.Name)
+		n += nw
+		if err != nil {
+			return n, err
+		}
+		nw, err = ch.WriteStringTo(w, f.Val)
+		n += nw
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func UnpackRec(b []byte) ([]byte, Rec, error) {
+	if len(b) < 4 {
+		return b, nil, ch.ErrTooSmall
+	}
+	n := int(binary.LittleEndian.Uint32(b[0:]))
+	if n < 0 || n > ch.MaxMsgSz {
+		return b, nil, ch.ErrTooLarge
+	}
+	b = b[4:]
+	r := make(Rec, 0, n)
+	var err error
+	for i := 0; i < n; i++ {
+		var f Fld
+		if b, f.Name, err = ch.UnpackString(b); err != nil {
+			return b, nil, err
+		}
+		if b, f.Val, err = ch.UnpackString(b); err != nil {
+			return b, nil, err
+		}
+		r = append(r, f)
+	}
+	return b, r, nil
+}
+
+func (r Rec) Unpack(b []byte) (face{}, error) {
+	_, r, err := UnpackRec(b)
+	return r, err
+}
@@ -0,0 +1,117 @@
+/*
+	run sam commands on input files
+
+	Each file in the input is edited using sam's command language
+	(see clive/txt/sam) with dot set to the whole file,
+	and then written to the output, unless -n is given.
+	Output from p and = is written to the output as well.
+*/
+package main
+
+import (
+	"clive/cmd"
+	"clive/cmd/opt"
+	"clive/txt"
+	"clive/txt/sam"
+	"clive/zx"
+	"strings"
+)
+
+// writes the output of p and = to out
+struct outw {
+	out chan<- face{}
+}
+
+var (
+	opts  = opt.New("cmd {cmd}")
+	nflag bool
+	prog  *sam.Prog
+)
+
+func (o outw) Write(b []byte) (int, error) {
+	nb := make([]byte, len(b))
+	copy(nb, b)
+	if ok := o.out <- nb; !ok {
+		return 0, cerror(o.out)
+	}
+	return len(b), nil
+}
+
+func edit(name string, b []byte, out chan<- face{}) error {
+	t := txt.New([]rune(string(b)))
+	s := sam.New(t)
+	s.Name = name
+	s.Out = outw{out}
+	s.Dot.P1 = t.Len()
+	if err := s.Exec(prog); err != nil {
+		return err
+	}
+	if nflag {
+		return nil
+	}
+	if ok := out <- []byte(t.String()); !ok {
+		return cerror(out)
+	}
+	return nil
+}
+
+func run(in <-chan face{}) error {
+	out := cmd.Out("out")
+	name := "in"
+	var sts error
+	for m := range in {
+		ok := true
+		switch m := m.(type) {
+		case zx.Dir:
+			name = m["Upath"]
+			if name == "" {
+				name = m["path"]
+			}
+			ok = out <- m
+		case []byte:
+			cmd.Dprintf("got %d bytes\n", len(m))
+			if err := edit(name, m, out); err != nil {
+				cmd.Warn("%s: %s", name, err)
+				sts = err
+				if cerror(out) != nil {
+					close(in, cerror(out))
+				}
+			}
+		default:
+			cmd.Dprintf("got %T\n", m)
+			ok = out <- m
+		}
+		if !ok {
+			close(in, cerror(out))
+		}
+	}
+	if err := cerror(in); err != nil {
+		return err
+	}
+	return sts
+}
+
+// Run sam in the current app context.
+func main() {
+	c := cmd.AppCtx()
+	cmd.UnixIO("err")
+	opts.NewFlag("D", "debug", &c.Debug)
+	opts.NewFlag("n", "do not write the edited files, just p and = output", &nflag)
+	ux := false
+	opts.NewFlag("u", "use unix out", &ux)
+	args := opts.Parse()
+	if ux {
+		cmd.UnixIO("out")
+	}
+	if len(args) == 0 {
+		cmd.Warn("missing command")
+		opts.Usage()
+	}
+	var err error
+	if prog, err = sam.Compile(strings.Join(args, "\n")); err != nil {
+		cmd.Fatal(err)
+	}
+	if err := run(cmd.FullFiles(cmd.In("in"))); err != nil {
+		cmd.Fatal(err)
+	}
+}
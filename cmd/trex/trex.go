@@ -1,5 +1,8 @@
 /*
 	translate expressions in input
+
+	Replacements may refer to groups in the expression
+	using \0 to \9, or \<name> for groups named (?P<name>...).
 */
 package main
 
@@ -16,50 +19,25 @@ var (
 	froms, tos []string
 	all        bool
 
-	sflag, fflag, gflag, tflag, lflag, uflag, rflag, xflag bool
+	sflag, fflag, gflag, tflag, lflag, uflag, rflag, xflag, iflag bool
 )
 
 func replre(s string, re *sre.ReProg, to string, glob bool) string {
 	rfrom := []rune(s)
-	rto := []rune(to)
-	nrefs := 0
-	for i := 0; i < len(rto)-1; i++ {
-		if nb := rto[i+1]; rto[i] == '\\' {
-			if nb >= '0' && nb <= '9' {
-				nb -= '0'
-				rto[i] = nb
-				nrefs++
-				n := copy(rto[i+1:], rto[i+2:])
-				rto = rto[:i+1+n]
-			} else if nb == '\\' {
-				n := copy(rto[i+1:], rto[i+2:])
-				rto = rto[:i+1+n]
-			}
-		}
-	}
 	st := 0
 	for {
 		cmd.Dprintf("re match [%d:%d]\n", st, len(rfrom))
-		rg := re.ExecRunes(rfrom, st, len(rfrom))
-		if len(rg) == 0 {
+		m := re.FindRunes(rfrom, st, len(rfrom))
+		if m == nil {
 			break
 		}
-		r0 := rg[0]
+		r0 := m.Rg[0]
 		var ns []rune
 		ns = append(ns, rfrom[:r0.P0]...)
-		if nrefs == 0 {
-			ns = append(ns, rto...)
+		if sflag {
+			ns = append(ns, []rune(to)...)
 		} else {
-			for _, r := range rto {
-				if r > 10 {
-					ns = append(ns, r)
-					continue
-				}
-				if r < rune(len(rg)) {
-					t := rfrom[rg[r].P0:rg[r].P1]
-					ns = append(ns, t...)
-				}
-			}
+			ns = append(ns, []rune(m.Repl(to))...)
 		}
 		st = len(ns)
 		ns = append(ns, rfrom[r0.P1:]...)
@@ -108,10 +86,6 @@ Loop:
 }
 
 func trex(in <-chan face{}) error {
-	nrepl := 1
-	if gflag {
-		nrepl = -1
-	}
 	out := cmd.Out("out")
 	doall := false
 	for m := range in {
@@ -132,12 +106,10 @@ func trex(in <-chan face{}) error {
 				s = strings.Title(s)
 			}
 			for i := 0; i < len(froms); i++ {
-				if res != nil {
-					s = replre(s, res[i], tos[i], gflag)
-				} else if rflag {
+				if rflag {
 					s = replset(s, froms[i], tos[i])
 				} else {
-					s = strings.Replace(s, froms[i], tos[i], nrepl)
+					s = replre(s, res[i], tos[i], gflag)
 				}
 			}
 			cmd.Printf("%s", s)
@@ -175,6 +147,7 @@ func main() {
 	opts.NewFlag("l", "translate to lower case", &lflag)
 	opts.NewFlag("t", "translate to title case", &tflag)
 	opts.NewFlag("r", "interpret replacements as rune sets", &rflag)
+	opts.NewFlag("i", "ignore case", &iflag)
 	opts.NewFlag("x", "match against each extracted text (eg., out from gr -x)", &xflag)
 	ux := false
 	opts.NewFlag("u", "use unix out", &ux)
@@ -190,15 +163,22 @@ func main() {
 		cmd.Warn("wrong number of arguments")
 		opts.Usage()
 	}
-	if rflag && gflag || rflag && sflag || gflag && sflag {
+	if rflag && gflag || rflag && sflag || rflag && iflag {
 		cmd.Warn("incompatible flags given")
 		opts.Usage()
 	}
+	dir := sre.Fwd
+	if iflag {
+		dir |= sre.Fold
+	}
+	if sflag {
+		dir |= sre.Lit
+	}
 	for i := 0; i < len(args); i += 2 {
-		froms = append(froms, args[0])
-		tos = append(tos, args[1])
-		if !sflag && !rflag {
-			re, err := sre.CompileStr(args[0], sre.Fwd)
+		froms = append(froms, args[i])
+		tos = append(tos, args[i+1])
+		if !rflag {
+			re, err := sre.CompileStr(args[i], dir)
 			if err != nil {
 				cmd.Fatal("rexp: %s", err)
 			}
@@ -0,0 +1,316 @@
+package tty
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A line editor for terminals.
+// Lines are edited in raw mode (see Raw), which is set only while reading a line.
+//
+// Keys understood are:
+//	^A, ^E, home, end: move to the start/end of line
+//	^B, ^F, left, right: move one rune back/forward
+//	^P, ^N, up, down: previous/next line in the history
+//	^H, del: delete the previous rune
+//	^D: delete the next rune, or EOF if the line is empty
+//	^K, ^U, ^W: delete to the end/start of line or the previous word
+//	^L: redraw the line
+//	^C: discard the line
+//	tab: complete the word before the cursor
+struct Editor {
+	// If set, called with the word before the cursor to return
+	// the possible (full) words for it.
+	// Words ending in / are not followed by a space when completed.
+	Complete func(word string) []string
+
+	in   *os.File
+	out  *os.File
+	hist []string
+}
+
+// Editing state for a line
+struct edLine {
+	*Editor
+	prompt string
+	buf    []rune
+	pos    int
+	hidx   int    // index in history
+	saved  []rune // line being edited while browsing the history
+}
+
+const (
+	ctl  = 0x1f
+	del  = 0x7f
+	esc  = 0x1b
+	bell = "\a"
+)
+
+// Create an editor for the tty in (with output to out).
+func NewEditor(in, out *os.File) *Editor {
+	return &Editor{
+		in:  in,
+		out: out,
+	}
+}
+
+// Read a rune from the tty.
+// Input is read a byte at a time and not buffered, so that
+// whatever follows the line read is left for others to read.
+func (e *Editor) readRune() (rune, error) {
+	var buf [utf8.UTFMax]byte
+	for n := 0; n < len(buf); {
+		if _, err := io.ReadFull(e.in, buf[n:n+1]); err != nil {
+			return 0, err
+		}
+		n++
+		if utf8.FullRune(buf[:n]) {
+			r, _ := utf8.DecodeRune(buf[:n])
+			return r, nil
+		}
+	}
+	return utf8.RuneError, nil
+}
+
+// Add a line to the history.
+// Empty lines and repeats of the last one are ignored.
+func (e *Editor) AddHistory(ln string) {
+	ln = strings.TrimRight(ln, "\n")
+	if ln == "" || len(e.hist) > 0 && e.hist[len(e.hist)-1] == ln {
+		return
+	}
+	e.hist = append(e.hist, ln)
+}
+
+// Return the history lines.
+func (e *Editor) History() []string {
+	return e.hist
+}
+
+// Read a line from the tty, editing it, and return it without the final \n.
+// Returns io.EOF on ^D at an empty line.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	st, err := Raw(e.in)
+	if err != nil {
+		return "", err
+	}
+	defer Restore(e.in, st)
+	l := &edLine{Editor: e, prompt: prompt, hidx: len(e.hist)}
+	l.redraw()
+	for {
+		r, err := e.readRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			l.end()
+			fmt.Fprintf(e.out, "\n")
+			return string(l.buf), nil
+		case 'A' & ctl:
+			l.pos = 0
+		case 'E' & ctl:
+			l.pos = len(l.buf)
+		case 'B' & ctl:
+			l.left()
+		case 'F' & ctl:
+			l.right()
+		case 'P' & ctl:
+			l.prev()
+		case 'N' & ctl:
+			l.next()
+		case 'H' & ctl, del:
+			if l.pos > 0 {
+				l.pos--
+				l.delete(l.pos, l.pos+1)
+			}
+		case 'D' & ctl:
+			if len(l.buf) == 0 {
+				fmt.Fprintf(e.out, "\n")
+				return "", io.EOF
+			}
+			l.delete(l.pos, l.pos+1)
+		case 'K' & ctl:
+			l.delete(l.pos, len(l.buf))
+		case 'U' & ctl:
+			l.delete(0, l.pos)
+			l.pos = 0
+		case 'W' & ctl:
+			p0 := l.wordStart(true)
+			l.delete(p0, l.pos)
+			l.pos = p0
+		case 'C' & ctl:
+			l.end()
+			fmt.Fprintf(e.out, "^C\n")
+			l.buf, l.pos = nil, 0
+		case 'L' & ctl:
+		case '\t':
+			l.complete()
+		case esc:
+			l.escape()
+		default:
+			if !unicode.IsPrint(r) {
+				fmt.Fprintf(e.out, bell)
+				continue
+			}
+			l.insert([]rune{r})
+		}
+		l.redraw()
+	}
+}
+
+func (l *edLine) redraw() {
+	s := fmt.Sprintf("\r%s%s\x1b[K", l.prompt, string(l.buf))
+	if n := len(l.buf) - l.pos; n > 0 {
+		s += fmt.Sprintf("\x1b[%dD", n)
+	}
+	fmt.Fprintf(l.out, "%s", s)
+}
+
+func (l *edLine) end() {
+	l.pos = len(l.buf)
+	l.redraw()
+}
+
+func (l *edLine) left() {
+	if l.pos > 0 {
+		l.pos--
+	}
+}
+
+func (l *edLine) right() {
+	if l.pos < len(l.buf) {
+		l.pos++
+	}
+}
+
+func (l *edLine) insert(rs []rune) {
+	nb := make([]rune, 0, len(l.buf)+len(rs))
+	nb = append(nb, l.buf[:l.pos]...)
+	nb = append(nb, rs...)
+	l.buf = append(nb, l.buf[l.pos:]...)
+	l.pos += len(rs)
+}
+
+func (l *edLine) delete(p0, p1 int) {
+	if p1 > len(l.buf) {
+		p1 = len(l.buf)
+	}
+	if p0 >= p1 {
+		return
+	}
+	l.buf = append(l.buf[:p0], l.buf[p1:]...)
+	if l.pos > len(l.buf) {
+		l.pos = len(l.buf)
+	}
+}
+
+// Return the start of the word before the cursor.
+// If skipsp, spaces right before the cursor are skipped first.
+func (l *edLine) wordStart(skipsp bool) int {
+	p := l.pos
+	for skipsp && p > 0 && unicode.IsSpace(l.buf[p-1]) {
+		p--
+	}
+	for p > 0 && !unicode.IsSpace(l.buf[p-1]) {
+		p--
+	}
+	return p
+}
+
+func (l *edLine) setHist(i int) {
+	if l.hidx == len(l.hist) {
+		l.saved = l.buf
+	}
+	l.hidx = i
+	if i == len(l.hist) {
+		l.buf = l.saved
+	} else {
+		l.buf = []rune(l.hist[i])
+	}
+	l.pos = len(l.buf)
+}
+
+func (l *edLine) prev() {
+	if l.hidx > 0 {
+		l.setHist(l.hidx - 1)
+	}
+}
+
+func (l *edLine) next() {
+	if l.hidx < len(l.hist) {
+		l.setHist(l.hidx + 1)
+	}
+}
+
+// ESC [ x and ESC O x sequences for arrows and other keys.
+func (l *edLine) escape() {
+	r, err := l.readRune()
+	if err != nil || r != '[' && r != 'O' {
+		return
+	}
+	r, err = l.readRune()
+	if err != nil {
+		return
+	}
+	switch r {
+	case 'A':
+		l.prev()
+	case 'B':
+		l.next()
+	case 'C':
+		l.right()
+	case 'D':
+		l.left()
+	case 'H':
+		l.pos = 0
+	case 'F':
+		l.pos = len(l.buf)
+	case '3': // ESC [ 3 ~ is delete
+		if r, _ = l.readRune(); r == '~' {
+			l.delete(l.pos, l.pos+1)
+		}
+	}
+}
+
+func commonPrefix(ws []string) string {
+	p := []rune(ws[0])
+	for _, w := range ws[1:] {
+		for !strings.HasPrefix(w, string(p)) {
+			p = p[:len(p)-1]
+		}
+	}
+	return string(p)
+}
+
+func (l *edLine) complete() {
+	if l.Complete == nil {
+		fmt.Fprintf(l.out, bell)
+		return
+	}
+	p0 := l.wordStart(false)
+	word := string(l.buf[p0:l.pos])
+	ws := l.Complete(word)
+	switch len(ws) {
+	case 0:
+		fmt.Fprintf(l.out, bell)
+		return
+	case 1:
+		w := ws[0]
+		if !strings.HasSuffix(w, "/") {
+			w += " "
+		}
+		l.insert([]rune(strings.TrimPrefix(w, word)))
+		return
+	}
+	if p := commonPrefix(ws); len(p) > len(word) && strings.HasPrefix(p, word) {
+		l.insert([]rune(p[len(word):]))
+		return
+	}
+	l.end()
+	fmt.Fprintf(l.out, "\n%s\n", strings.Join(ws, " "))
+}
@@ -1,4 +1,5 @@
-// +build !bsd, !darwin, !linux, !freebsd, !openbsd, !cgo
+//go:build !bsd && !darwin && !linux && !freebsd && !openbsd
+// +build !bsd,!darwin,!linux,!freebsd,!openbsd
 
 package tty
 
@@ -0,0 +1,48 @@
+// +build bsd darwin freebsd openbsd linux
+
+package tty
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// The state of a tty, as saved by Raw.
+struct State {
+	termios syscall.Termios
+}
+
+func ioctl(f *os.File, req uintptr, t *syscall.Termios) error {
+	_, _, err := syscall.Syscall6(syscall.SYS_IOCTL, f.Fd(), req,
+		uintptr(unsafe.Pointer(t)), 0, 0, 0)
+	if err != 0 {
+		return err
+	}
+	return nil
+}
+
+// Put the tty f in raw mode and return its previous state.
+// Input is not echoed nor edited by the system and the
+// interrupt characters are read as any other.
+// Output processing is kept, so \n still moves to the start of the next line.
+func Raw(f *os.File) (*State, error) {
+	var st State
+	if err := ioctl(f, ioctlReadTermios, &st.termios); err != nil {
+		return nil, err
+	}
+	t := st.termios
+	t.Iflag &^= syscall.ICRNL | syscall.INLCR | syscall.IGNCR | syscall.ISTRIP | syscall.IXON
+	t.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	t.Cc[syscall.VMIN] = 1
+	t.Cc[syscall.VTIME] = 0
+	if err := ioctl(f, ioctlWriteTermios, &t); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// Restore the state of the tty f as saved by Raw.
+func Restore(f *os.File, st *State) error {
+	return ioctl(f, ioctlWriteTermios, &st.termios)
+}
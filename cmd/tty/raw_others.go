@@ -0,0 +1,24 @@
+//go:build !bsd && !darwin && !linux && !freebsd && !openbsd
+// +build !bsd,!darwin,!linux,!freebsd,!openbsd
+
+package tty
+
+import (
+	"errors"
+	"os"
+)
+
+// The state of a tty, as saved by Raw.
+struct State {
+}
+
+// Put the tty f in raw mode and return its previous state.
+// Not supported in this system.
+func Raw(f *os.File) (*State, error) {
+	return nil, errors.New("no raw tty mode in this system")
+}
+
+// Restore the state of the tty f as saved by Raw.
+func Restore(f *os.File, st *State) error {
+	return nil
+}
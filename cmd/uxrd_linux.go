@@ -0,0 +1,110 @@
+// +build linux
+
+package cmd
+
+import (
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// A reader for a unix file that is not ours (eg., the terminal),
+// that can be closed while waiting for input, so that nothing is
+// read from the file once we are done with it.
+struct uxRdr {
+	sync.Mutex
+	f       *os.File
+	p       [2]int // closing p[1] stops the reader
+	reading bool
+	stopped bool
+}
+
+const fdSetSz = 8 * int(unsafe.Sizeof(syscall.FdSet{}))
+
+func newUXRdr(f *os.File) io.ReadCloser {
+	r := &uxRdr{f: f}
+	if int(f.Fd()) >= fdSetSz {
+		return nil
+	}
+	if err := syscall.Pipe(r.p[:]); err != nil {
+		return nil
+	}
+	if r.p[0] >= fdSetSz {
+		syscall.Close(r.p[0])
+		syscall.Close(r.p[1])
+		return nil
+	}
+	return r
+}
+
+func fdSet(s *syscall.FdSet, fd int) {
+	n := 8 * int(unsafe.Sizeof(s.Bits[0]))
+	s.Bits[fd/n] |= 1 << uint(fd%n)
+}
+
+func fdIsSet(s *syscall.FdSet, fd int) bool {
+	n := 8 * int(unsafe.Sizeof(s.Bits[0]))
+	return s.Bits[fd/n]&(1<<uint(fd%n)) != 0
+}
+
+// Wait until there's input or we are stopped.
+func (r *uxRdr) wait() error {
+	fd := int(r.f.Fd())
+	nfd := fd
+	if r.p[0] > nfd {
+		nfd = r.p[0]
+	}
+	for {
+		var s syscall.FdSet
+		fdSet(&s, fd)
+		fdSet(&s, r.p[0])
+		_, err := syscall.Select(nfd+1, &s, nil, nil, nil)
+		switch {
+		case err == syscall.EINTR:
+		case err != nil:
+			return err
+		case fdIsSet(&s, r.p[0]):
+			return io.EOF
+		case fdIsSet(&s, fd):
+			return nil
+		}
+	}
+}
+
+func (r *uxRdr) Read(b []byte) (int, error) {
+	r.Lock()
+	if r.stopped {
+		r.Unlock()
+		return 0, io.EOF
+	}
+	r.reading = true
+	r.Unlock()
+	err := r.wait()
+	r.Lock()
+	r.reading = false
+	if r.stopped {
+		syscall.Close(r.p[0])
+		err = io.EOF
+	}
+	r.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	return r.f.Read(b)
+}
+
+func (r *uxRdr) Close() error {
+	r.Lock()
+	defer r.Unlock()
+	if r.stopped {
+		return nil
+	}
+	r.stopped = true
+	syscall.Close(r.p[1])
+	if !r.reading {
+		syscall.Close(r.p[0])
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+// +build !linux
+
+package cmd
+
+import (
+	"io"
+	"os"
+)
+
+// Readers for unix files that can be stopped are not supported in
+// this system, and the file is read directly.
+func newUXRdr(f *os.File) io.ReadCloser {
+	return nil
+}
@@ -2,6 +2,12 @@
 	ZX file server.
 
 	Export zx trees
+
+	Under flag -N, the name space ($NS) is also exported as a single tree
+	with the name given. Its Ctl file lists the mounts and accepts
+	mount and unmount commands (see ns.Tree).
+	Remote users other than the owner can't use remote trees
+	mounted there, which would be used with the owner's credentials.
 */
 package main
 
@@ -9,11 +15,14 @@ import (
 	"clive/cmd"
 	"clive/cmd/opt"
 	"clive/dbg"
+	"clive/net"
 	"clive/net/auth"
+	"clive/ns"
 	"clive/zx"
 	"clive/zx/rzx"
 	"clive/zx/zux"
 	"clive/zx/zxc"
+	"os"
 	fpath "path"
 	"path/filepath"
 	"strings"
@@ -27,6 +36,11 @@ var (
 
 	opts       = opt.New("{spec}")
 	port, addr string
+	auditf     string
+	aclf       string
+	nsname     string
+	proxies    string
+	nfails     = rzx.ThrottleFails
 )
 
 func main() {
@@ -44,13 +58,21 @@ func main() {
 	opts.NewFlag("v", "report users logged in/out (verbose)", &c.Verb)
 	opts.NewFlag("Z", "verbose debug", &Zdebug)
 	opts.NewFlag("n", "no auth", &noauth)
+	opts.NewFlag("L", "file: write an audit log to file (- for stderr)", &auditf)
+	opts.NewFlag("c", "aclfile: grant access to trees as said in aclfile", &aclf)
+	opts.NewFlag("T", "nfails: refuse conns after nfails auth failures (0 disables)", &nfails)
+	opts.NewFlag("N", "name: serve also the name space as the tree name", &nsname)
+	opts.NewFlag("P", "ips: trust the ws proxies at these comma separated ips", &proxies)
 	args := opts.Parse()
-	if len(args) == 0 {
+	if len(args) == 0 && nsname == "" {
 		cmd.Warn("missing arguments")
 		opts.Usage()
 	}
 	c.Debug = c.Debug || Zdebug
 	auth.Debug = c.Debug
+	if proxies != "" {
+		net.TrustProxy(strings.Split(proxies, ",")...)
+	}
 
 	trs := map[string]zx.Fs{}
 	ros := map[bool]string{false: "rw", true: "ro"}
@@ -105,6 +127,18 @@ func main() {
 		}
 		rotrs[t.Tag] = ronly
 	}
+	if nsname != "" {
+		if _, ok := trs[nsname]; ok {
+			cmd.Fatal("dup tree name %s", nsname)
+		}
+		t := ns.NewTree(cmd.NS())
+		t.Tag = nsname
+		cmd.Warn("%s ns", nsname)
+		trs[nsname] = t
+		if mainfs == nil {
+			mainfs = t
+		}
+	}
 	if len(trs) == 0 {
 		cmd.Fatal("no trees to serve")
 	}
@@ -119,6 +153,26 @@ func main() {
 	if noauth {
 		srv.NoAuth()
 	}
+	srv.Throttle(nfails, rzx.ThrottleDelay, rzx.ThrottleMaxDelay)
+	if aclf != "" {
+		acl, err := rzx.LoadACL(aclf)
+		if err != nil {
+			cmd.Fatal("acl: %s", err)
+		}
+		srv.SetACL(acl)
+	}
+	switch auditf {
+	case "":
+	case "-":
+		srv.AuditTo(os.Stderr)
+	default:
+		fd, err := os.OpenFile(auditf, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			cmd.Fatal("audit: %s", err)
+		}
+		defer fd.Close()
+		srv.AuditTo(fd)
+	}
 	if c.Debug {
 		srv.Debug = true
 	}
@@ -2,6 +2,8 @@
 	Authentication services for clive.
 
 	Clive relies on challenge response authentication using shared keys.
+	As an alternative, users may keep ed25519 private keys and servers
+	the public keys authorized for them, to avoid distributing shared secrets.
 	This package provides tools to authenticate clients and servers and
 	to authenticate acess to wax interfaces.
 
@@ -11,8 +13,6 @@
 */
 package auth
 
-// BUG(x): the secrets file for a domain should keep multiple user/key pairs.
-
 // REFERENCE(x): nchan, channels for I/O devices.
 
 // REFERENCE(x): cmd/auth, to generate key files.
@@ -34,6 +34,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math/rand"
 	"os"
 	"path"
@@ -98,6 +99,7 @@ struct Info {
 /*
 	Per-user keys.
 	See Info for a description.
+	Key files keep one or more of these, one per user.
 */
 struct Key {
 	Uid  string
@@ -124,6 +126,10 @@ var (
 	// Timeout placed on the authentication protocol.
 	Tmout = 10 * time.Second
 
+	// Let clients using public keys accept servers without a public key
+	// authorized for them, relying on TLS to identify the server.
+	PkTrustTLS = false
+
 	// Enable debug diagnostics.
 	Debug   = false
 	dprintf = dbg.FlagPrintf(&Debug)
@@ -175,7 +181,7 @@ func TLScfg(pem, key string) (*tls.Config, error) {
 }
 
 struct msg {
-	enabled         bool
+	enabled, pk     bool
 	user, speaksfor string
 	proto           map[string]bool
 	ch              []byte
@@ -183,9 +189,12 @@ struct msg {
 
 func (m *msg) pack() []byte {
 	var buf bytes.Buffer
-	if m.enabled {
+	switch {
+	case m.enabled && m.pk:
+		buf.WriteString("pkauth")
+	case m.enabled:
 		buf.WriteString("auth")
-	} else {
+	default:
 		buf.WriteString("noauth")
 	}
 	buf.WriteByte(0)
@@ -207,7 +216,9 @@ func (m *msg) String() string {
 		return "<nil auth msg>"
 	}
 	var buf bytes.Buffer
-	if m.enabled {
+	if m.enabled && m.pk {
+		fmt.Fprintf(&buf, "pkauth")
+	} else if m.enabled {
 		fmt.Fprintf(&buf, "auth")
 	} else {
 		fmt.Fprintf(&buf, "noauth")
@@ -234,7 +245,8 @@ func (m *msg) unpack(buf []byte) error {
 		return err
 	}
 	buf = buf[n:]
-	m.enabled = s == "auth"
+	m.enabled = s == "auth" || s == "pkauth"
+	m.pk = s == "pkauth"
 	n, m.user, err = unpackstr(buf)
 	if err != nil {
 		return err
@@ -291,40 +303,89 @@ func SaveKey(dir, name, user, secret string, groups ...string) error {
 	data := []byte(secret)
 	key := pbkdf2.Key(data, []byte("ltsa"), 1000, 32, sha1.New)
 
-	old, _ := LoadKey(dir, name)
-	new := []Key{}
-	for _, o := range old {
+	old, err := keysToUpdate(LoadKey(dir, name))
+	if err != nil {
+		return err
+	}
+	return writeKeys(file, addKey(old, Key{Uid: user, Gids: groups, Key: key}))
+}
+
+// Remove the key for the given user in the named auth domain
+// kept at KeyFile(dir, name).
+// It is not an error if the user has no key.
+func DelKey(dir, name, user string) error {
+	if dir == "" {
+		dir = KeyDir()
+	}
+	if name == "" {
+		name = "default"
+	}
+	old, err := LoadKey(dir, name)
+	if err != nil {
+		return err
+	}
+	return writeKeys(KeyFile(dir, name), delKey(old, user))
+}
+
+// Return the keys loaded to update them, or an error unless they were
+// loaded or there are none yet.
+// Updating a damaged file would drop the keys that could not be read.
+func keysToUpdate(ks []Key, err error) ([]Key, error) {
+	if err == nil || os.IsNotExist(err) || err == io.EOF && len(ks) == 0 {
+		return ks, nil
+	}
+	return nil, err
+}
+
+// Return ks with k added, replacing any other key for the same user.
+func addKey(ks []Key, k Key) []Key {
+	return append(delKey(ks, k.Uid), k)
+}
+
+// Return ks without the key for the given user.
+func delKey(ks []Key, user string) []Key {
+	nks := []Key{}
+	for _, o := range ks {
 		if o.Uid != user {
-			new = append(new, o)
+			nks = append(nks, o)
 		}
 	}
-	new = append(new, Key{Uid: user, Gids: groups, Key: key})
-	fd, err := os.Create(file)
+	return nks
+}
+
+// Write the keys to the given file, readable only by the owner.
+// The keys are written to a temporary file that replaces the old one
+// only when complete, so a failed write does not affect the old keys.
+func writeKeys(file string, ks []Key) error {
+	fd, err := ioutil.TempFile(path.Dir(file), path.Base(file))
 	if err != nil {
 		return err
 	}
-	for _, k := range new {
-		if _, err := fmt.Fprintf(fd, "%s", k.Uid); err != nil {
-			os.Remove(file)
-			return err
-		}
+	tmp := fd.Name()
+	var buf bytes.Buffer
+	for _, k := range ks {
+		fmt.Fprintf(&buf, "%s", k.Uid)
 		for _, g := range k.Gids {
-			if _, err := fmt.Fprintf(fd, " %s", g); err != nil {
-				os.Remove(file)
-				return err
-			}
-		}
-		if _, err := fmt.Fprintf(fd, "\n%x\n", k.Key); err != nil {
-			os.Remove(file)
-			return err
+			fmt.Fprintf(&buf, " %s", g)
 		}
+		fmt.Fprintf(&buf, "\n%x\n", k.Key)
+	}
+	if err := fd.Chmod(0600); err != nil {
+		fd.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if _, err := fd.Write(buf.Bytes()); err != nil {
+		fd.Close()
+		os.Remove(tmp)
+		return err
 	}
 	if err := fd.Close(); err != nil {
-		os.Remove(file)
+		os.Remove(tmp)
 		return err
 	}
-	if err := os.Chmod(file, 0600); err != nil {
-		os.Remove(file)
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
 		return err
 	}
 	return nil
@@ -339,34 +400,51 @@ func LoadKey(dir, name string) (ks []Key, err error) {
 	if name == "" {
 		name = "default"
 	}
-	file := path.Join(dir, "clive."+name)
+	return readKeys(KeyFile(dir, name))
+}
+
+// Read the keys kept at the given file.
+// Each key is kept in two lines: one with the user name followed by its groups,
+// and one with the key in hexa.
+// Files may keep keys for multiple users, and empty lines and
+// lines starting with # are ignored.
+func readKeys(file string) (ks []Key, err error) {
 	fd, err := os.Open(file)
 	if err != nil {
 		return nil, err
 	}
 	defer fd.Close()
 	scn := bufio.NewScanner(fd)
+	scan := func() ([]string, bool) {
+		for scn.Scan() {
+			toks := strings.Fields(scn.Text())
+			if len(toks) > 0 && !strings.HasPrefix(toks[0], "#") {
+				return toks, true
+			}
+		}
+		return nil, false
+	}
 	for {
-		if !scn.Scan() {
+		toks, ok := scan()
+		if !ok {
 			if len(ks) == 0 {
 				return nil, io.EOF
 			}
 			break
 		}
-		users := scn.Text()
-		toks := strings.Fields(users)
-		if len(toks) == 0 {
-			return ks, io.EOF
-		}
 		user := toks[0]
 		toks = toks[1:]
 		if len(toks) == 0 {
 			toks = append(toks, user)
 		}
-		if !scn.Scan() {
-			return ks, io.EOF
+		if _, ok := FindKey(ks, user); ok {
+			return ks, fmt.Errorf("%s: duplicate user %s", file, user)
 		}
-		key, err := hex.DecodeString(scn.Text())
+		ktoks, ok := scan()
+		if !ok {
+			return ks, fmt.Errorf("%s: no key for user %s", file, user)
+		}
+		key, err := hex.DecodeString(ktoks[0])
 		if err != nil {
 			return ks, err
 		}
@@ -375,36 +453,56 @@ func LoadKey(dir, name string) (ks []Key, err error) {
 	return ks, nil
 }
 
+// Return the key for the given user, or the first one if user is "".
+func FindKey(ks []Key, user string) (Key, bool) {
+	for _, k := range ks {
+		if user == "" || k.Uid == user {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+// Return the domain keys (those loaded at init time for the default domain).
+func domainKeys(name string) ([]Key, error) {
+	if name == "" || name == "default" {
+		if keys == nil {
+			return nil, errors.New("no keys")
+		}
+		return keys, nil
+	}
+	return LoadKey(KeyDir(), name)
+}
+
 /*
 	Check out to see if resp is the expected response for the ch challenge on
-	the named auth domain.
+	the named auth domain for the given user.
+	If user is "", the first user in the key file is used.
 	Returns the user who authenticates and the status for authentication.
 	Always returns true when Auth is not enabled.
 */
-func ChallengeResponseOk(name, ch, resp string) (user string, ok bool) {
+func ChallengeResponseOk(name, user, ch, resp string) (string, bool) {
 	usr := u.Uid
 	if !Enabled {
 		return usr, true
 	}
-	if keys == nil || iv == nil {
+	if iv == nil {
 		return usr, false
 	}
-	usr = keys[0].Uid
-	key := keys[0].Key
-	if name != "" && name != "default" {
-		var err error
-		ks, err := LoadKey(KeyDir(), name)
-		if err != nil {
-			dbg.Warn("auth: loadkey %s: %s", name, err)
-			return usr, false
-		}
-		usr, key = ks[0].Uid, ks[0].Key
+	ks, err := domainKeys(name)
+	if err != nil {
+		dbg.Warn("auth: loadkey %s: %s", name, err)
+		return usr, false
+	}
+	k, ok := FindKey(ks, user)
+	if !ok {
+		return user, false
 	}
-	chresp, ok := encrypt(key, iv, []byte(ch))
+	chresp, ok := encrypt(k.Key, iv, []byte(ch))
 	if !ok || len(chresp) == 0 {
-		return usr, false
+		return k.Uid, false
 	}
-	return usr, fmt.Sprintf("%x", chresp) == resp
+	return k.Uid, fmt.Sprintf("%x", chresp) == resp
 }
 
 /*
@@ -419,6 +517,17 @@ func ChallengeResponseOk(name, ch, resp string) (user string, ok bool) {
 	to complete its part of the protocol, and selects the key for that user name
 	as kept in the key file. The iscaller argument indicates if it's the dialer or not.
 
+	If the dialer has a private key for the auth domain (see PrivKeyFile), public key
+	authentication is used instead. The dialer signs the challenges using its private key
+	and the callee checks the signature using the public key authorized for that user
+	(see PubKeysFile), which also defines the groups for the user.
+	The private key used is that for the user given by the shared key file, if any,
+	or for the local user, or the first one kept if there's none for the local user.
+	The callee signs the challenges as well, using the private key for its user,
+	and the dialer checks the signature using the public key authorized for the
+	callee's user. If there's no such key, authentication fails unless PkTrustTLS
+	is set, and then the dialer relies on TLS to identify the callee.
+
 	If there's no key, or TLS is not configured for the network, or auth is not enabled, c is left
 	undisturbed and an error is returned instead. The error is ErrDisabled when auth
 	is disabled.
@@ -469,6 +578,8 @@ func NoneAtServer(c ch.Conn, name string, proto ...string) (*Info, error) {
 
 	if there's no auth in just one side, this is an error.
 
+	if the cli auth msg is for pkauth, the protocol continues with pkconn.
+
 	3.
 	cli responds for the ch sent from the server using its own uid
 	srv responds for the ch sent from the client using the client's uid to encrypt
@@ -480,6 +591,8 @@ func NoneAtServer(c ch.Conn, name string, proto ...string) (*Info, error) {
 func conn(c ch.Conn, iscaller bool, name string, enabled bool, proto ...string) (*Info, error) {
 	ch := make([]byte, 16)
 	var k []byte
+	var ks, pks []Key
+	var pk *Key // private key used, if any
 	user := u.Uid
 	groups := []string{user}
 	if keys != nil {
@@ -491,15 +604,23 @@ func conn(c ch.Conn, iscaller bool, name string, enabled bool, proto ...string)
 		if TLSclient == nil || TLSserver == nil {
 			return nil, errors.New("no tls")
 		}
-		if name != "" && name != "default" {
-			var err error
-			ks, err := LoadKey(KeyDir(), name)
-			if err != nil {
-				return nil, fmt.Errorf("no key: %s", err)
-			}
+		pks, _ = LoadPrivKey(KeyDir(), name)
+		var err error
+		ks, err = domainKeys(name)
+		if err == nil {
 			user, k, groups = ks[0].Uid, ks[0].Key, ks[0].Gids
+		} else if len(pks) == 0 && name != "" && name != "default" {
+			return nil, fmt.Errorf("no key: %s", err)
 		}
-		if k == nil {
+		if key, ok := FindKey(pks, user); ok {
+			pk = &key
+		} else if len(pks) > 0 && k == nil {
+			pk = &pks[0]
+		}
+		if pk != nil && (iscaller || k == nil) {
+			user, groups = pk.Uid, pk.Gids
+		}
+		if k == nil && pk == nil {
 			return nil, errors.New("no key")
 		}
 		binary.LittleEndian.PutUint64(ch[0:], <-chc)
@@ -508,6 +629,7 @@ func conn(c ch.Conn, iscaller bool, name string, enabled bool, proto ...string)
 
 	// 1. send the auth msg and challenge
 	m := &msg{enabled: enabled, user: user, speaksfor: u.Uid, ch: ch}
+	m.pk = enabled && pk != nil
 	m.proto = map[string]bool{}
 	for _, s := range proto {
 		m.proto[s] = true
@@ -578,18 +700,19 @@ func conn(c ch.Conn, iscaller bool, name string, enabled bool, proto ...string)
 		close(c.In, ErrDisabled)
 		close(c.Out, ErrDisabled)
 		return info, ErrDisabled
+	case iscaller && m.pk, !iscaller && rm.pk:
+		// The caller decides to use public keys.
+		return pkconn(c, tc, iscaller, name, pk, m, rm, info)
 	}
 
 	// 3. respond (but server relies on the key for the user given by the caller).
 	if !iscaller {
 		k = nil
 		groups = nil
-		for _, key := range keys {
-			if key.Uid == rm.user {
-				k = key.Key
-				user = key.Uid
-				groups = key.Gids
-			}
+		if key, ok := FindKey(ks, rm.user); ok && rm.user != "" {
+			k = key.Key
+			user = key.Uid
+			groups = key.Gids
 		}
 		for _, g := range groups {
 			info.Gids[g] = true
@@ -754,13 +877,12 @@ func init() {
 		}
 	}()
 
-	keys, err = LoadKey(dir, "")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "net/auth: loadkey: %s\n", err)
-		return
-	}
 	iv, err = hex.DecodeString("12131415161718191a1b1c1d1e1f1011")
 	if err != nil {
 		panic(err)
 	}
+	keys, err = LoadKey(dir, "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "net/auth: loadkey: %s\n", err)
+	}
 }
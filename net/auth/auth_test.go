@@ -4,7 +4,12 @@ import (
 	"clive/ch"
 	"clive/dbg"
 	"clive/net"
+	"clive/u"
+	"crypto/tls"
 	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"testing"
 )
 
@@ -204,3 +209,171 @@ func TestMuxDisabledAuth(t *testing.T) {
 	close(ec)
 	<-donec
 }
+
+func TestPkAuth(t *testing.T) {
+	debug = testing.Verbose()
+	tdir, err := ioutil.TempDir("", "pkauth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+	ohome := u.Home
+	u.Home = tdir
+	defer func() {
+		u.Home = ohome
+	}()
+	if TLSclient == nil || TLSserver == nil {
+		otc, ots := TLSclient, TLSserver
+		TLSclient, TLSserver = &tls.Config{}, &tls.Config{}
+		defer func() {
+			TLSclient, TLSserver = otc, ots
+		}()
+	}
+	if err := os.MkdirAll(KeyDir(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	pub, err := NewPrivKey("", "pktest", "nemo", "nemo", "sys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(PrivKeyFile(KeyDir(), "pktest"))
+	if err != nil || fi.Mode().Perm() != 0600 {
+		t.Fatalf("private key file: %v %v", fi, err)
+	}
+	if fis, _ := ioutil.ReadDir(KeyDir()); len(fis) != 1 {
+		t.Fatalf("%d files in key dir", len(fis))
+	}
+	if err := AuthorizeKey("", "pktest", "nemo", pub, "nemo", "sys"); err != nil {
+		t.Fatal(err)
+	}
+	for _, fail := range []bool{false, true} {
+		if fail {
+			// another key for the same user is not ok
+			if _, err := NewPrivKey("", "pktest", "nemo"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		c1, c2 := ch.NewPipePair(5)
+		ec := make(chan error, 1)
+		go func() {
+			_, err := AtClient(c1, "pktest", "foo")
+			ec <- err
+		}()
+		ai, err := AtServer(c2, "pktest", "foo")
+		cerr := <-ec
+		printf("server ai %v err %v; client err %v\n", ai, err, cerr)
+		if fail {
+			if err == nil {
+				t.Fatal("pk auth didn't fail")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cerr != nil {
+			t.Fatal(cerr)
+		}
+		if !ai.Ok || ai.Uid != "nemo" || !ai.InGroup("sys") {
+			t.Fatalf("bad auth info %v", ai)
+		}
+	}
+
+	// servers without a key authorized for them are trusted only
+	// under PkTrustTLS
+	defer func() {
+		PkTrustTLS = false
+	}()
+	for _, trust := range []bool{false, true} {
+		PkTrustTLS = trust
+		c1, c2 := ch.NewPipePair(5)
+		go func() {
+			<-c2.In
+			m := &msg{enabled: true, pk: true, user: "srv", speaksfor: "srv",
+				proto: map[string]bool{"foo": true}, ch: make([]byte, 16)}
+			c2.Out <- m.pack()
+			<-c2.In
+			c2.Out <- []byte("nokey")
+			close(c2.Out)
+		}()
+		ai, err := AtClient(c1, "pktest", "foo")
+		printf("trust %v: client ai %v err %v\n", trust, ai, err)
+		if !trust && err == nil {
+			t.Fatal("unknown server was trusted")
+		}
+		if trust && err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMultiUserKeys(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+	ohome := u.Home
+	u.Home = tdir
+	defer func() {
+		u.Home = ohome
+	}()
+	if err := os.MkdirAll(KeyDir(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	users := []string{"nemo", "elf", "gandalf"}
+	for _, usr := range users {
+		if err := SaveKey("", "multi", usr, usr+"secret", usr, "team"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ks, err := LoadKey("", "multi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ks) != len(users) {
+		t.Fatalf("got %d keys", len(ks))
+	}
+	for i, usr := range users {
+		k, ok := FindKey(ks, usr)
+		if !ok || ks[i].Uid != usr || k.Gids[1] != "team" {
+			t.Fatalf("bad key for %s: %v", usr, ks[i])
+		}
+		ch := "1234"
+		resp, _ := encrypt(k.Key, iv, []byte(ch))
+		ru, ok := ChallengeResponseOk("multi", usr, ch, fmt.Sprintf("%x", resp))
+		if !ok || ru != usr {
+			t.Fatalf("challenge response for %s failed (%s)", usr, ru)
+		}
+		if ru, ok := ChallengeResponseOk("multi", "nobody", ch, fmt.Sprintf("%x", resp)); ok {
+			t.Fatalf("challenge response for nobody worked (%s)", ru)
+		}
+	}
+	if err := DelKey("", "multi", "elf"); err != nil {
+		t.Fatal(err)
+	}
+	ks, err = LoadKey("", "multi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := FindKey(ks, "elf"); ok || len(ks) != 2 {
+		t.Fatalf("del key failed: %v", ks)
+	}
+
+	// damaged files are not updated
+	file := KeyFile(KeyDir(), "multi")
+	dat, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := append(dat, "bad\nzz\n"...)
+	if err := ioutil.WriteFile(file, bad, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveKey("", "multi", "elf", "elfsecret"); err == nil {
+		t.Fatal("damaged key file updated")
+	}
+	if ndat, _ := ioutil.ReadFile(file); string(ndat) != string(bad) {
+		t.Fatal("damaged key file changed")
+	}
+}
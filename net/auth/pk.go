@@ -0,0 +1,271 @@
+package auth
+
+import (
+	"bytes"
+	"clive/ch"
+	"clive/dbg"
+	"clive/x/github.com/agl/ed25519"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Return the path to the file at dir where the ed25519 private keys for
+// the auth domain named are kept.
+// The file format is that of KeyFile, but keeps private keys.
+func PrivKeyFile(dir, name string) string {
+	return KeyFile(dir, name) + ".ed25519"
+}
+
+// Return the path to the file at dir where the ed25519 public keys
+// authorized for the auth domain named are kept.
+// The file format is that of KeyFile, but keeps public keys.
+func PubKeysFile(dir, name string) string {
+	return KeyFile(dir, name) + ".authorized"
+}
+
+// Generate a new ed25519 key pair for the given user in the named auth domain
+// and save the private key at PrivKeyFile(dir, name).
+// The key is added if there is no such user in the auth domain or replaced
+// if the user already exists.
+// Returns the public key, to be authorized at the servers (see AuthorizeKey).
+func NewPrivKey(dir, name, user string, groups ...string) ([]byte, error) {
+	if dir == "" {
+		dir = KeyDir()
+	}
+	if name == "" {
+		name = "default"
+	}
+	pub, priv, err := ed25519.GenerateKey(crand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	old, err := keysToUpdate(LoadPrivKey(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	k := Key{Uid: user, Gids: groups, Key: priv[:]}
+	if err := writeKeys(PrivKeyFile(dir, name), addKey(old, k)); err != nil {
+		return nil, err
+	}
+	return pub[:], nil
+}
+
+// Remove the private key for the given user in the named auth domain.
+// It is not an error if the user has no key.
+func DelPrivKey(dir, name, user string) error {
+	if dir == "" {
+		dir = KeyDir()
+	}
+	if name == "" {
+		name = "default"
+	}
+	old, err := LoadPrivKey(dir, name)
+	if err != nil {
+		return err
+	}
+	return writeKeys(PrivKeyFile(dir, name), delKey(old, user))
+}
+
+// Load the private keys for the named auth domain kept at dir.
+func LoadPrivKey(dir, name string) ([]Key, error) {
+	if dir == "" {
+		dir = KeyDir()
+	}
+	if name == "" {
+		name = "default"
+	}
+	ks, err := readKeys(PrivKeyFile(dir, name))
+	for _, k := range ks {
+		if len(k.Key) != ed25519.PrivateKeySize {
+			return nil, fmt.Errorf("%s: bad private key", k.Uid)
+		}
+	}
+	return ks, err
+}
+
+// Authorize the public key for the given user in the named auth domain
+// by adding it to PubKeysFile(dir, name).
+// Groups are those known for the user when it authenticates.
+// The key is added if there is no such user in the auth domain or replaced
+// if the user already exists.
+func AuthorizeKey(dir, name, user string, pub []byte, groups ...string) error {
+	if dir == "" {
+		dir = KeyDir()
+	}
+	if name == "" {
+		name = "default"
+	}
+	if len(pub) != ed25519.PublicKeySize {
+		return errors.New("bad public key")
+	}
+	old, err := keysToUpdate(LoadPubKeys(dir, name))
+	if err != nil {
+		return err
+	}
+	return writeKeys(PubKeysFile(dir, name), addKey(old, Key{Uid: user, Gids: groups, Key: pub}))
+}
+
+// Remove the public key authorized for the given user in the named auth domain.
+// It is not an error if the user has no key.
+func DelPubKey(dir, name, user string) error {
+	if dir == "" {
+		dir = KeyDir()
+	}
+	if name == "" {
+		name = "default"
+	}
+	old, err := LoadPubKeys(dir, name)
+	if err != nil {
+		return err
+	}
+	return writeKeys(PubKeysFile(dir, name), delKey(old, user))
+}
+
+// Load the public keys authorized for the named auth domain kept at dir.
+func LoadPubKeys(dir, name string) ([]Key, error) {
+	if dir == "" {
+		dir = KeyDir()
+	}
+	if name == "" {
+		name = "default"
+	}
+	ks, err := readKeys(PubKeysFile(dir, name))
+	for _, k := range ks {
+		if len(k.Key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%s: bad public key", k.Uid)
+		}
+	}
+	return ks, err
+}
+
+// Parse a public key as printed by the auth command (in hexa).
+func ParsePubKey(s string) ([]byte, error) {
+	k, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(k) != ed25519.PublicKeySize {
+		return nil, errors.New("bad public key")
+	}
+	return k, nil
+}
+
+// Message signed by a peer: both challenges, the one we are responding to first.
+func pkmsg(ch, peerch []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("clive pkauth")
+	buf.WriteByte(0)
+	buf.Write(ch)
+	buf.Write(peerch)
+	return buf.Bytes()
+}
+
+func pksign(k, ch, peerch []byte) []byte {
+	var priv [ed25519.PrivateKeySize]byte
+	copy(priv[:], k)
+	sig := ed25519.Sign(&priv, pkmsg(ch, peerch))
+	return sig[:]
+}
+
+func pkverify(k, ch, peerch, sig []byte) bool {
+	if len(k) != ed25519.PublicKeySize || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	var pub [ed25519.PublicKeySize]byte
+	var xsig [ed25519.SignatureSize]byte
+	copy(pub[:], k)
+	copy(xsig[:], sig)
+	return ed25519.Verify(&pub, pkmsg(ch, peerch), &xsig)
+}
+
+/*
+	Steps 3 and 4 of the clive cr protocol when using public keys:
+
+	3.
+	cli signs both challenges (the srv one first) using its private key.
+	srv scans for the public key of the user given by the cli, and
+		signs both challenges (the cli one first) using its private key, if any.
+
+	4.
+	cli checks the signature using the public key for the srv user,
+		(or relies on tls if there's none and PkTrustTLS is set)
+		hangup or it's ok
+	srv checks the signature using the public key for the cli user,
+		hangup or it's ok
+*/
+func pkconn(c ch.Conn, tc <-chan time.Time, iscaller bool, name string,
+	pk *Key, m, rm *msg, info *Info) (*Info, error) {
+	var peer *Key
+	aks, _ := LoadPubKeys(KeyDir(), name)
+	if k, ok := FindKey(aks, rm.user); ok && rm.user != "" {
+		peer = &k
+	}
+	if !iscaller {
+		if peer == nil {
+			err := errors.New("wrong user/key")
+			close(c.In, err)
+			close(c.Out, err)
+			return info, err
+		}
+		for _, g := range peer.Gids {
+			info.Gids[g] = true
+		}
+	} else if peer == nil && !PkTrustTLS {
+		err := fmt.Errorf("%s: no key for %s", ErrFailed, rm.user)
+		close(c.In, err)
+		close(c.Out, err)
+		return info, err
+	}
+
+	// 3. sign the challenges (a server without keys can't).
+	sig := []byte("nokey")
+	if pk != nil {
+		sig = pksign(pk.Key, rm.ch, m.ch)
+	}
+	select {
+	case <-tc:
+		close(c.Out, ErrTimedOut)
+		close(c.In, ErrTimedOut)
+		return info, ErrTimedOut
+	case c.Out <- sig:
+		if cerror(c.Out) != nil {
+			err := fmt.Errorf("auth: %s", cerror(c.Out))
+			close(c.In, err)
+			return info, err
+		}
+	}
+
+	// 4. read the remote signature
+	var rsig []byte
+	select {
+	case <-tc:
+		close(c.Out, ErrTimedOut)
+		close(c.In, ErrTimedOut)
+		return info, ErrTimedOut
+	case xsig := <-c.In:
+		rsig, _ = xsig.([]byte)
+		if len(rsig) == 0 {
+			close(c.In, ErrFailed)
+			close(c.Out, ErrFailed)
+			return info, fmt.Errorf("%s: empty reply", ErrFailed)
+		}
+	}
+
+	// check the signature
+	if peer == nil {
+		dprintf("no key for %s: relying on tls\n", rm.user)
+		info.Ok = true
+		return info, nil
+	}
+	if !pkverify(peer.Key, m.ch, rm.ch, rsig) {
+		dbg.Warn("auth failed: %s (as %s)", info.SpeaksFor, rm.user)
+		close(c.In, ErrFailed)
+		close(c.Out, ErrFailed)
+		return info, fmt.Errorf("%s: bad signature", ErrFailed)
+	}
+	info.Ok = true
+	return info, nil
+}
@@ -38,6 +38,19 @@ func checkOrigin(config *websocket.Config, req *http.Request) (err error) {
 	return err
 }
 
+// Split the auth cookie into user, challenge and response.
+// Old cookies have no user, and the first user in the key file is used.
+func cookieAuth(val string) (usr, ch, resp string, ok bool) {
+	toks := strings.SplitN(val, ":", 3)
+	switch len(toks) {
+	case 2:
+		return "", toks[0], toks[1], true
+	case 3:
+		return toks[0], toks[1], toks[2], true
+	}
+	return "", "", "", false
+}
+
 // Authenticate a websocket before servicing it.
 func AuthWebSocketHandler(h websocket.Handler) http.HandlerFunc {
 	hndler := func(w http.ResponseWriter, r *http.Request) {
@@ -48,14 +61,13 @@ func AuthWebSocketHandler(h websocket.Handler) http.HandlerFunc {
 				http.Error(w, "auth failed", 403)
 				return
 			}
-			toks := strings.SplitN(string(clive.Value), ":", 2)
-			if len(toks) < 2 {
+			usr, ch, resp, ok := cookieAuth(clive.Value)
+			if !ok {
 				cmd.Warn("wax/auth: wrong cookie")
 				http.Error(w, "auth failed", 403)
 				return
 			}
-			ch, resp := toks[0], toks[1]
-			u, ok := auth.ChallengeResponseOk("wax", ch, resp)
+			u, ok := auth.ChallengeResponseOk("wax", usr, ch, resp)
 			if !ok {
 				cmd.Warn("wax/auth: failed for %s", u)
 				http.Error(w, "auth failed", 403)
@@ -82,14 +94,13 @@ func AuthHandler(fn http.HandlerFunc) http.HandlerFunc {
 			authFailed(w, r)
 			return
 		}
-		toks := strings.SplitN(string(clive.Value), ":", 2)
-		if len(toks) < 2 {
+		usr, ch, resp, ok := cookieAuth(clive.Value)
+		if !ok {
 			cmd.Warn("wax/auth: wrong cookie")
 			authFailed(w, r)
 			return
 		}
-		ch, resp := toks[0], toks[1]
-		u, ok := auth.ChallengeResponseOk("wax", ch, resp)
+		u, ok := auth.ChallengeResponseOk("wax", usr, ch, resp)
 		if !ok {
 			cmd.Warn("wax/auth: failed for %s", u)
 			authFailed(w, r)
@@ -124,13 +135,14 @@ func serveLoginFor(proceedto string) {
 		$(function(){
 			$("#dialog").on('submit', function(e) {
 				var salt ='ltsa';
+				var usr = $("#user").val();
 				var usrkey = $("#pass").val();
 				var key = CryptoJS.PBKDF2(usrkey, salt, { keySize: 256/32, iterations: 1000});
 				usrkey = "XXXXXXXXXXXX";
 				var ch = Math.random().toPrecision(16).slice(2);
 				var iv  = CryptoJS.enc.Hex.parse('12131415161718191a1b1c1d1e1f1011');
 				var enc  = CryptoJS.AES.encrypt(ch, key, { iv: iv, padding: CryptoJS.pad.Pkcs7});
-				var c =  "clive=" + ch + ":" + enc.ciphertext + ";secure=secure";
+				var c =  "clive=" + usr + ":" + ch + ":" + enc.ciphertext + ";secure=secure";
 				document.cookie = c;
 				clive = c;
 				window.location = "` + proceedto + `";
@@ -143,6 +155,8 @@ func serveLoginFor(proceedto string) {
 		</script>
 		<p><center><b><tt>
 		<form name="form" id="dialog" action="" method="get" >
+			<label for="user">Clive ink user: </label>
+			<input name="user" id="user" type="text"/ ><br>
 			<label for="box">Clive ink password: </label>
 			<input name="box" id="pass" type="password"/ ></form></tt></b></center>
 `
@@ -19,7 +19,8 @@ function CliveCtlr() {
 	$(this.d).addClass("clivectl");
 	var self = this;
 	// use self here, because post will be bound also to this.d
-	this.post = function(args) {
+	// vers, if given, is the version for the event (see text.js).
+	this.post = function(args, vers) {
 		var ws = self.ws;
 		if(!ws){
 			console.log("post: no ws");
@@ -32,12 +33,10 @@ function CliveCtlr() {
 		if(!self.vers) {
 			self.vers = 0;
 		}
-		// cut advances the vers (might del nothing)
-		// this is for text and shouldn't be here.
-		if(args[0] == "eins" || args[0] == "edel") {
-			self.vers++;
+		if(vers === undefined) {
+			vers = self.vers;
 		}
-		var ev = {Id: self.cid, Src: self.id, Vers: self.vers, Args: args};
+		var ev = {Id: self.cid, Src: self.id, Vers: vers, Args: args};
 		var msg = JSON.stringify(ev);
 		try {
 			self.ws.send(msg);
@@ -45,14 +44,6 @@ function CliveCtlr() {
 		}catch(ex){
 			console.log("post: " + ex);
 		}
-		// if this is a cut, it implies a del and we
-		// must advance our vers, the event didn't
-		// advance the vers.
-		// Same for paste.
-		// this is for text and shouldn't be here.
-		if(args[0] == "ecut" || args[0] == "epaste") {
-			ev.Vers++;
-		}
 		return ev;
 	};
 
@@ -198,6 +198,7 @@ function Lines(els) {
 	this.scrollup = function(n){ return 0;};
 	this.redrawtext = function(){};
 	this.wrapoff = function(t){ return t.length; };
+	this.peermarks = function(){ return []; };
 	this.frlninsdel = function(ln, ninsdel){};
 
 	// pos0 is optional (0 by default).
@@ -793,6 +794,36 @@ function DrawLines(c) {
 
 	// draw a line and return false if it's out of the draw space.
 	this.drawline = function(ln) {
+		if(!this.drawlntext(ln)) {
+			return false;
+		}
+		this.drawpeers(ln);
+		return true;
+	};
+
+	// draw ticks for the positions in peermarks (other views editing
+	// the text) that are in the line.
+	this.drawpeers = function(ln) {
+		var ps = this.peermarks();
+		if(ps.length == 0) {
+			return;
+		}
+		var ctx = this.ctx;
+		var y = (ln.lni-this.ln0.lni)*this.fontht;
+		var old = ctx.fillStyle;
+		ctx.fillStyle = "#E07000";
+		for(var i = 0; i < ps.length; i++) {
+			var pos = ps[i];
+			if(pos < ln.off || pos > ln.off + ln.txt.length) {
+				continue;
+			}
+			var x = this.posdx(ln.txt, pos - ln.off) + this.marginsz;
+			ctx.fillRect(x - this.tscale/2, y, this.tscale, this.fontht);
+		}
+		ctx.fillStyle = old;
+	};
+
+	this.drawlntext = function(ln) {
 		var ctx = this.ctx;
 		var lnht = this.fontht;
 		var avail = this.c.width - 2*this.marginsz - 1;
@@ -30,6 +30,84 @@ function dontbubble(e) {
 	}
 }
 
+// Operational transforms for concurrent edits, as done by ot.go in ink.
+// Edits are {ins: bool, off: int, n: int, data: string, src: string, name: string},
+// with n being the number of runes inserted or deleted.
+
+// return a copy of e at off, n
+function otat(e, off, n) {
+	if(!e.ins && n <= 0) {
+		return [];
+	}
+	return [{ins: e.ins, off: off, n: n, data: e.data, src: e.src, name: e.name}];
+}
+
+function otdeladj(p, off, n) {
+	if(p <= off) {
+		return p;
+	}
+	if(p <= off+n) {
+		return off;
+	}
+	return p - n;
+}
+
+// transform insert i and delete d against each other
+function otxinsdel(i, d) {
+	var dend = d.off + d.n;
+	if(i.off <= d.off) {
+		return [otat(i, i.off, i.n), otat(d, d.off+i.n, d.n)];
+	}
+	if(i.off >= dend) {
+		return [otat(i, i.off-d.n, i.n), otat(d, d.off, d.n)];
+	}
+	var n0 = i.off - d.off;
+	var ds = otat(d, i.off+i.n, d.n-n0).concat(otat(d, d.off, n0));
+	return [otat(i, d.off, i.n), ds];
+}
+
+// given edits a and b made to the same text, return [a', b'],
+// being a' to be applied after b, and b' to be applied after a.
+function otxpair(a, b) {
+	if(a.ins && b.ins) {
+		if(a.off < b.off || a.off == b.off && a.src < b.src) {
+			return [otat(a, a.off, a.n), otat(b, b.off+a.n, b.n)];
+		}
+		return [otat(a, a.off+b.n, a.n), otat(b, b.off, b.n)];
+	}
+	if(a.ins) {
+		return otxinsdel(a, b);
+	}
+	if(b.ins) {
+		var x = otxinsdel(b, a);
+		return [x[1], x[0]];
+	}
+	var a0 = otdeladj(a.off, b.off, b.n);
+	var a1 = otdeladj(a.off+a.n, b.off, b.n);
+	var b0 = otdeladj(b.off, a.off, a.n);
+	var b1 = otdeladj(b.off+b.n, a.off, a.n);
+	return [otat(a, a0, a1-a0), otat(b, b0, b1-b0)];
+}
+
+// the same for lists of edits.
+function otxlist(as, bs) {
+	if(as.length == 0 || bs.length == 0) {
+		return [as, bs];
+	}
+	if(as.length == 1 && bs.length == 1) {
+		return otxpair(as[0], bs[0]);
+	}
+	var x0, x1;
+	if(as.length > 1) {
+		x0 = otxlist(as.slice(0, 1), bs);
+		x1 = otxlist(as.slice(1), x0[1]);
+		return [x0[0].concat(x1[0]), x1[1]];
+	}
+	x0 = otxlist(as, bs.slice(0, 1));
+	x1 = otxlist(x0[0], bs.slice(1));
+	return [x1[0], x0[1].concat(x1[1])];
+}
+
 // A frame of lines using the Clive ink framework.
 // d is the div, c is the canvas, cid and id are the ink ids.
 // This will have to be rewritten when we rewrite ink js code.
@@ -43,6 +121,13 @@ function CliveText(d, c, cid, id) {
 	this.vers = 0;
 	this.noedits = false;
 
+	// Our edits are posted one at a time, waiting for an eack
+	// before posting the next one. Those from others are transformed
+	// against ours not yet acknowledged (sent) or posted (pending).
+	this.sent = [];
+	this.pending = [];
+	this.waiting = false;
+
 	this.islocked = false;
 	this.locking = false;
 	this.mustunlock = false;
@@ -209,6 +294,144 @@ function CliveText(d, c, cid, id) {
 			return delp0;
 		return pos - (delp1 - delp0);
 	};
+
+	this.insat = function(p0, data, fromserver) {
+		var op0 = this.p0;
+		var op1 = this.p1;
+		if(op0 != op1) {
+			this.setsel(op0, op0);
+		}
+		this.p0 = p0;
+		this.p1 = p0;
+		this.ins(data, false);
+		if(op0 > p0)
+			op0 += data.length;
+		if(op1 > p0)
+			op1 += data.length;
+		if(fromserver) {
+			this.setsel(op0, op1, false);
+		}
+		if(!this.userresized && data.indexOf('\n') >= 0) {
+			this.autoresize();
+		} 
+	};
+
+	this.delat = function(p0, p1, fromserver) {
+		var op0 = this.p0;
+		var op1 = this.p1;
+		this.p0 = p0;
+		this.p1 = p1;
+		try{
+			this.del(false);
+		}catch(ex){
+			console.log(this.divid, "apply: del: " + ex);
+		}
+		op0 = this.adjdel(op0, p0, p1);
+		op1 = this.adjdel(op1, p0, p1);
+		if(fromserver) {
+			this.setsel(op0, op1, false);
+		}
+	};
+
+	// Edits from the server must be for the next version.
+	this.outofsync = function(ev) {
+		if(!ev.Vers || ev.Vers == this.vers+1) {
+			return false;
+		}
+		console.log("OUT OF SYNC", ev.Args, "v", ev.Vers, this.vers);
+		this.post(["needreload"]);
+		return true;
+	};
+
+	// Transform an edit from the server against ours, returning
+	// the edits to apply.
+	this.xremote = function(e) {
+		var x = otxlist(this.sent, [e]);
+		this.sent = x[0];
+		x = otxlist(this.pending, x[1]);
+		this.pending = x[0];
+		return x[1];
+	};
+
+	// Apply an edit made here and post it when we can.
+	this.edit = function(args) {
+		var e;
+		switch(args[0]) {
+		case "eins":
+			e = {ins: true, off: parseInt(args[2]), n: args[1].length, data: args[1]};
+			break;
+		case "epaste":
+			// the server inserts the snarf and sends it back.
+			e = {ins: true, off: parseInt(args[1]), n: 0, data: ""};
+			break;
+		default:
+			var p0 = parseInt(args[1]);
+			e = {ins: false, off: p0, n: parseInt(args[2])-p0};
+		}
+		e.src = this.id;
+		e.name = args[0];
+		if(args[0] != "epaste") {
+			try {
+				this.apply({Args: args}, false);
+			}catch(ex){
+				console.log("txt apply: " + ex);
+			}
+		}
+		this.pending.push(e);
+		this.sendedit();
+	};
+
+	this.sendedit = function() {
+		if(this.waiting || this.pending.length == 0) {
+			return;
+		}
+		var e = this.pending.shift();
+		this.sent = [e];
+		this.waiting = true;
+		var args;
+		switch(e.name) {
+		case "eins":
+			args = ["eins", e.data, ""+e.off];
+			break;
+		case "epaste":
+			args = ["epaste", ""+e.off, ""+e.off];
+			break;
+		default:
+			args = [e.name, ""+e.off, ""+(e.off+e.n)];
+		}
+		this.post(args, this.vers+1);
+	};
+
+	// Is this the name of another view, or of one of its marks?
+	this.ispeer = function(name) {
+		var id = this.id;
+		if(name == id || name == id+"p0" || name == id+"p1") {
+			return false;
+		}
+		return name.indexOf(this.cid+"_") == 0;
+	};
+
+	// Positions for the ticks of other views, see lines.js.
+	this.peermarks = function() {
+		var ps = [];
+		for(var i = 0; i < this.marks.length; i++) {
+			var m = this.marks[i];
+			if(this.ispeer(m.name) && m.name.slice(-2) == "p0") {
+				ps.push(m.pos);
+			}
+		}
+		return ps;
+	};
+
+	// Show the tick for the view src at pos.
+	this.peer = function(src, pos) {
+		if(!src || !this.ispeer(src)) {
+			return;
+		}
+		this.setmark(src+"p0", pos);
+		this.setmark(src+"p1", pos);
+		this.redrawtext();
+	};
 	
 	this.apply = function(ev, fromserver) {
 		if(!ev || !ev.Args || !ev.Args[0]){
@@ -285,6 +508,16 @@ function CliveText(d, c, cid, id) {
 				console.log(this.id, "apply: no mark", arg[1]);
 				break;
 			}
+			if(arg.length > 2 && fromserver) {
+				if(this.outofsync(ev)) {
+					delete this.markinsdata;
+					break;
+				}
+				var data = this.markinsdata.join("");
+				var es = this.xremote({ins: true, off: parseInt(arg[2]),
+					n: data.length, data: data, src: ev.Src});
+				m.pos = es[0].off;
+			}
 			var op0 = this.p0;
 			var op1 = this.p1;
 			if(op0 != op1) {
@@ -335,13 +568,14 @@ function CliveText(d, c, cid, id) {
 				console.log(this.id, "apply: short ins");
 				break;
 			}
-			if(ev.Vers && fromserver && ev.Vers != this.vers+1){
-				console.log("OUT OF SYNC", ev.Args, "v", ev.Vers, this.vers);
-				this.post(["needreload"]);
+			if(this.outofsync(ev)) {
 				delete this.einsdata;
 				break;
 			}
-			var p0 = parseInt(arg[1]);
+			var data = this.einsdata.join("");
+			var es = this.xremote({ins: true, off: parseInt(arg[1]),
+				n: data.length, data: data, src: ev.Src});
+			var p0 = es[0].off;
 			var op0 = this.p0;
 			var op1 = this.p1;
 			if(op0 != op1) {
@@ -372,62 +606,40 @@ function CliveText(d, c, cid, id) {
 				console.log(this.id, "apply: short ins");
 				break;
 			}
-			if(ev.Vers && fromserver && ev.Vers != this.vers+1){
-				console.log("OUT OF SYNC", ev.Args, "v", ev.Vers, this.vers);
-				this.post(["needreload"]);
+			if(!fromserver) {
+				this.insat(parseInt(arg[2]), arg[1], false);
 				break;
 			}
-			var p0 = parseInt(arg[2]);
-			var op0 = this.p0;
-			var op1 = this.p1;
-			if(op0 != op1) {
-				this.setsel(op0, op0);
-			}
-			this.p0 = p0;
-			this.p1 = p0;
-			this.ins(arg[1], false);
-			if(op0 > p0)
-				op0 += arg[1].length;
-			if(op1 > p0)
-				op1 += arg[1].length;
-			if(fromserver) {
-				this.setsel(op0, op1, false);
-			}
-			if(ev.Vers) {
-				this.vers = ev.Vers;
+			if(this.outofsync(ev)) {
+				break;
 			}
-			if(!this.userresized && arg[1].indexOf('\n') >= 0) {
-				this.autoresize();
-			} 
+			var es = this.xremote({ins: true, off: parseInt(arg[2]),
+				n: arg[1].length, data: arg[1], src: ev.Src});
+			this.insat(es[0].off, arg[1], true);
+			this.vers = ev.Vers;
+			this.peer(ev.Src, es[0].off + arg[1].length);
 			break;
 		case "edel":
 			if(arg.length < 3){
 				console.log(this.id, "apply: short del");
 				break;
 			}
-			if(ev.Vers && fromserver && ev.Vers != this.vers+1){
-				console.log("OUT OF SYNC", ev.Args, "v", ev.Vers, this.vers);
-				this.post(["needreload"]);
-			}
 			var p0 = parseInt(arg[1]);
 			var p1 = parseInt(arg[2]);
-			var op0 = this.p0;
-			var op1 = this.p1;
-			this.p0 = p0;
-			this.p1 = p1;
-			try{
-				this.del(false);
-			}catch(ex){
-				console.log(this.divid, "apply: del: " + ex);
+			if(!fromserver) {
+				this.delat(p0, p1, false);
+				break;
 			}
-			op0 = this.adjdel(op0, p0, p1);
-			op1 = this.adjdel(op1, p0, p1);
-			if(fromserver) {
-				this.setsel(op0, op1, false);
+			if(this.outofsync(ev)) {
+				break;
 			}
-			if(ev.Vers) {
-				this.vers = ev.Vers;
+			var es = this.xremote({ins: false, off: p0, n: p1-p0, src: ev.Src});
+			for(var i = 0; i < es.length; i++) {
+				this.delat(es[i].off, es[i].off+es[i].n, true);
+				p0 = es[i].off;
 			}
+			this.vers = ev.Vers;
+			this.peer(ev.Src, p0);
 			break;
 		case "ecut":
 			try{
@@ -435,8 +647,15 @@ function CliveText(d, c, cid, id) {
 			}catch(ex){
 				console.log(this.id, "apply: cut: " + ex);
 			}
-			if(ev.Vers)
-				this.vers = ev.Vers;
+			break;
+		case "eack":
+			if(arg.length < 2 || arg[1] != this.id) {
+				break;
+			}
+			this.vers = ev.Vers;
+			this.sent = [];
+			this.waiting = false;
+			this.sendedit();
 			break;
 		case "reload":
 			this.reloadln0 = this.ln0.lni;
@@ -465,6 +684,9 @@ function CliveText(d, c, cid, id) {
 				break;
 			}
 			this.vers = parseInt(arg[1]);
+			this.sent = [];
+			this.pending = [];
+			this.waiting = false;
 			if(this.reloadln0) {
 				this.ln0 = this.seekln(this.reloadln0);
 				this.reloadln0 = 0;
@@ -485,6 +707,9 @@ function CliveText(d, c, cid, id) {
 			}
 			var pos = parseInt(arg[2]);
 			this.setmark(arg[1], pos);
+			if(this.ispeer(arg[1])) {
+				this.redrawtext();
+			}
 			break;
 		case "sel":
 			if(arg.length < 3){
@@ -505,6 +730,9 @@ function CliveText(d, c, cid, id) {
 				break;
 			}
 			this.delmark(arg[1]);
+			if(this.ispeer(arg[1])) {
+				this.redrawtext();
+			}
 			break;
 		case "close":
 			this.ws.close();
@@ -516,6 +744,10 @@ function CliveText(d, c, cid, id) {
 	};
 
 	this.Post = function(e) {
+		if(e[0] == "eins" || e[0] == "edel" || e[0] == "ecut" || e[0] == "epaste") {
+			this.edit(e);
+			return;
+		}
 		var ev = this.post(e);
 		if(ev){
 			try {
@@ -721,7 +953,7 @@ function CliveText(d, c, cid, id) {
 				if(this.p0 != this.p1){
 					this.Post(["edel", ""+this.p0, ""+this.p1]);
 				}
-				this.post(["epaste", ""+this.p0, ""+this.p1]);
+				this.Post(["epaste", ""+this.p0, ""+this.p1]);
 				return false;
 			}
 			break;
@@ -1096,7 +1328,7 @@ function CliveText(d, c, cid, id) {
 				if(self.p0 != self.p1){
 					self.Post(["edel", ""+self.p0, ""+self.p1]);
 				}
-				self.post(["epaste", ""+self.p0, ""+self.p1]);
+				self.Post(["epaste", ""+self.p0, ""+self.p1]);
 			}
 			if(self.buttons == 1+8){
 				wassel = false;
@@ -0,0 +1,167 @@
+package ink
+
+import (
+	"clive/txt"
+	"fmt"
+)
+
+/*
+	Concurrent edits are transformed against each other
+	(operational transforms) instead of being rejected.
+
+	Views apply their edits right away and post them one at a time,
+	tagged with the version they were made for (the last version
+	seen by the view plus one), waiting for an eack event before
+	posting the next one.
+	If the text is no longer at the previous version, the edit is
+	transformed against those made since then, which are kept in
+	a log, and the result is applied and reflected instead.
+	Views transform the edits they get against those they did not
+	post or did not see acknowledged yet, in the same way
+	(see js/text.js), and all of them converge to the same text.
+*/
+
+// An edit, as seen by the transforms
+struct otEdit {
+	op   txt.Tedit
+	off  int
+	n    int    // # of runes inserted or deleted
+	data []rune // runes inserted, if known
+	src  string // who made the edit, to order inserts at the same point
+}
+
+const maxLog = 1024 // max # of edits kept to transform against
+
+func (e *otEdit) end() int {
+	return e.off + e.n
+}
+
+// Return a copy of e at off, n
+func (e *otEdit) at(off, n int) []*otEdit {
+	if e.op == txt.Edel && n <= 0 {
+		return nil
+	}
+	ne := *e
+	ne.off, ne.n = off, n
+	return []*otEdit{&ne}
+}
+
+func (e *otEdit) String() string {
+	if e.op == txt.Eins {
+		return fmt.Sprintf("%s ins %q at %d", e.src, string(e.data), e.off)
+	}
+	return fmt.Sprintf("%s del %d at %d", e.src, e.n, e.off)
+}
+
+// Return the position for p after deleting n runes at off.
+func deladj(p, off, n int) int {
+	switch {
+	case p <= off:
+		return p
+	case p <= off+n:
+		return off
+	}
+	return p - n
+}
+
+// Transform insert i and delete d against each other
+func xinsdel(i, d *otEdit) ([]*otEdit, []*otEdit) {
+	switch {
+	case i.off <= d.off:
+		return i.at(i.off, i.n), d.at(d.off+i.n, d.n)
+	case i.off >= d.end():
+		return i.at(i.off-d.n, i.n), d.at(d.off, d.n)
+	}
+	// The insert is within the deleted text: keep the inserted runes
+	// and delete around them, the last piece first.
+	n0 := i.off - d.off
+	ds := d.at(i.off+i.n, d.n-n0)
+	return i.at(d.off, i.n), append(ds, d.at(d.off, n0)...)
+}
+
+// Given edits a and b, made to the same text, return a as it must be
+// applied after b, and b as it must be applied after a.
+func xpair(a, b *otEdit) ([]*otEdit, []*otEdit) {
+	switch {
+	case a.op == txt.Eins && b.op == txt.Eins:
+		if a.off < b.off || a.off == b.off && a.src < b.src {
+			return a.at(a.off, a.n), b.at(b.off+a.n, b.n)
+		}
+		return a.at(a.off+b.n, a.n), b.at(b.off, b.n)
+	case a.op == txt.Eins:
+		return xinsdel(a, b)
+	case b.op == txt.Eins:
+		bs, as := xinsdel(b, a)
+		return as, bs
+	}
+	a0, a1 := deladj(a.off, b.off, b.n), deladj(a.end(), b.off, b.n)
+	b0, b1 := deladj(b.off, a.off, a.n), deladj(b.end(), a.off, a.n)
+	return a.at(a0, a1-a0), b.at(b0, b1-b0)
+}
+
+// Given the edit lists as and bs, made to the same text, return as
+// it must be applied after bs, and bs as it must be applied after as.
+func xlist(as, bs []*otEdit) ([]*otEdit, []*otEdit) {
+	switch {
+	case len(as) == 0 || len(bs) == 0:
+		return as, bs
+	case len(as) == 1 && len(bs) == 1:
+		return xpair(as[0], bs[0])
+	case len(as) > 1:
+		as0, bs0 := xlist(as[:1], bs)
+		as1, bs1 := xlist(as[1:], bs0)
+		return append(as0, as1...), bs1
+	}
+	as0, bs0 := xlist(as, bs[:1])
+	as1, bs1 := xlist(as0, bs[1:])
+	return as1, append(bs0, bs1...)
+}
+
+// Record an edit just made to the text.
+func (t *Txt) logEdit(e *otEdit) {
+	vers := t.t.Vers()
+	if t.logv+len(t.log)+1 != vers {
+		// The text was changed in ways we did not see.
+		t.log = nil
+		t.logv = vers - 1
+	}
+	t.log = append(t.log, e)
+	if len(t.log) > maxLog {
+		n := len(t.log) - maxLog/2
+		t.log = append([]*otEdit(nil), t.log[n:]...)
+		t.logv += n
+	}
+}
+
+// Return the edits made after the given version, if known.
+func (t *Txt) since(vers int) ([]*otEdit, bool) {
+	last := t.logv + len(t.log)
+	if vers < t.logv || vers > last || t.t.Vers() != last {
+		return nil, false
+	}
+	return t.log[vers-t.logv:], true
+}
+
+// Transform an edit made by a view against those made since the
+// version it was made for.
+// If that's not known, the view is reloaded and false is returned.
+func (t *Txt) xform(wev *Ev, e *otEdit) ([]*otEdit, bool) {
+	vers := t.t.Vers()
+	if wev.Vers == vers+1 {
+		return []*otEdit{e}, true
+	}
+	old, ok := t.since(wev.Vers - 1)
+	if !ok {
+		dprintf("%s: %s: vers %d != %d+1\n", t.Id, wev.Args[0], wev.Vers, vers)
+		t.update(wev.Src)
+		return nil, false
+	}
+	es, _ := xlist([]*otEdit{e}, old)
+	dprintf("%s: %s: vers %d: %v -> %v\n", t.Id, wev.Args[0], wev.Vers, e, es)
+	return es, true
+}
+
+// Let a view know its last edit is done.
+func (t *Txt) ack(src string) {
+	t.out <- &Ev{Id: t.Id, Src: "", Vers: t.t.Vers(), Args: []string{"eack", src}}
+}
@@ -0,0 +1,228 @@
+package ink
+
+import (
+	"clive/txt"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func otApply(rs []rune, e *otEdit) []rune {
+	nrs := append([]rune{}, rs[:e.off]...)
+	if e.op == txt.Eins {
+		nrs = append(nrs, e.data...)
+		return append(nrs, rs[e.off:]...)
+	}
+	return append(nrs, rs[e.end():]...)
+}
+
+func otApplyAll(rs []rune, es []*otEdit) []rune {
+	for _, e := range es {
+		rs = otApply(rs, e)
+	}
+	return rs
+}
+
+func randEdit(rnd *rand.Rand, n int, src string) *otEdit {
+	off := rnd.Intn(n + 1)
+	if n > 0 && rnd.Intn(3) == 0 {
+		if off == n {
+			off--
+		}
+		return &otEdit{op: txt.Edel, off: off, n: 1 + rnd.Intn(n-off), src: src}
+	}
+	data := []rune(src[:1] + strconv.Itoa(rnd.Intn(100)))
+	return &otEdit{op: txt.Eins, off: off, n: len(data), data: data, src: src}
+}
+
+// Edits made at different places converge to the same text
+// when applied in either order, once transformed.
+func TestXform(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		rs := []rune("0123456789")
+		var as, bs []*otEdit
+		ra, rb := rs, rs
+		for j := rnd.Intn(4); j >= 0; j-- {
+			e := randEdit(rnd, len(ra), "a")
+			as = append(as, e)
+			ra = otApply(ra, e)
+		}
+		for j := rnd.Intn(4); j >= 0; j-- {
+			e := randEdit(rnd, len(rb), "b")
+			bs = append(bs, e)
+			rb = otApply(rb, e)
+		}
+		xas, xbs := xlist(as, bs)
+		ab := string(otApplyAll(ra, xbs))
+		ba := string(otApplyAll(rb, xas))
+		if ab != ba {
+			t.Fatalf("%d: %v vs %v:\n\t%q\n\t%q", i, as, bs, ab, ba)
+		}
+	}
+}
+
+// A view, doing what js/text.js does with edits.
+struct tView {
+	id            string
+	t             *Txt
+	in            chan *Ev
+	rs            []rune
+	vers          int
+	sent, pending []*otEdit
+	waiting       bool // for an eack
+	einsing       []rune
+	started       bool
+}
+
+func newTView(t *Txt, id string) *tView {
+	v := &tView{id: id, t: t, in: make(chan *Ev, 64*1024)}
+	tv := t.newView()
+	t.Lock()
+	tv.Id = id
+	t.Unlock()
+	go func() {
+		for ev := range tv.out {
+			v.in <- ev
+		}
+	}()
+	t.in <- &Ev{Id: t.Id, Src: id, Args: []string{"start"}}
+	return v
+}
+
+func (v *tView) edit(e *otEdit) {
+	v.rs = otApply(v.rs, e)
+	v.pending = append(v.pending, e)
+	v.send()
+}
+
+func (v *tView) send() {
+	if v.waiting || len(v.pending) == 0 {
+		return
+	}
+	e := v.pending[0]
+	v.pending = v.pending[1:]
+	v.sent = []*otEdit{e}
+	v.waiting = true
+	args := []string{"edel", strconv.Itoa(e.off), strconv.Itoa(e.end())}
+	if e.op == txt.Eins {
+		args = []string{"eins", string(e.data), strconv.Itoa(e.off)}
+	}
+	v.t.in <- &Ev{Id: v.t.Id, Src: v.id, Vers: v.vers + 1, Args: args}
+}
+
+func (v *tView) remote(t *testing.T, ev *Ev, e *otEdit) {
+	if ev.Vers != v.vers+1 {
+		t.Fatalf("%s: out of sync: %v vers %d", v.id, ev.Args, v.vers)
+	}
+	v.vers = ev.Vers
+	es := []*otEdit{e}
+	v.sent, es = xlist(v.sent, es)
+	v.pending, es = xlist(v.pending, es)
+	v.rs = otApplyAll(v.rs, es)
+}
+
+func (v *tView) apply(t *testing.T, ev *Ev) {
+	args := ev.Args
+	switch args[0] {
+	case "reload":
+		if v.started {
+			t.Fatalf("%s: reloaded", v.id)
+		}
+		v.rs = nil
+	case "reloading":
+		v.rs = append(v.rs, []rune(args[1]+"\n")...)
+	case "reloaded":
+		v.vers, _ = strconv.Atoi(args[1])
+		v.started = true
+	case "eack":
+		if args[1] == v.id {
+			v.vers = ev.Vers
+			v.sent = nil
+			v.waiting = false
+			v.send()
+		}
+	case "einsing":
+		v.einsing = append(v.einsing, []rune(args[1])...)
+	case "einsdone", "eins":
+		data := v.einsing
+		p0, _ := strconv.Atoi(args[1])
+		if args[0] == "eins" {
+			data = []rune(args[1])
+			p0, _ = strconv.Atoi(args[2])
+		}
+		v.einsing = nil
+		e := &otEdit{op: txt.Eins, off: p0, n: len(data), data: data, src: ev.Src}
+		v.remote(t, ev, e)
+	case "edel":
+		p0, _ := strconv.Atoi(args[1])
+		p1, _ := strconv.Atoi(args[2])
+		v.remote(t, ev, &otEdit{op: txt.Edel, off: p0, n: p1 - p0, src: ev.Src})
+	}
+}
+
+// Process events for v, if any, and return false if there were none.
+func (v *tView) poll(t *testing.T, wait time.Duration) bool {
+	select {
+	case ev := <-v.in:
+		v.apply(t, ev)
+		return true
+	case <-time.After(wait):
+		return false
+	}
+}
+
+// Replay interleaved edit streams from several views and the
+// application and check that all of them get the same text.
+func TestConcurrentEdits(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	tx := NewTxt("one line", "two lines")
+	vs := []*tView{newTView(tx, "a"), newTView(tx, "b"), newTView(tx, "c")}
+	for _, v := range vs {
+		for !v.started {
+			if !v.poll(t, 5*time.Second) {
+				t.Fatalf("%s: not started", v.id)
+			}
+		}
+	}
+	for i := 0; i < 2000; i++ {
+		v := vs[rnd.Intn(len(vs))]
+		switch n := rnd.Intn(10); {
+		case n < 4:
+			v.poll(t, 0)
+		case n < 9:
+			v.edit(randEdit(rnd, len(v.rs), v.id))
+		default:
+			tt := tx.GetText()
+			e := randEdit(rnd, tt.Len(), "app")
+			if e.op == txt.Eins {
+				tx.Ins(e.data, e.off)
+			} else {
+				tx.Del(e.off, e.n)
+			}
+			tx.UngetText()
+		}
+	}
+	limit := time.Now().Add(time.Minute)
+	for busy := true; busy; {
+		busy = false
+		for _, v := range vs {
+			for v.poll(t, 100*time.Millisecond) {
+				busy = true
+			}
+			busy = busy || v.waiting
+		}
+		if time.Now().After(limit) {
+			t.Fatalf("edits not done")
+		}
+	}
+	s := tx.GetText().String()
+	tx.UngetText()
+	for _, v := range vs {
+		if len(v.pending) > 0 || string(v.rs) != s {
+			t.Fatalf("%s: text is %q\n\tnot %q", v.id, string(v.rs), s)
+		}
+	}
+	t.Logf("text %q", s)
+}
@@ -22,7 +22,7 @@ import (
 //	ecopy	p0 p1
 //	ecut	p0 p1
 //	eins	text p0
-//	edel	p0 p1
+//	edel	p0 p1	(edits are transformed against concurrent ones, see ot.go)
 //	eundo
 //	eredo
 //	neeedreload
@@ -40,6 +40,7 @@ import (
 //	reloaded vers
 //	eins ...
 //	edel ...
+//	eack viewid	(the last edit from viewid is done, Vers is the version now)
 //	close
 //	noedits
 //	edits
@@ -48,7 +49,7 @@ import (
 //	rlse
 //	mark name pos
 //	markinsing name str
-//	markinsdone name
+//	markinsdone name p0
 //	delmark
 //	dirty
 //	clean
@@ -79,6 +80,8 @@ struct Txt {
 	cundo         bool
 	owner         string
 	held          []*Ev
+	log           []*otEdit // edits made, to transform those from views
+	logv          int       // version before log[0]
 	lastev        string
 	ngets         int
 	getslk        sync.Mutex
@@ -229,16 +232,6 @@ func (t *Txt) updateAll() {
 	}
 }
 
-func (t *Txt) wrongVers(tag string, wev *Ev) bool {
-	vers := t.t.Vers()
-	if wev.Vers == vers+1 {
-		return false
-	}
-	dprintf("%s: %s: vers %d != %d+1\n", t.Id, tag, wev.Vers, vers)
-	t.update(wev.Src)
-	return true
-}
-
 func (t *Txt) p0p1(ev []string) (int, int, error) {
 	if len(ev) < 3 {
 		dprintf("%s: %s: short\n", t.Id, ev[0])
@@ -275,6 +268,8 @@ type handler func(*Ev) handler
 //	releasing - > unlocked
 //
 // Held events are kept in t.held.
+// Only the application locks the text, views edit it concurrently
+// and get the hold they ask for right away.
 func (t *Txt) handler() {
 	h := t.handleUnlocked
 	for {
@@ -338,13 +333,16 @@ func (t *Txt) handleUnlocked(wev *Ev) handler {
 		if t.owner != "" {
 			panic("owner for a free text")
 		}
-		t.owner = wev.Src
-		if wev.Src != "" && wev.Src != "app" {
+		if wev.Src != "app" {
 			to := t.viewOut(wev.Src)
 			to <- &Ev{Id: t.Id, Src: t.Id + "u", Args: []string{"held"}}
+			return t.handleUnlocked
 		}
+		t.owner = wev.Src
 		dprintf("%s: locked by %s (%d)\n", t.Id, wev.Src, len(t.held))
 		return t.handleLocked
+	case "rlsed":
+		return t.handleUnlocked
 	}
 	t.apply(wev)
 	return t.handleUnlocked
@@ -433,6 +431,11 @@ func (t *Txt) undoRedo(isredo bool) bool {
 		}
 		some = true
 		dprintf("%s: %s: undo1\n", t.Id, o)
+		e := &otEdit{op: uev.Op, off: uev.Off, n: len(uev.Data)}
+		if uev.Op == txt.Eins {
+			e.data = uev.Data
+		}
+		t.logEdit(e)
 		nev := &Ev{Id: t.Id, Src: "", Vers: t.t.Vers()}
 		off := fmt.Sprintf("%d", uev.Off)
 		s := string(uev.Data)
@@ -450,6 +453,46 @@ func (t *Txt) undoRedo(isredo bool) bool {
 	}
 }
 
+// Apply edits from a view, already transformed, reflect them to
+// other views and post them to the user.
+// The marks for the view are left at the end of the last one.
+// Returns the runes deleted.
+func (t *Txt) edit(wev *Ev, es []*otEdit) []rune {
+	var drs []rune
+	for _, e := range es {
+		nev := &Ev{Id: t.Id, Src: wev.Src}
+		pos := e.off
+		if e.op == txt.Eins {
+			if err := t.t.Ins(e.data, e.off); err != nil {
+				dprintf("%s: ins: %s\n", t.Id, err)
+				t.update(wev.Src)
+				continue
+			}
+			pos += e.n
+			nev.Args = []string{"eins", string(e.data), strconv.Itoa(e.off)}
+		} else {
+			if e.n <= 0 {
+				continue
+			}
+			rs := t.t.Del(e.off, e.n)
+			e.n = len(rs)
+			drs = append(rs, drs...)
+			nev.Args = []string{"edel", strconv.Itoa(e.off), strconv.Itoa(e.end())}
+		}
+		t.logEdit(e)
+		if wev.Args[0] != "ecut" {
+			t.t.ContdEdit()
+		}
+		nev.Vers = t.t.Vers()
+		dprintf("%s: vers %d\n", t.Id, nev.Vers)
+		t.out <- nev
+		t.post(nev)
+		t.t.SetMark(wev.Src+"p0", pos)
+		t.t.SetMark(wev.Src+"p1", pos)
+	}
+	return drs
+}
+
 func (t *Txt) apply(wev *Ev) {
 
 	ev := wev.Args
@@ -524,49 +567,37 @@ func (t *Txt) apply(wev *Ev) {
 			return
 		}
 		p0, err := strconv.Atoi(ev[2])
-		if err != nil || t.wrongVers(ev[0], wev) {
+		if err != nil {
 			dprintf("%s: ins: %s\n", t.Id, err)
 			return
 		}
 		data := []rune(ev[1])
 		if len(data) == 0 {
+			t.ack(wev.Src)
 			return
 		}
-		if err := t.t.Ins(data, p0); err != nil {
-			dprintf("%s: ins: %s\n", t.Id, err)
-			return
+		e := &otEdit{op: txt.Eins, off: p0, n: len(data), data: data, src: wev.Src}
+		if es, ok := t.xform(wev, e); ok {
+			t.edit(wev, es)
+			t.ack(wev.Src)
 		}
-		t.t.ContdEdit()
-		dprintf("%s: vers %d\n", t.Id, t.t.Vers())
-		t.out <- wev
-		t.post(wev)
 	case "edel", "ecut":
 		p0, p1, err := t.p0p1(ev)
-		if ev[0] == "ecut" {
-			wev.Vers++ // cut does not advance, let wrongVers check it
+		if err != nil {
+			return
 		}
-		if err != nil || t.wrongVers(ev[0], wev) {
+		e := &otEdit{op: txt.Edel, off: p0, n: p1 - p0, src: wev.Src}
+		es, ok := t.xform(wev, e)
+		if !ok {
 			return
 		}
-		if p1 <= p0 {
-			t.t.Del(0, 0) // advance the vers
-		} else {
-			rs := t.t.Del(p0, p1-p0)
-			if ev[0] == "ecut" {
-				if err := snarf.Set(string(rs)); err != nil {
-					dprintf("%s: %s: snarf: %s\n", t.Id, ev[0], err)
-				}
-			} else {
-				t.t.ContdEdit()
+		rs := t.edit(wev, es)
+		if ev[0] == "ecut" && len(rs) > 0 {
+			if err := snarf.Set(string(rs)); err != nil {
+				dprintf("%s: %s: snarf: %s\n", t.Id, ev[0], err)
 			}
 		}
-		if ev[0] == "ecut" {
-			wev.Vers = t.t.Vers()
-		}
-		dprintf("%s: vers %d\n", t.Id, t.t.Vers())
-		ev[0] = "edel"
-		t.out <- wev
-		t.post(wev)
+		t.ack(wev.Src)
 	case "ecopy":
 		p0, p1, err := t.p0p1(ev)
 		if err != nil {
@@ -581,34 +612,39 @@ func (t *Txt) apply(wev *Ev) {
 		}
 	case "epaste":
 		p0, _, err := t.p0p1(ev)
-		wev.Vers++ // paste does not advance, let wrongVers check it
-		if err != nil || t.wrongVers(ev[0], wev) {
+		if err != nil {
+			return
+		}
+		es, ok := t.xform(wev, &otEdit{op: txt.Eins, off: p0, src: wev.Src})
+		if !ok {
 			return
 		}
+		p0 = es[0].off
+		t.ack(wev.Src)
 		s, err := snarf.Get()
 		if err != nil {
 			dprintf("%s: %s: snarf: %s\n", t.Id, ev[0], err)
 			return
 		}
 		rs := []rune(s)
-		if s == "" {
-			// Make the vers advance
-			t.t.Del(0, 0)
-		} else if err := t.t.Ins(rs, p0); err != nil {
-			dprintf("%s: %s: ins: %s\n", t.Id, ev[0], err)
-			return
+		if len(rs) > 0 {
+			if err := t.t.Ins(rs, p0); err != nil {
+				dprintf("%s: %s: ins: %s\n", t.Id, ev[0], err)
+				return
+			}
+			t.logEdit(&otEdit{op: txt.Eins, off: p0, n: len(rs), data: rs})
+			nev := &Ev{Id: t.Id, Src: "", Vers: t.t.Vers()}
+			nev.Args = []string{"eins", s, strconv.Itoa(p0)}
+			t.out <- nev
+			t.post(nev)
 		}
-		nev := &Ev{Id: t.Id, Src: "", Vers: t.t.Vers()}
-		nev.Args = []string{"eins", s, wev.Args[1]}
-		t.out <- nev
-		t.post(nev)
 		p1 := p0 + len(rs)
 		t.t.SetMark(wev.Src+"p0", p0)
 		t.t.SetMark(wev.Src+"p1", p1)
 		t.t.SetMark("p0", p0)
 		t.t.SetMark("p1", p1)
 		t.out <- &Ev{Id: t.Id, Src: "", Args: []string{"sel", strconv.Itoa(p0), strconv.Itoa(p1)}}
-		nev = &Ev{Id: t.Id, Src: "app", Vers: t.t.Vers(), Args: []string{
+		nev := &Ev{Id: t.Id, Src: "app", Vers: t.t.Vers(), Args: []string{
 			"tick", strconv.Itoa(p0), strconv.Itoa(p1),
 		}}
 		t.post(nev)
@@ -707,8 +743,10 @@ func (t *Txt) putText() {
 }
 
 // Return the text so the application can edit it at will,
-// further updates from the views will fail due to wrong version,
-// and the caller must call EditDone() when done so the views are reloaded
+// further edits from the views are held until PutText() or UngetText()
+// are called, and are rejected, reloading the views, if the text was changed
+// using the txt.Text returned.
+// The caller must call PutText() when done so the views are reloaded
 // with the new text.
 func (t *Txt) GetText() *txt.Text {
 	t.getText()
@@ -754,6 +792,7 @@ func (t *Txt) Ins(data []rune, off int) error {
 		dprintf("%s: ins: %s\n", t.Id, err)
 		return err
 	}
+	t.logEdit(&otEdit{op: txt.Eins, off: off, n: len(data), data: data, src: "app"})
 	dprintf("%s: vers %d\n", t.Id, t.t.Vers())
 	// Sending 4k or so in a single event makes Safari
 	// take a very long time (30s) to post the event.
@@ -778,6 +817,7 @@ func (t *Txt) Del(off, n int) []rune {
 	t.getText()
 	defer t.putText()
 	rs := t.t.Del(off, n)
+	t.logEdit(&otEdit{op: txt.Edel, off: off, n: len(rs), src: "app"})
 	dprintf("%s: vers %d\n", t.Id, t.t.Vers())
 	wev := &Ev{Id: t.Id, Src: "app", Vers: t.t.Vers(),
 		Args: []string{"edel", strconv.Itoa(off), strconv.Itoa(off + len(rs))}}
@@ -866,9 +906,15 @@ func (t *Txt) MarkIns(mark string, data []rune) error {
 	// So send multiple chunks, which is faster.
 	t.getText()
 	defer t.putText()
+	m := t.t.Mark(mark)
+	if m == nil {
+		return fmt.Errorf("no mark %s", mark)
+	}
+	off := m.Off
 	if err := t.t.MarkIns(mark, data); err != nil {
 		return err
 	}
+	t.logEdit(&otEdit{op: txt.Eins, off: off, n: len(data), data: data, src: "app"})
 	for tot, nw := 0, 0; tot < len(data); tot += nw {
 		nw = len(data) - tot
 		if nw > 128 {
@@ -879,7 +925,7 @@ func (t *Txt) MarkIns(mark string, data []rune) error {
 		}
 	}
 	t.out <- &Ev{Id: t.Id, Src: "app", Vers: t.t.Vers(),
-		Args: []string{"markinsdone", mark},
+		Args: []string{"markinsdone", mark, strconv.Itoa(off)},
 	}
 	return nil
 }
@@ -199,6 +199,8 @@ func MuxServe(addr string, tlscfg ...*tls.Config) (c <-chan *ch.Mux, ec chan boo
 	case "unix", "tcp", "tls":
 		port := Port(nw, svc)
 		return serveMux1(nw, host, port, cfg)
+	case "ws", "wss":
+		return serveMuxWS(nw, host, svc, cfg)
 	default:
 		return nil, nil, ErrBadAddr
 	}
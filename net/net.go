@@ -79,7 +79,8 @@ func Port(netw, svc string) string {
 // 	network!address!service
 //
 // The network/address may be "*" to use any available.
-// Known networks are unix, tcp, and tls; the default is tcp.
+// Known networks are unix, tcp, tls, ws, and wss; the default is tcp.
+// The ws and wss networks are WebSockets, and are only served by MuxServe.
 // The service defaults to "zx".
 func ParseAddr(addr string) (net, mach, svc string) {
 	args := strings.Split(addr, "!")
@@ -155,6 +156,9 @@ func dialTCP(host, port string, tlscfg *tls.Config) (net.Conn, error) {
 
 func dial(addr string, tlscfg *tls.Config) (c net.Conn, err error) {
 	nw, host, svc := ParseAddr(addr)
+	if isWS(nw) {
+		return dialWS(nw, host, svc, tlscfg)
+	}
 	port := Port(nw, svc)
 	err = ErrBadAddr
 	if nw == "*" || nw == "unix" {
@@ -3,6 +3,7 @@ package net
 import (
 	"clive/dbg"
 	"crypto/tls"
+	"net/http"
 	"os"
 	"testing"
 	"time"
@@ -275,3 +276,60 @@ func TestTLSMux(t *testing.T) {
 		time.Sleep(60 * time.Second)
 	}
 }
+
+func TestWSMux(t *testing.T) {
+	os.Args[0] = "net.test"
+	testMux(t, "ws!local!6668", nil, nil)
+}
+
+struct wsu {
+	addr, url string
+}
+
+func TestWSURL(t *testing.T) {
+	urls := []wsu{
+		{"ws!lsub.org!zx", "ws://lsub.org:8002/zx"},
+		{"ws!lsub.org:80!zx", "ws://lsub.org:80/zx"},
+		{"wss!lsub.org:443!zx", "wss://lsub.org:443/zx"},
+		{"ws!local!6668", "ws://127.0.0.1:6668/6668"},
+	}
+	for _, u := range urls {
+		nw, host, svc := ParseAddr(u.addr)
+		url, _ := wsURL(nw, host, svc, nil)
+		t.Logf("%s -> %s\n", u.addr, url)
+		if url != u.url {
+			t.Fatalf("was not %s", u.url)
+		}
+	}
+}
+
+struct fwdu {
+	raddr, fwd, addr string
+}
+
+func TestWSRemoteAddr(t *testing.T) {
+	TrustProxy("10.0.0.1", "10.0.0.2", "fe80:0::1")
+	addrs := []fwdu{
+		{"1.2.3.4:5", "", "1.2.3.4!5"},
+		{"1.2.3.4:5", "6.6.6.6", "1.2.3.4!5"},
+		{"10.0.0.1:5", "6.6.6.6", "6.6.6.6"},
+		{"10.0.0.1:5", "6.6.6.6, 7.7.7.7", "7.7.7.7"},
+		{"10.0.0.1:5", "6.6.6.6, 7.7.7.7, 10.0.0.2", "7.7.7.7"},
+		{"[::1]:5", "", "::1!5"},
+		{"10.0.0.1:5", "2001:db8::1", "2001:db8::1"},
+		{"10.0.0.1:5", "[2001:db8::1]:7", "2001:db8::1!7"},
+		{"[::2]:5", "6.6.6.6", "::2!5"},
+		{"[fe80::1]:5", "2001:db8::1, fe80::1", "2001:db8::1"},
+	}
+	for _, a := range addrs {
+		r := &http.Request{RemoteAddr: a.raddr, Header: http.Header{}}
+		if a.fwd != "" {
+			r.Header.Set("X-Forwarded-For", a.fwd)
+		}
+		addr := wsRemoteAddr(r)
+		t.Logf("%s %q -> %s\n", a.raddr, a.fwd, addr)
+		if addr != a.addr {
+			t.Fatalf("was not %s", a.addr)
+		}
+	}
+}
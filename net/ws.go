@@ -0,0 +1,188 @@
+package net
+
+import (
+	"clive/ch"
+	"clive/dbg"
+	"clive/x/code.google.com/p/go.net/websocket"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+/*
+	WebSocket networks.
+
+	The ws network speaks the clive protocols within WebSocket binary frames,
+	so that services can be reached through HTTP reverse proxies and from
+	web browsers. The wss network is ws over TLS, and ws is also secured
+	if a TLS config is given, as it happens with tcp.
+
+	The service name is used as the URL path, and its port is used
+	unless the host includes one, eg. ws!lsub.org:443!zx refers to
+	the URL wss://lsub.org:443/zx.
+
+	The X-Forwarded-For header is honored only for requests coming
+	from proxies said to be trusted (see TrustProxy).
+*/
+
+var (
+	proxies   = map[string]bool{}
+	proxieslk sync.Mutex
+)
+
+// Trust the HTTP proxies at the given IP addresses to report
+// the addresses of clients in WebSocket requests.
+func TrustProxy(ips ...string) {
+	proxieslk.Lock()
+	defer proxieslk.Unlock()
+	for _, ip := range ips {
+		if nip := net.ParseIP(ip); nip != nil {
+			ip = nip.String()
+		}
+		proxies[ip] = true
+	}
+}
+
+func isTrustedProxy(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		host = ip.String()
+	}
+	proxieslk.Lock()
+	defer proxieslk.Unlock()
+	return proxies[host]
+}
+
+// Is nw a WebSocket network?
+func isWS(nw string) bool {
+	return nw == "ws" || nw == "wss"
+}
+
+// Return the URL and the origin for the WebSocket address.
+func wsURL(nw, host, svc string, tlscfg *tls.Config) (string, string) {
+	if host == "local" || host == "localhost" || host == "*" {
+		host = "127.0.0.1"
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = host + ":" + Port("tcp", svc)
+	}
+	if nw == "wss" || tlscfg != nil {
+		return fmt.Sprintf("wss://%s/%s", host, svc), "https://" + host + "/"
+	}
+	return fmt.Sprintf("ws://%s/%s", host, svc), "http://" + host + "/"
+}
+
+func dialWS(nw, host, svc string, tlscfg *tls.Config) (net.Conn, error) {
+	if nw == "wss" && tlscfg == nil {
+		tlscfg = ClientTLSCfg
+		if tlscfg == nil {
+			return nil, ErrNoTLSCfg
+		}
+	}
+	url, origin := wsURL(nw, host, svc, tlscfg)
+	cfg, err := websocket.NewConfig(url, origin)
+	if err != nil {
+		return nil, err
+	}
+	cfg.TlsConfig = tlscfg
+	ws, err := websocket.DialConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ws.PayloadType = websocket.BinaryFrame
+	return ws, nil
+}
+
+// Remote address for a WebSocket request, in clive format.
+// Requests forwarded by trusted proxies report the address of the client,
+// ie. the last one in X-Forwarded-For that is not a trusted proxy.
+func wsRemoteAddr(r *http.Request) string {
+	raddr := r.RemoteAddr
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" && isTrustedProxy(raddr) {
+		toks := strings.Split(fwd, ",")
+		for i := len(toks) - 1; i >= 0; i-- {
+			raddr = strings.TrimSpace(toks[i])
+			if !isTrustedProxy(raddr) {
+				break
+			}
+		}
+	}
+	host, port, err := net.SplitHostPort(raddr)
+	if err != nil {
+		// no port, as in the (perhaps IPv6) addresses of X-Forwarded-For
+		return strings.TrimSuffix(strings.TrimPrefix(raddr, "["), "]")
+	}
+	return host + "!" + port
+}
+
+func serveMuxWS(nw, host, svc string, tlscfg *tls.Config) (c <-chan *ch.Mux, ec chan bool, err error) {
+	tag := fmt.Sprintf("%s!%s!%s", nw, host, svc)
+	if nw == "wss" && tlscfg == nil {
+		tlscfg = ServerTLSCfg
+		if tlscfg == nil {
+			return nil, nil, ErrNoTLSCfg
+		}
+	}
+	if host == "local" || host == "*" || host == "localhost" {
+		host = ""
+	}
+	addr := host + ":" + Port("tcp", svc)
+	dbg.Warn("listen at %s (%s:%s/%s)", tag, nw, addr, svc)
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tlscfg != nil {
+		l = tls.NewListener(l, tlscfg)
+	}
+	rc := make(chan *ch.Mux)
+	rec := make(chan bool)
+	muxes := map[*ch.Mux]bool{}
+	var muxeslk sync.Mutex
+	hndlr := func(ws *websocket.Conn) {
+		ws.PayloadType = websocket.BinaryFrame
+		mux := ch.NewMux(ws, false)
+		mux.Tag = wsRemoteAddr(ws.Request())
+		muxeslk.Lock()
+		muxes[mux] = true
+		muxeslk.Unlock()
+		defer func() {
+			muxeslk.Lock()
+			delete(muxes, mux)
+			muxeslk.Unlock()
+		}()
+		if ok := rc <- mux; !ok {
+			mux.Close()
+			return
+		}
+		// the conn is closed when we return.
+		<-mux.Hup
+	}
+	// Clients are not browsers and do not have to send an Origin.
+	hs := func(*websocket.Config, *http.Request) error {
+		return nil
+	}
+	srvmux := http.NewServeMux()
+	srvmux.Handle("/"+svc, websocket.Server{Handler: hndlr, Handshake: hs})
+	go func() {
+		<-rec
+		l.Close()
+		muxeslk.Lock()
+		for m := range muxes {
+			m.Close()
+		}
+		muxeslk.Unlock()
+	}()
+	go func() {
+		err := http.Serve(l, srvmux)
+		close(rc, err)
+		close(rec, err)
+	}()
+	return rc, rec, nil
+}
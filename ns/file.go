@@ -3,12 +3,10 @@ package ns
 import (
 	"clive/net/auth"
 	"clive/zx"
-	"clive/zx/rzx"
 	"clive/zx/zux"
 	"fmt"
 	"io"
 	fpath "path"
-	"strings"
 	"sync"
 )
 
@@ -19,8 +17,30 @@ var (
 	_fs  zx.RWFs       = &NS{}
 	_fs2 zx.Finder     = &NS{}
 	_fs3 zx.FindGetter = &NS{}
+	_fs4 zx.Linker     = &NS{}
+	_fs5 zx.Auther     = &NS{}
 )
 
+// Return a view of ns that uses its trees as the user for ai,
+// for those trees that can authenticate users.
+// Other trees (eg., remote ones) are refused in the view unless ai is for
+// our user, because they would be used with our credentials.
+// The view shares the mounts with ns.
+func (ns *NS) Auth(ai *auth.Info) (zx.Fs, error) {
+	return ns.authFor(ai), nil
+}
+
+func (ns *NS) authFor(ai *auth.Info) *NS {
+	nns := &NS{Verb: ns.Verb, Progress: ns.Progress, mntTab: ns.mntTab, ai: ai}
+	nns.Flag = ns.Flag
+	return nns
+}
+
+// Like DirFs, but using the tree as the user ns is for.
+func (ns *NS) dirFs(d zx.Dir) (zx.Fs, error) {
+	return dirFsFor(d, ns.ai)
+}
+
 // For testing
 func delLfsPath(path string) {
 	path, err := zx.UseAbsPath(path)
@@ -83,36 +103,6 @@ func Lfs(path string) (zx.Fs, string, string) {
 	return lfs[bp], root, rel
 }
 
-// Dial the server for this dir (if not already dialed) and return it,
-// the dir addr is updated.
-func DirFs(d zx.Dir) (zx.Fs, error) {
-	switch p := d.Proto(); p {
-	case "lfs":
-		addr := d["addr"]
-		toks := strings.Split(d["addr"], "!") // lfs!root!/path
-		if len(toks) != 3 {
-			return nil, fmt.Errorf("ns: no zux tree for addr %q", addr)
-		}
-		fullpath := fpath.Join(toks[1], toks[2])
-		fs, root, rel := Lfs(fullpath)
-		if fs == nil {
-			return nil, fmt.Errorf("ns: no zux tree for addr %q", addr)
-		}
-		d["addr"] = "lfs!" + root + "!" + rel
-		return fs, nil
-	case "zx":
-		addr := d.SAddr()
-		if len(addr) < 3 {
-			panic("DirFs bug")
-		}
-		addr = addr[3:] // remove zx!
-		// rzx does cache dials, no need to do it again here.
-		return rzx.Dial(addr, auth.TLSclient)
-	default:
-		return nil, fmt.Errorf("ns: no tree for addr %q", d["addr"])
-	}
-}
-
 func cerr(err error) <-chan []byte {
 	c := make(chan []byte)
 	close(c, err)
@@ -132,12 +122,102 @@ func rerr(err error) <-chan error {
 	return c
 }
 
+// Return the mount in a union where the file exists, or the first
+// one if it does not exist, and its stat, if known.
+// Mounts are considered in order and, for those without an address,
+// the file is the mount point.
+func (ns *NS) existingMnt(ds []zx.Dir) (zx.Dir, zx.Dir) {
+	if len(ds) == 1 {
+		return ds[0], nil
+	}
+	for _, d := range ds {
+		if d["addr"] == "" {
+			return d, d
+		}
+		fs, err := ns.dirFs(d)
+		if err != nil {
+			continue
+		}
+		if sd, err := zx.Stat(fs, d.SPath()); err == nil && sd != nil {
+			return d, sd
+		}
+	}
+	return ds[0], nil
+}
+
+// Return the mount in a union where the file is to be put:
+// that where it exists or, for new files, the first one mounted
+// with the create option, or the first one if none has it.
+func (ns *NS) putMnt(ds []zx.Dir) zx.Dir {
+	if len(ds) == 1 {
+		return ds[0]
+	}
+	if d, sd := ns.existingMnt(ds); sd != nil {
+		return d
+	}
+	for _, d := range ds {
+		if _, ok := mntOpts(d)["create"]; ok {
+			return d
+		}
+	}
+	return ds[0]
+}
+
+// Send the merged listing for a directory in a union.
+// Entries are de-duplicated by name, using the first one found.
+// Members where the directory does not exist are not part of the
+// listing, but any other failure in a member fails the whole listing.
+func (ns *NS) getUnion(ds []zx.Dir, off, count int64, c chan<- []byte) error {
+	seen := map[string]bool{}
+	n := int64(0)
+	for _, d := range ds {
+		if d["addr"] == "" {
+			continue
+		}
+		fs, err := ns.dirFs(d)
+		if err != nil {
+			return err
+		}
+		gfs, ok := fs.(zx.Getter)
+		if !ok {
+			return fmt.Errorf("%s: tree is not a getter", d["addr"])
+		}
+		sd, err := zx.Stat(fs, d.SPath())
+		if zx.IsNotExist(err) || err == nil && sd["type"] != "d" {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		ents, err := zx.GetDir(gfs, d.SPath())
+		if err != nil {
+			return err
+		}
+		for _, e := range ents {
+			if seen[e["name"]] {
+				continue
+			}
+			seen[e["name"]] = true
+			if n++; n <= off {
+				continue
+			}
+			if count >= 0 && n > off+count {
+				return nil
+			}
+			if ok := c <- e.Bytes(); !ok {
+				return cerror(c)
+			}
+		}
+	}
+	return nil
+}
+
 func (ns *NS) Stat(path string) <-chan zx.Dir {
 	pname, ds, err := ns.Resolve(path)
 	if err != nil {
 		return derr(err)
 	}
-	d := ds[0]
+	d, _ := ns.existingMnt(ds)
 	if d["addr"] == "" {
 		c := make(chan zx.Dir, 1)
 		d["path"] = fpath.Join(pname, d.SPath())
@@ -145,7 +225,7 @@ func (ns *NS) Stat(path string) <-chan zx.Dir {
 		close(c)
 		return c
 	}
-	fs, err := DirFs(d)
+	fs, err := ns.dirFs(d)
 	if err != nil {
 		return derr(err)
 	}
@@ -162,13 +242,21 @@ func (ns *NS) Stat(path string) <-chan zx.Dir {
 	return rc
 }
 
+// On unions, directories are the merge of those found in the union.
 func (ns *NS) Get(path string, off, count int64) <-chan []byte {
 	_, ds, err := ns.Resolve(path)
 	if err != nil {
 		return cerr(err)
 	}
-	d := ds[0]
-	fs, err := DirFs(d)
+	d, sd := ns.existingMnt(ds)
+	if sd != nil && sd["type"] == "d" {
+		c := make(chan []byte)
+		go func() {
+			close(c, ns.getUnion(ds, off, count, c))
+		}()
+		return c
+	}
+	fs, err := ns.dirFs(d)
 	if err != nil {
 		return cerr(err)
 	}
@@ -179,15 +267,16 @@ func (ns *NS) Get(path string, off, count int64) <-chan []byte {
 	return xfs.Get(d.SPath(), off, count)
 }
 
-// On unions, the first entry is always used.
+// On unions, the file is put where it exists or, if it's new,
+// at the first tree mounted with the create option (or the first tree).
 func (ns *NS) Put(path string, ud zx.Dir, off int64, dc <-chan []byte) <-chan zx.Dir {
 	pname, ds, err := ns.Resolve(path)
 	if err != nil {
 		close(dc, err)
 		return derr(err)
 	}
-	d := ds[0]
-	fs, err := DirFs(d)
+	d := ns.putMnt(ds)
+	fs, err := ns.dirFs(d)
 	if err != nil {
 		close(dc, err)
 		return derr(err)
@@ -215,8 +304,8 @@ func (ns *NS) Wstat(path string, ud zx.Dir) <-chan zx.Dir {
 	if err != nil {
 		return derr(err)
 	}
-	d := ds[0]
-	fs, err := DirFs(d)
+	d, _ := ns.existingMnt(ds)
+	fs, err := ns.dirFs(d)
 	if err != nil {
 		return derr(err)
 	}
@@ -242,8 +331,8 @@ func (ns *NS) Remove(path string) <-chan error {
 	if err != nil {
 		return rerr(err)
 	}
-	d := ds[0]
-	fs, err := DirFs(d)
+	d, _ := ns.existingMnt(ds)
+	fs, err := ns.dirFs(d)
 	if err != nil {
 		return rerr(err)
 	}
@@ -259,8 +348,8 @@ func (ns *NS) RemoveAll(path string) <-chan error {
 	if err != nil {
 		return rerr(err)
 	}
-	d := ds[0]
-	fs, err := DirFs(d)
+	d, _ := ns.existingMnt(ds)
+	fs, err := ns.dirFs(d)
 	if err != nil {
 		return rerr(err)
 	}
@@ -270,28 +359,3 @@ func (ns *NS) RemoveAll(path string) <-chan error {
 	}
 	return xfs.RemoveAll(d.SPath())
 }
-
-func (ns *NS) Move(from, to string) <-chan error {
-	_, fromds, err := ns.Resolve(from)
-	if err != nil {
-		return rerr(err)
-	}
-	fromd := fromds[0]
-	fromfs, err := DirFs(fromd)
-	if err != nil {
-		return rerr(err)
-	}
-	_, tods, err := ns.Resolve(to)
-	if err != nil {
-		return rerr(err)
-	}
-	tod := tods[0]
-	if fromd.SAddr() != tod.SAddr() {
-		return rerr(fmt.Errorf("%s: cross device move", from))
-	}
-	xfs, ok := fromfs.(zx.Mover)
-	if !ok {
-		return rerr(fmt.Errorf("%s: tree is not a mover", from))
-	}
-	return xfs.Move(fromd.SPath(), tod.SPath())
-}
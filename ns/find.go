@@ -49,6 +49,20 @@ struct finder {
 
 	suffs  map[string]*prefix
 	spreds map[string]*pred.Pred
+	seen   map[string]bool // paths sent, to merge unions
+}
+
+// Is d to be sent? Entries found before at other trees in a union are not.
+func (f *finder) isNew(d zx.Dir) bool {
+	if d["err"] != "" {
+		return true
+	}
+	if f.seen[d["path"]] {
+		f.ns.vprintf("fnd:		dup %s\n", d["path"])
+		return false
+	}
+	f.seen[d["path"]] = true
+	return true
 }
 
 func (p *prefix) dupDirs() []zx.Dir {
@@ -67,14 +81,14 @@ func (f *finder) find1get(d zx.Dir) error {
 	searching := zx.HasPrefix(f.name, f.walked)
 	f.ns.vprintf("fnd:\t\tfind name %s walked %s sp %s dp %s depth %d searching %v\n",
 		f.name, f.walked, f.spref, f.dpref, f.depth, searching)
-	if !d.IsFinder() {
+	if !isFinder(d) {
 		f.ns.vprintf("fnd:\t\tnot a finder\n")
 		// it's ok to find it if it's just the name where we are finding.
 		if f.name == f.walked || !searching {
 			d["name"] = pname
 			d["path"] = f.walked
 			v, _, _ := f.pred.EvalAt(d, f.depth)
-			if v {
+			if v && f.isNew(d) {
 				if ok := f.gc <- d; !ok {
 					return cerror(f.gc)
 				}
@@ -83,7 +97,7 @@ func (f *finder) find1get(d zx.Dir) error {
 		return nil
 	}
 
-	rf, err := DirFs(d)
+	rf, err := f.ns.dirFs(d)
 	if err != nil {
 		f.ns.vprintf("fnd:\t\tdir fs: %s\n", err)
 		return err
@@ -104,9 +118,13 @@ func (f *finder) find1get(d zx.Dir) error {
 	f.ns.vprintf("fnd:\t\tfind(%s %q %s %s %d)\n",
 		sname, f.pred, spath, f.walked, f.depth)
 	rgc := r.FindGet(sname, f.pred.String(), spath, f.walked, f.depth)
+	skip := false // data for a dup entry
 	for rg := range rgc {
 		rd, ok := rg.(zx.Dir)
 		if !ok {
+			if skip {
+				continue
+			}
 			f.ns.vprintf("fnd: fwd msg type %T\n", rg)
 			if ok := f.gc <- rg; !ok {
 				close(rgc, cerror(f.gc))
@@ -151,6 +169,9 @@ func (f *finder) find1get(d zx.Dir) error {
 			suff := zx.Suffix(cpath, f.spref)
 			rd["path"] = fpath.Join(f.dpref, suff)
 		}
+		if skip = !f.isNew(rd); skip {
+			continue
+		}
 		if ok := f.gc <- rd; !ok {
 			close(rgc, cerror(f.gc))
 			break
@@ -165,21 +186,21 @@ func (f *finder) find1(d zx.Dir) error {
 	searching := zx.HasPrefix(f.name, f.walked)
 	f.ns.vprintf("fnd:\t\tfind1 name %s walked %s sp %s dp %s depth %d searching %v\n",
 		f.name, f.walked, f.spref, f.dpref, f.depth, searching)
-	if !d.IsFinder() {
+	if !isFinder(d) {
 		f.ns.vprintf("fnd:\t\tnot a finder: %s\n", d)
 		// it's ok to find it if it's just the name where we are finding.
 		if f.name == f.walked || !searching {
 			d["name"] = pname
 			d["path"] = f.walked
 			v, _, _ := f.pred.EvalAt(d, f.depth)
-			if v {
+			if v && f.isNew(d) {
 				f.c <- d
 			}
 		}
 		return nil
 	}
 
-	rf, err := DirFs(d)
+	rf, err := f.ns.dirFs(d)
 	if err != nil {
 		f.ns.vprintf("fnd:\t\tdir fs: %s\n", err)
 		return err
@@ -237,6 +258,9 @@ func (f *finder) find1(d zx.Dir) error {
 			suff := zx.Suffix(cpath, f.spref)
 			rd["path"] = fpath.Join(f.dpref, suff)
 		}
+		if !f.isNew(rd) {
+			continue
+		}
 		if ok := f.c <- rd; !ok {
 			close(rc, cerror(f.c))
 			break
@@ -370,6 +394,7 @@ func (ns *NS) findget(name string, fpred string, spref, dpref string, depth0 int
 		depth: depth0,
 		c:     c,
 		gc:    gc,
+		seen:  map[string]bool{},
 	}
 	ns.vprintf("fnd:\t%s %s d%d\n", fndr.name, fndr.upred, fndr.depth)
 	err = fndr.find()
@@ -0,0 +1,209 @@
+package ns
+
+import (
+	"clive/zx"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	fpath "path"
+	"strings"
+	"time"
+)
+
+// A change made to a name space.
+// Cmd is the ctl command (see Tree) that makes the change again.
+struct Chg {
+	Time time.Time
+	Cmd  string
+}
+
+func (c Chg) String() string {
+	return fmt.Sprintf("%s %s", c.Time.Format(time.RFC3339), c.Cmd)
+}
+
+// Return the journal for ns, ie. the changes made to it
+// since it was created or parsed.
+func (ns *NS) Journal() []Chg {
+	ns.lk.RLock()
+	defer ns.lk.RUnlock()
+	return append([]Chg{}, ns.journal...)
+}
+
+func (ns *NS) log(cmd string) {
+	ns.journal = append(ns.journal, Chg{Time: time.Now(), Cmd: cmd})
+}
+
+func (ns *NS) logMount(d zx.Dir, flag Flag) {
+	nd := d.Dup()
+	if nd["type"] == "" {
+		nd["type"] = "p"
+	}
+	if nd["mode"] == "" {
+		nd["mode"] = "0644"
+	}
+	fl := "-a"
+	switch flag {
+	case Before:
+		fl = "-b"
+	case Repl:
+		fl = "-r"
+	}
+	ns.log("mount " + fl + " " + entLine(nd))
+}
+
+func (ns *NS) logUnmount(name string, d zx.Dir) {
+	if name == "" {
+		name = "-"
+	}
+	if d != nil && d["addr"] != "" {
+		ns.log("unmount " + name + " " + d["addr"])
+	} else {
+		ns.log("unmount " + name)
+	}
+}
+
+// Execute the given ctl commands, one per line:
+//
+//	mount [-a|-b|-r] path addr [opts]
+//	mount dir
+//	unmount path [addr]
+//
+// The entry mounted is given as in a name space description
+// (see Parse) and mounted after those at the same path (-a),
+// before them (-b), or replacing them (-r).
+// Unmount removes the mounts at path, or those for the given addr.
+// A path "-" unmounts the addr at any path.
+// Views of ns made for (remote) users by Auth can't mount lfs trees.
+func (ns *NS) Ctl(cmds string) error {
+	for _, ln := range strings.Split(cmds, "\n") {
+		ln = strings.TrimSpace(ln)
+		if ln == "" || ln[0] == '#' {
+			continue
+		}
+		if err := ns.ctl(ln); err != nil {
+			return fmt.Errorf("ctl: %s: %s", ln, err)
+		}
+	}
+	return nil
+}
+
+func (ns *NS) ctl(ln string) error {
+	toks := strings.Fields(ln)
+	switch toks[0] {
+	case "mount":
+		flag := After
+		ln = strings.TrimSpace(ln[len(toks[0]):])
+		if len(toks) > 1 && strings.HasPrefix(toks[1], "-") {
+			switch toks[1] {
+			case "-a":
+			case "-b":
+				flag = Before
+			case "-r":
+				flag = Repl
+			default:
+				return errors.New("usage: mount [-a|-b|-r] path addr [opts]")
+			}
+			ln = strings.TrimSpace(ln[len(toks[1]):])
+		}
+		d, err := parseEnt(ln)
+		if err != nil {
+			return err
+		}
+		if ns.ai != nil && d.Proto() == "lfs" {
+			// remote users can't reach our files this way
+			return fmt.Errorf("mount %s: %s", d["addr"], zx.ErrPerm)
+		}
+		return ns.Mount(d, flag)
+	case "unmount", "umount":
+		var d zx.Dir
+		switch len(toks) {
+		case 2:
+			if toks[1] == "-" {
+				return errors.New("unmount: no addr")
+			}
+		case 3:
+			sd := specialForm("/ " + toks[2])
+			if sd == nil {
+				return errors.New("bad addr")
+			}
+			d = zx.Dir{"addr": sd["addr"]}
+		default:
+			return errors.New("usage: unmount path [addr]")
+		}
+		if toks[1] == "-" {
+			toks[1] = ""
+		}
+		return ns.Unmount(toks[1], d)
+	default:
+		return errors.New("unknown ctl")
+	}
+}
+
+// Replace the mounts in ns with those in the name space described by s
+// (see Parse), and make again the changes in the journal of ns.
+// This is used to reload a name space when its description changes
+// while keeping the changes made at run time.
+// Changes that can't be made again are dropped from the journal
+// and the first error found doing so is returned.
+func (ns *NS) Reload(s string) error {
+	nns, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	ns.lk.Lock()
+	defer ns.lk.Unlock()
+	var kept []Chg
+	for _, c := range ns.journal {
+		if cerr := nns.ctl(c.Cmd); cerr != nil {
+			if err == nil {
+				err = fmt.Errorf("reload: %s: %s", c.Cmd, cerr)
+			}
+			continue
+		}
+		kept = append(kept, c)
+	}
+	for _, p := range nns.pref {
+		p.ns = ns
+	}
+	ns.pref = nns.pref
+	ns.journal = kept
+	return err
+}
+
+// Save the name space to the given file (in the format of Parse).
+// The file is updated atomically: the name space is written to a
+// temporary file in the same directory that is then renamed to be file.
+// The journal is not saved.
+func (ns *NS) Save(file string) error {
+	dir, name := fpath.Split(file)
+	if dir == "" {
+		dir = "."
+	}
+	tf, err := ioutil.TempFile(dir, "."+name)
+	if err != nil {
+		return fmt.Errorf("ns: save: %s", err)
+	}
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(file); err == nil {
+		mode = fi.Mode() & os.ModePerm
+	}
+	_, err = tf.WriteString(ns.String())
+	if err == nil {
+		err = tf.Chmod(mode)
+	}
+	if err == nil {
+		err = tf.Sync()
+	}
+	if cerr := tf.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tf.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tf.Name())
+		return fmt.Errorf("ns: save: %s", err)
+	}
+	return nil
+}
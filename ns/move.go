@@ -0,0 +1,200 @@
+package ns
+
+import (
+	"clive/zx"
+	"fmt"
+	fpath "path"
+)
+
+// Move from to be to.
+// When both names are in the same tree, the tree moves the file.
+// Otherwise, from is copied to a temporary name next to to,
+// recursively for directories and preserving their attributes,
+// then moved into place, and then removed.
+// If the copy fails, the partial copy is removed and from is left untouched.
+// ns.Progress, if set, is called for each file copied.
+func (ns *NS) Move(from, to string) <-chan error {
+	_, fromds, err := ns.Resolve(from)
+	if err != nil {
+		return rerr(err)
+	}
+	fromd, _ := ns.existingMnt(fromds)
+	fromfs, err := ns.dirFs(fromd)
+	if err != nil {
+		return rerr(err)
+	}
+	_, tods, err := ns.Resolve(to)
+	if err != nil {
+		return rerr(err)
+	}
+	tod := ns.putMnt(tods)
+	if fromd.SAddr() == tod.SAddr() {
+		xfs, ok := fromfs.(zx.Mover)
+		if !ok {
+			return rerr(fmt.Errorf("%s: tree is not a mover", from))
+		}
+		return xfs.Move(fromd.SPath(), tod.SPath())
+	}
+	tofs, err := ns.dirFs(tod)
+	if err != nil {
+		return rerr(err)
+	}
+	// Resolve made sure these are fine; use them as names in ns.
+	from, _ = zx.UseAbsPath(from)
+	to, _ = zx.UseAbsPath(to)
+	c := make(chan error, 1)
+	go func() {
+		err := ns.xmove(fromfs, fromd.SPath(), from, tofs, tod.SPath(), to)
+		c <- err
+		close(c, err)
+	}()
+	return c
+}
+
+// Move across trees: copy, move into place, and remove the source.
+func (ns *NS) xmove(sfs zx.Fs, spath, from string, dfs zx.Fs, dpath, to string) error {
+	if spath == "/" || spath == "/Ctl" {
+		return fmt.Errorf("move %s: %s", from, zx.ErrPerm)
+	}
+	if dpath == "/" || dpath == "/Ctl" {
+		return fmt.Errorf("move %s: %s", to, zx.ErrPerm)
+	}
+	sgfs, ok := sfs.(zx.Getter)
+	if !ok {
+		return fmt.Errorf("%s: tree is not a getter", from)
+	}
+	srfs, ok := sfs.(zx.Remover)
+	if !ok {
+		return fmt.Errorf("%s: tree is not a remover", from)
+	}
+	dpfs, ok := dfs.(zx.Putter)
+	if !ok {
+		return fmt.Errorf("%s: tree is not a putter", to)
+	}
+	dmfs, ok := dfs.(zx.Mover)
+	if !ok {
+		return fmt.Errorf("%s: tree is not a mover", to)
+	}
+	drfs, ok := dfs.(zx.Remover)
+	if !ok {
+		return fmt.Errorf("%s: tree is not a remover", to)
+	}
+	d, err := zx.Stat(sfs, spath)
+	if err != nil {
+		return err
+	}
+	tmp := fpath.Join(fpath.Dir(dpath), "."+fpath.Base(dpath)+".mv")
+	ns.vprintf("xmove %s %s (tmp %s)\n", from, to, tmp)
+	if err := ns.copy(sgfs, d, spath, from, dpfs, tmp, to); err != nil {
+		<-drfs.RemoveAll(tmp)
+		return err
+	}
+	if err := <-dmfs.Move(tmp, dpath); err != nil {
+		<-drfs.RemoveAll(tmp)
+		return err
+	}
+	// some systems update the mtime of dirs renamed.
+	if err := setMtime(dpfs, dpath, d); err != nil {
+		return fmt.Errorf("%s: %s", to, err)
+	}
+	// From now on, to is complete, and there's no point in rolling back.
+	if err := <-srfs.RemoveAll(spath); err != nil {
+		return fmt.Errorf("%s: copied to %s but not removed: %s", from, to, err)
+	}
+	return nil
+}
+
+// Copy the file for d at spath to be at dpath, recursively for directories.
+// from and to are the names in ns, for diags and progress reports.
+func (ns *NS) copy(sfs zx.Getter, d zx.Dir, spath, from string, dfs zx.Putter, dpath, to string) error {
+	nd := d.SysDup()
+	for _, k := range []string{"path", "name", "addr", "wuid"} {
+		delete(nd, k)
+	}
+	switch d["type"] {
+	case "d":
+		delete(nd, "size")
+		dc := make(chan []byte)
+		close(dc)
+		if err := putc(dfs, dpath, nd, dc); err != nil {
+			return err
+		}
+		ds, err := zx.GetDir(sfs, spath)
+		if err != nil {
+			return err
+		}
+		for _, cd := range ds {
+			if cd["type"] == "c" {
+				continue // Ctl files are not copied
+			}
+			n := cd["name"]
+			err := ns.copy(sfs, cd, fpath.Join(spath, n), fpath.Join(from, n),
+				dfs, fpath.Join(dpath, n), fpath.Join(to, n))
+			if err != nil {
+				return err
+			}
+		}
+		// creating the children updated the mtime.
+		if err := setMtime(dfs, dpath, d); err != nil {
+			return err
+		}
+	case "-":
+		if err := putc(dfs, dpath, nd, sfs.Get(spath, 0, zx.All)); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%s: can't copy files of type '%s'", from, d["type"])
+	}
+	ns.vprintf("copied %s %s\n", from, to)
+	if ns.Progress != nil {
+		ns.Progress(from, to, d.Size())
+	}
+	return nil
+}
+
+func setMtime(fs zx.Putter, path string, d zx.Dir) error {
+	wfs, ok := fs.(zx.Wstater)
+	if !ok || d["mtime"] == "" {
+		return nil
+	}
+	wc := wfs.Wstat(path, zx.Dir{"mtime": d["mtime"]})
+	<-wc
+	return cerror(wc)
+}
+
+func putc(fs zx.Putter, path string, d zx.Dir, dc <-chan []byte) error {
+	pc := fs.Put(path, d, 0, dc)
+	<-pc
+	err := cerror(pc)
+	if err != nil {
+		close(dc, err)
+	}
+	return err
+}
+
+// Link new to refer to old.
+// Both names must be in the same tree.
+func (ns *NS) Link(oldp, newp string) <-chan error {
+	_, oldds, err := ns.Resolve(oldp)
+	if err != nil {
+		return rerr(err)
+	}
+	oldd, _ := ns.existingMnt(oldds)
+	_, newds, err := ns.Resolve(newp)
+	if err != nil {
+		return rerr(err)
+	}
+	newd := ns.putMnt(newds)
+	if oldd.SAddr() != newd.SAddr() {
+		return rerr(fmt.Errorf("%s: cross device link", newp))
+	}
+	fs, err := ns.dirFs(oldd)
+	if err != nil {
+		return rerr(err)
+	}
+	xfs, ok := fs.(zx.Linker)
+	if !ok {
+		return rerr(fmt.Errorf("%s: tree is not a linker", oldp))
+	}
+	return xfs.Link(oldd.SPath(), newd.SPath())
+}
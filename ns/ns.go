@@ -6,15 +6,21 @@
 	to a finder interface.
 
 	It's a prefix table where the longest prefix wins.
-	There are no binds and no unions.
+	There are no binds.
+
+	Mounting several dirs at the same prefix (Before or After) makes a union.
+	Files are found at the first tree where they exist, directories
+	are the merge of those in the union (without duplicate names),
+	and new files are created at the first tree mounted with the create
+	option, or at the first tree if none has it.
 */
 package ns
 
 import (
 	"bytes"
 	"clive/dbg"
+	"clive/net/auth"
 	"clive/zx"
-	"clive/zx/rzx"
 	"fmt"
 	"io/ioutil"
 	"path"
@@ -63,16 +69,29 @@ struct NS {
 	dbg.Flag
 	Verb bool // verbose debug diags
 
-	lk   sync.RWMutex
-	pref []*prefix
+	// If not nil, called for each file or directory copied
+	// by moves across trees, with its ns paths and its size.
+	Progress func(from, to string, size int64)
+
+	*mntTab            // shared with the views made by Auth
+	ai      *auth.Info // user the trees are used as, if not nil
+}
+
+// The mount table of a name space
+struct mntTab {
+	lk      sync.RWMutex
+	pref    []*prefix
+	journal []Chg // changes since the ns was made
 }
 
 // Create a new empty name space. It has a single entry for an empty
 // directory mounted at "/"
 func New() *NS {
 	ns := &NS{
-		pref: []*prefix{
-			{name: "/"},
+		mntTab: &mntTab{
+			pref: []*prefix{
+				{name: "/"},
+			},
 		},
 	}
 	ns.Tag = "ns"
@@ -141,41 +160,48 @@ func (ns *NS) String() string {
 		return "/\n"
 	}
 	var buf bytes.Buffer
-	ents := ns.Entries()
-	for _, p := range ents {
-		if p["type"] != "p" {
-			fmt.Fprintf(&buf, "%s\n", p)
-			continue
-		}
-		path := p["path"]
-		if a := p["addr"]; strings.HasPrefix(a, "lfs!") {
-			toks := strings.Split(a, "!")
-			if len(toks) == 3 && path == toks[1] && toks[2] == "/" {
-				fmt.Fprintf(&buf, "%s\n", path)
-			} else if len(toks) == 3 && toks[2] == "/" {
-				fmt.Fprintf(&buf, "%s\t%s\n", path, toks[1])
-			} else {
-				fmt.Fprintf(&buf, "%s\t%s\n", path, a)
-			}
-		} else {
-			pd := zx.Dir{
-				"path": path,
-				"name": fpath.Base(path),
-				"mode": "0644",
-				"type": "p",
-				"addr": a,
-			}
-			if zx.EqualDirs(pd, p) {
-				fmt.Fprintf(&buf, "%s\t%s\n", p["path"], p["addr"])
-			} else {
-				fmt.Fprintf(&buf, "%s\n", p)
-			}
-		}
+	for _, p := range ns.Entries() {
+		fmt.Fprintf(&buf, "%s\n", entLine(p))
 	}
 	return buf.String()
 }
 
-// If ln is "path addr" and addr is of the form
+// Return the line describing the mount entry p in the format of Parse.
+func entLine(p zx.Dir) string {
+	if p["type"] != "p" {
+		return p.String()
+	}
+	path := p["path"]
+	opts := ""
+	if o := p["opts"]; o != "" {
+		opts = "\t" + o
+	}
+	if a := p["addr"]; strings.HasPrefix(a, "lfs!") {
+		toks := strings.Split(a, "!")
+		if len(toks) == 3 && path == toks[1] && toks[2] == "/" && opts == "" {
+			return path
+		} else if len(toks) == 3 && toks[2] == "/" {
+			return fmt.Sprintf("%s\t%s%s", path, toks[1], opts)
+		}
+		return fmt.Sprintf("%s\t%s%s", path, a, opts)
+	}
+	pd := zx.Dir{
+		"path": path,
+		"name": fpath.Base(path),
+		"mode": "0644",
+		"type": "p",
+		"addr": p["addr"],
+	}
+	if opts != "" {
+		pd["opts"] = p["opts"]
+	}
+	if zx.EqualDirs(pd, p) {
+		return fmt.Sprintf("%s\t%s%s", p["path"], p["addr"], opts)
+	}
+	return p.String()
+}
+
+// If ln is "path addr" or "path addr opts" and addr is of the form
 // proto ! ... ! path
 // or
 // /one/path
@@ -189,54 +215,62 @@ func specialForm(ln string) zx.Dir {
 		return nil
 	}
 	toks := strings.Fields(ln)
-	if len(toks) > 2 {
+	if len(toks) > 3 {
 		return nil
 	}
-	addr := toks[len(toks)-1]
+	addr := toks[0]
+	if len(toks) > 1 {
+		addr = toks[1]
+	}
 	if n = strings.IndexRune(addr, '!'); n < 0 {
 		addr = fmt.Sprintf("lfs!%s!/", addr)
 	} else {
-		els := strings.Split(addr, "!")
-		if els[0] != "zx" && els[0] != "lfs" {
-			els = append([]string{"zx"}, els...)
-			addr = "zx!" + addr
-		}
-		if els[0] == "lfs" {
-			switch len(els) {
-			case 2:
-				addr += "!/"
-			}
-		} else {
-			switch len(els) {
-			case 6: // zx!unix!localhost!zx!main!/
-			case 5: // zx!unix!localhost!zx!main
-				addr += "!/"
-			case 4: // zx!unix!localhost!zx
-				addr += "!main!/"
-			case 3, 2:
-				oaddr := strings.Join(els[1:], "!")
-				naddr := rzx.FillAddr(oaddr)
-				addr = els[0] + "!" + naddr + "!main!/"
-			}
-		}
+		addr = fillAddr(addr)
 	}
-	return zx.Dir{
+	d := zx.Dir{
 		"path": toks[0],
 		"addr": addr,
 		"name": fpath.Base(toks[0]),
 	}
+	if len(toks) == 3 {
+		d["opts"] = toks[2]
+	}
+	return d
+}
+
+// Parse a line describing a mount entry, as found in ns descriptions.
+func parseEnt(ln string) (zx.Dir, error) {
+	d := specialForm(ln)
+	p := d["path"]
+	if d == nil {
+		d, _ = zx.ParseDir(ln)
+		p = d["path"]
+	}
+	if len(d) == 0 || p == "" {
+		return nil, fmt.Errorf("bad ns entry for dir <%s>", d)
+	}
+	if err := chkOpts(d["opts"]); err != nil {
+		return nil, fmt.Errorf("ns entry for %s: %s", p, err)
+	}
+	return d, nil
 }
 
 // Recreate a name space provided its printed representation.
 // It accepts the special line formats
-// 	path addr
-// 	path filepath
+// 	path addr [opts]
+// 	path filepath [opts]
 // to dial the given addr or use the given lfs filepath and mount it at path.
+// Opts is a comma separated list of mount options:
+//	ro	the tree is read-only
+//	cache	use a zxc cache for the tree
+//	auth=name	authenticate using the keys for the auth domain name
+//	create	new files in a union are created at this tree
+// The options are kept in the "opts" attribute of the mounted dir.
 //
 // A full addr is proto!net!host!port!tree!path,
-// where proto can be zx|lfs.
+// where proto can be zx|lfs or any other protocol registered.
 // For lfs, the addr is of the form lfs!lfsroot!path
-// zx is implied it no proto is given.
+// zx is implied it no registered proto is given.
 // Any suffix components may be absent so we accept
 //	localhost!zx	-> zx!tcp!localhost!zx!main!/
 //	unix!localhost!zx	-> zx!unix!localhost!zx!main!/
@@ -259,23 +293,19 @@ func Parse(s string) (*NS, error) {
 		if len(ln) == 0 || ln[0] == '#' {
 			continue
 		}
-		d := specialForm(ln)
-		p := d["path"]
-		if d == nil {
-			d, _ = zx.ParseDir(ln)
-			p = d["path"]
-		}
-		if len(d) == 0 || p == "" {
-			return nil, fmt.Errorf("bad ns entry for dir <%s>", d)
+		d, err := parseEnt(ln)
+		if err != nil {
+			return nil, err
 		}
 		if err := ns.Mount(d, After); err != nil {
 			return nil, err
 		}
 	}
+	ns.journal = nil
 	return ns, nil
 }
 
-// Create a copy of the ns.
+// Create a copy of the ns, including its journal.
 func (ns *NS) Dup() *NS {
 	var b bytes.Buffer
 	fmt.Fprintf(&b, "%s", ns)
@@ -283,6 +313,7 @@ func (ns *NS) Dup() *NS {
 	if err != nil {
 		panic("NS.Dup: didn't parse a correct dir string")
 	}
+	x.journal = ns.Journal()
 	return x
 }
 
@@ -342,7 +373,11 @@ func (ns *NS) Mount(d zx.Dir, flag Flag) error {
 	d["name"] = path.Base(name)
 	ns.lk.Lock()
 	defer ns.lk.Unlock()
-	return ns.mount(d, flag)
+	if err := ns.mount(d, flag); err != nil {
+		return err
+	}
+	ns.logMount(d, flag)
+	return nil
 }
 
 func (p *prefix) unmount(d zx.Dir) bool {
@@ -393,7 +428,11 @@ func (ns *NS) Unmount(fname string, d zx.Dir) error {
 	}
 	ns.lk.Lock()
 	defer ns.lk.Unlock()
-	return ns.unmount(name, d)
+	if err := ns.unmount(name, d); err != nil {
+		return err
+	}
+	ns.logUnmount(name, d)
+	return nil
 }
 
 // Resolve a name and return the prefix path and the array of mount points for it.
@@ -425,7 +464,7 @@ func (ns *NS) Resolve(name string) (pref string, mnts []zx.Dir, err error) {
 	suff := zx.Suffix(path, p.name)
 	mnts = make([]zx.Dir, 0, len(p.mnt))
 	for _, d := range p.mnt {
-		if d.IsFinder() || suff == "" || suff == "/" {
+		if isFinder(d) || suff == "" || suff == "/" {
 			d = d.Dup()
 			if suff != "/" && suff != "" {
 				if a := d["addr"]; len(a) > 0 && a[len(a)-1] == '/' {
@@ -4,12 +4,16 @@ import (
 	"bytes"
 	"clive/dbg"
 	"clive/net"
+	"clive/net/auth"
+	"clive/u"
 	"clive/zx"
 	"clive/zx/fstest"
 	"clive/zx/rzx"
 	"clive/zx/zux"
 	"clive/zx/zxc"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	fpath "path"
 	"strings"
@@ -311,19 +315,6 @@ var finds = []findTest{
 			`d rwxr-xr-x      0 /a/b/c/d    addr lfs!/tmp/ns_test!/d`,
 			`d rwxr-xr-x      0 /a/b/c/e    addr lfs!/tmp/ns_test!/e`,
 			`d rwxr-xr-x      0 /a/b/c/e/f  addr lfs!/tmp/ns_test!/e/f`,
-			`d rwxr-xr-x      0 /a/b/c      addr lfs!/tmp/ns_test!/`,
-			`c rw-r--r--      0 /a/b/c/Ctl  addr lfs!/tmp/ns_test!/Ctl`,
-			`- rw-r--r--      0 /a/b/c/1    addr lfs!/tmp/ns_test!/1`,
-			`- rw-r--r--  30.9k /a/b/c/2    addr lfs!/tmp/ns_test!/2`,
-			`d rwxr-xr-x      0 /a/b/c/a    addr lfs!/tmp/ns_test!/a`,
-			`- rw-r--r--   9.9k /a/b/c/a/a1 addr lfs!/tmp/ns_test!/a/a1`,
-			`- rw-r--r--  20.9k /a/b/c/a/a2 addr lfs!/tmp/ns_test!/a/a2`,
-			`d rwxr-xr-x      0 /a/b/c/a/b  addr lfs!/tmp/ns_test!/a/b`,
-			`d rwxr-xr-x      0 /a/b/c/a/b/c addr lfs!/tmp/ns_test!/a/b/c`,
-			`- rw-r--r--  43.9k /a/b/c/a/b/c/c3 addr lfs!/tmp/ns_test!/a/b/c/c3`,
-			`d rwxr-xr-x      0 /a/b/c/d    addr lfs!/tmp/ns_test!/d`,
-			`d rwxr-xr-x      0 /a/b/c/e    addr lfs!/tmp/ns_test!/e`,
-			`d rwxr-xr-x      0 /a/b/c/e/f  addr lfs!/tmp/ns_test!/e/f`,
 			`d rwxr-xr-x      0 /d          addr lfs!/tmp/ns_test!/d`,
 			`d rwxr-xr-x      0 /e          addr lfs!/tmp/ns_test!/e`,
 			`d rwxr-xr-x      0 /e/f        addr lfs!/tmp/ns_test!/e/f`,
@@ -354,13 +345,6 @@ var finds = []findTest{
 			`d rwxr-xr-x      0 /a/b/c/d    addr lfs!/tmp/ns_test!/d`,
 			`d rwxr-xr-x      0 /a/b/c/e    addr lfs!/tmp/ns_test!/e`,
 			`d rwxr-xr-x      0 /a/b/c/e/f  addr lfs!/tmp/ns_test!/e/f`,
-			`d rwxr-xr-x      0 /a/b/c      addr lfs!/tmp/ns_test!/`,
-			`d rwxr-xr-x      0 /a/b/c/a    addr lfs!/tmp/ns_test!/a`,
-			`d rwxr-xr-x      0 /a/b/c/a/b  addr lfs!/tmp/ns_test!/a/b`,
-			`d rwxr-xr-x      0 /a/b/c/a/b/c addr lfs!/tmp/ns_test!/a/b/c`,
-			`d rwxr-xr-x      0 /a/b/c/d    addr lfs!/tmp/ns_test!/d`,
-			`d rwxr-xr-x      0 /a/b/c/e    addr lfs!/tmp/ns_test!/e`,
-			`d rwxr-xr-x      0 /a/b/c/e/f  addr lfs!/tmp/ns_test!/e/f`,
 		},
 	},
 	findTest{
@@ -370,9 +354,6 @@ var finds = []findTest{
 			`d rwxr-xr-x      0 /a/b/c/a    addr lfs!/tmp/ns_test!/a`,
 			`d rwxr-xr-x      0 /a/b/c/a/b  addr lfs!/tmp/ns_test!/a/b`,
 			`d rwxr-xr-x      0 /a/b/c/a/b/c addr lfs!/tmp/ns_test!/a/b/c`,
-			`d rwxr-xr-x      0 /a/b/c/a    addr lfs!/tmp/ns_test!/a`,
-			`d rwxr-xr-x      0 /a/b/c/a/b  addr lfs!/tmp/ns_test!/a/b`,
-			`d rwxr-xr-x      0 /a/b/c/a/b/c addr lfs!/tmp/ns_test!/a/b/c`,
 		},
 	},
 }
@@ -512,6 +493,481 @@ func runTest(t *testing.T, fn fstest.TestFunc) {
 	}
 }
 
+func TestRegisterProto(t *testing.T) {
+	os.RemoveAll(tdir)
+	fstest.MkTree(t, tdir)
+	defer os.RemoveAll(tdir)
+	fs, err := zux.NewZX(tdir)
+	if err != nil {
+		t.Fatalf("lfs: %s", err)
+	}
+	RegisterProto("tst", func(d zx.Dir) (zx.Fs, error) {
+		return fs, nil
+	})
+	RegisterProtoAddr("tst", func(els []string) string {
+		if len(els) == 2 {
+			els = append(els, "/")
+		}
+		return strings.Join(els, "!")
+	})
+	ns := mkns(t, "/\t/tmp\n/t\ttst!x\n/u\tlfs!/tmp\n")
+	printf("ns is `%s`\n", ns)
+	out := "/\t/tmp\n/t\ttst!x!/\n/u\t/tmp\n"
+	if ns.String() != out {
+		t.Fatalf("bad ns")
+	}
+	d, err := zx.Stat(ns, "/t/a/a1")
+	if err != nil {
+		t.Fatalf("stat: %s", err)
+	}
+	printf("got %s\n", d.Fmt())
+	if d["type"] != "-" {
+		t.Fatalf("bad stat")
+	}
+	_, ds, err := ns.Resolve("/t/a/a1")
+	if err != nil || len(ds) != 1 || ds[0]["addr"] != "tst!x!/a/a1" {
+		t.Fatalf("bad resolve %v %s", ds, err)
+	}
+	if _, err := zx.Stat(ns, "/t/nothere"); err == nil {
+		t.Fatalf("stat did not fail")
+	}
+	_, err = DirFs(zx.Dir{"addr": "none!x!/"})
+	if err == nil {
+		t.Fatalf("unknown proto did not fail")
+	}
+
+	// trees that are not finders are not walked
+	RegisterProto("nf", func(d zx.Dir) (zx.Fs, error) {
+		return statFs{fs}, nil
+	})
+	AddLfsPath(tdir, nil)
+	ns = mkns(t, fmt.Sprintf("/\t%s\n/n\tnf!x!/\n", tdir))
+	if _, ds, err := ns.Resolve("/n/a"); err == nil {
+		t.Fatalf("resolve in a non finder: %v", ds)
+	}
+	if _, err := zx.Stat(ns, "/n"); err != nil {
+		t.Fatalf("stat: %s", err)
+	}
+	fc := ns.Find("/", "depth<2", "/", "/", 0)
+	for d := range fc {
+		printf("\t%s\n", d.Fmt())
+	}
+	if err := cerror(fc); err != nil {
+		t.Fatalf("find: %s", err)
+	}
+}
+
+// A tree that is not a finder
+struct statFs {
+	fs zx.Fs
+}
+
+func (s statFs) Stat(p string) <-chan zx.Dir {
+	return s.fs.Stat(p)
+}
+
+func TestXMove(t *testing.T) {
+	tdir2 := tdir + "2"
+	os.RemoveAll(tdir)
+	os.RemoveAll(tdir2)
+	fstest.MkTree(t, tdir)
+	defer os.RemoveAll(tdir)
+	defer os.RemoveAll(tdir2)
+	if err := os.Mkdir(tdir2, 0755); err != nil {
+		t.Fatalf("mkdir: %s", err)
+	}
+	AddLfsPath(tdir, nil)
+	AddLfsPath(tdir2, nil)
+	defer delLfsPath(tdir2)
+	ns := mkns(t, fmt.Sprintf("/s\t%s\n/d\t%s\n/m\t%s/e\n", tdir, tdir2, tdir))
+	printf("ns is `%s`\n", ns)
+	ncopied := 0
+	names := map[string]string{}
+	ns.Progress = func(from, to string, size int64) {
+		printf("copied %s %s %d\n", from, to, size)
+		ncopied++
+		names[from] = to
+	}
+
+	// same tree
+	if err := <-ns.Move("/s/1", "/s/1x"); err != nil {
+		t.Fatalf("move: %s", err)
+	}
+	if ncopied != 0 {
+		t.Fatalf("same tree move did copy")
+	}
+	if err := <-ns.Link("/s/2", "/s/2x"); err != nil {
+		t.Fatalf("link: %s", err)
+	}
+	if err := <-ns.Link("/s/2", "/d/2x"); err == nil {
+		t.Fatalf("cross tree link did not fail")
+	}
+
+	// across trees
+	ad, err := zx.Stat(ns, "/s/a")
+	if err != nil {
+		t.Fatalf("stat: %s", err)
+	}
+	if err := <-ns.Move("/s/a", "/d/x"); err != nil {
+		t.Fatalf("move: %s", err)
+	}
+	if ncopied != 6 {
+		t.Fatalf("copied %d files", ncopied)
+	}
+	if names["/s/a"] != "/d/x" || names["/s/a/b/c/c3"] != "/d/x/b/c/c3" {
+		t.Fatalf("bad names in progress reports: %v", names)
+	}
+	if _, err := zx.Stat(ns, "/s/a"); err == nil {
+		t.Fatalf("source not removed")
+	}
+	xd, err := zx.Stat(ns, "/d/x")
+	if err != nil {
+		t.Fatalf("stat: %s", err)
+	}
+	if xd["mode"] != ad["mode"] || xd["mtime"] != ad["mtime"] {
+		t.Fatalf("attrs not preserved: %s vs %s", xd.Fmt(), ad.Fmt())
+	}
+	for _, f := range []string{"a1", "a2", "b/c/c3"} {
+		dat, err := zx.GetAll(ns, "/d/x/"+f)
+		if err != nil {
+			t.Fatalf("get: %s", err)
+		}
+		if bytes.Compare(dat, fstest.FileData["/a/"+f]) != 0 {
+			t.Fatalf("bad data for %s", f)
+		}
+	}
+
+	// names reported for trees not rooted at /
+	if err := <-ns.Move("/m/f", "/d/y"); err != nil {
+		t.Fatalf("move: %s", err)
+	}
+	if names["/m/f"] != "/d/y" {
+		t.Fatalf("bad names in progress reports: %v", names)
+	}
+	if err := <-ns.Move("/d/y", "/m/f"); err != nil {
+		t.Fatalf("move: %s", err)
+	}
+
+	// failures leave no partial copies
+	if err := <-ns.Move("/s/nothere", "/d/y"); err == nil {
+		t.Fatalf("move did not fail")
+	}
+	ds, err := zx.GetDir(ns, "/d")
+	if err != nil {
+		t.Fatalf("getdir: %s", err)
+	}
+	for _, d := range ds {
+		printf("\t%s\n", d.Fmt())
+		if d["name"] != "x" && d["name"] != "Ctl" {
+			t.Fatalf("partial copy left: %s", d["name"])
+		}
+	}
+}
+
+func TestTree(t *testing.T) {
+	os.RemoveAll(tdir)
+	fstest.MkTree(t, tdir)
+	defer os.RemoveAll(tdir)
+	AddLfsPath(tdir, nil)
+	ns := mkns(t, fmt.Sprintf("/\t%s\n", tdir))
+	tr := NewTree(ns)
+	d, err := zx.Stat(tr, "/Ctl")
+	if err != nil || d["type"] != "c" {
+		t.Fatalf("stat ctl: %v %v", d, err)
+	}
+	ctl := fmt.Sprintf("mount /x %s\nmount -b /x lfs!%s!/a\n", tdir, tdir)
+	if err := zx.PutAll(tr, "/Ctl", []byte(ctl)); err != nil {
+		t.Fatalf("put ctl: %s", err)
+	}
+	dat, err := zx.GetAll(tr, "/Ctl")
+	if err != nil {
+		t.Fatalf("get ctl: %s", err)
+	}
+	printf("ctl is `%s`\n", dat)
+	out := fmt.Sprintf("ns ns:\n/\t%s\n/x\tlfs!%s!/a\n/x\t%s\n", tdir, tdir, tdir)
+	if string(dat) != out {
+		t.Fatalf("bad ctl")
+	}
+	if _, err := zx.Stat(tr, "/x/a1"); err != nil {
+		t.Fatalf("stat: %s", err)
+	}
+	ctl = fmt.Sprintf("unmount /x lfs!%s!/a\n", tdir)
+	if err := zx.PutAll(tr, "/Ctl", []byte(ctl)); err != nil {
+		t.Fatalf("put ctl: %s", err)
+	}
+	if _, err := zx.Stat(tr, "/x/a/a1"); err != nil {
+		t.Fatalf("stat: %s", err)
+	}
+	if err := zx.PutAll(tr, "/Ctl", []byte("bad ctl\n")); err == nil {
+		t.Fatalf("bad ctl did not fail")
+	}
+	if err := <-tr.Remove("/Ctl"); err == nil {
+		t.Fatalf("remove ctl did not fail")
+	}
+	nctls := 0
+	for d := range tr.Find("/", "depth<2", "/", "/", 0) {
+		printf("\t%s\n", d.Fmt())
+		if d["path"] == "/Ctl" {
+			if d["type"] != "c" || d["addr"] != "" {
+				t.Fatalf("not our ctl")
+			}
+			nctls++
+		}
+	}
+	if nctls != 1 {
+		t.Fatalf("%d ctls found", nctls)
+	}
+}
+
+func TestTreeAuth(t *testing.T) {
+	os.RemoveAll(tdir)
+	fstest.MkTree(t, tdir)
+	defer os.RemoveAll(tdir)
+	AddLfsPath(tdir, nil)
+	ns := mkns(t, fmt.Sprintf("/\t%s\n", tdir))
+	tr := NewTree(ns)
+	x, err := tr.Auth(&auth.Info{Uid: "otheruser", Gids: map[string]bool{}})
+	if err != nil {
+		t.Fatalf("auth: %s", err)
+	}
+	afs := x.(*Tree)
+	if _, err := zx.Stat(afs, "/a/a1"); err != nil {
+		t.Fatalf("stat: %s", err)
+	}
+	if _, err := zx.Stat(afs, "/Ctl"); err != nil {
+		t.Fatalf("stat ctl: %s", err)
+	}
+	ctl := fmt.Sprintf("mount /x %s\n", tdir)
+	if err := zx.PutAll(afs, "/Ctl", []byte(ctl)); err == nil {
+		t.Fatalf("ctl by other user did not fail")
+	}
+	x, _ = tr.Auth(&auth.Info{Uid: u.Uid, Gids: map[string]bool{}})
+	ofs := x.(*Tree)
+	if err := zx.PutAll(ofs, "/Ctl", []byte(ctl)); err == nil {
+		t.Fatalf("lfs mount by remote user did not fail")
+	}
+	ctl = "mount /x zx!unix!8089!/tmp\n"
+	if err := zx.PutAll(ofs, "/Ctl", []byte(ctl)); err != nil {
+		t.Fatalf("ctl: %s", err)
+	}
+	// the views share the name space
+	if _, ds, err := ns.Resolve("/x"); err != nil || len(ds) != 1 {
+		t.Fatalf("resolve: %v %v", ds, err)
+	}
+
+	// trees that can't authenticate are only for the owner
+	fs, err := zux.NewZX(tdir)
+	if err != nil {
+		t.Fatalf("lfs: %s", err)
+	}
+	RegisterProto("noauth", func(d zx.Dir) (zx.Fs, error) {
+		return statFs{fs}, nil
+	})
+	ctl = "mount /na noauth!x!/\n"
+	if err := zx.PutAll(ofs, "/Ctl", []byte(ctl)); err != nil {
+		t.Fatalf("ctl: %s", err)
+	}
+	if _, err := zx.Stat(ofs, "/na"); err != nil {
+		t.Fatalf("stat by owner: %s", err)
+	}
+	if _, err := zx.Stat(afs, "/na"); err == nil {
+		t.Fatalf("stat by other user did not fail")
+	}
+}
+
+func TestJournal(t *testing.T) {
+	os.RemoveAll(tdir)
+	fstest.MkTree(t, tdir)
+	defer os.RemoveAll(tdir)
+	AddLfsPath(tdir, nil)
+	ns := mkns(t, fmt.Sprintf("/\t%s\n", tdir))
+	if len(ns.Journal()) != 0 {
+		t.Fatalf("parse made changes")
+	}
+	ctl := fmt.Sprintf("mount -b /x lfs!%s!/a\nmount /y %s\nunmount /y\n", tdir, tdir)
+	if err := ns.Ctl(ctl); err != nil {
+		t.Fatalf("ctl: %s", err)
+	}
+	js := []string{}
+	for _, c := range ns.Journal() {
+		printf("\t%s\n", c)
+		js = append(js, c.Cmd)
+	}
+	out := fmt.Sprintf("mount -b /x\tlfs!%s!/a\nmount -a /y\t%s\nunmount /y", tdir, tdir)
+	if strings.Join(js, "\n") != out {
+		t.Fatalf("bad journal %q", js)
+	}
+	if nns := ns.Dup(); len(nns.Journal()) != 3 {
+		t.Fatalf("dup did not keep the journal")
+	}
+
+	nsf := tdir + ".ns"
+	defer os.Remove(nsf)
+	fns := mkns(t, fmt.Sprintf("/\t%s\n/z\t%s\n", tdir, tdir))
+	if err := fns.Save(nsf); err != nil {
+		t.Fatalf("save: %s", err)
+	}
+	dat, err := ioutil.ReadFile(nsf)
+	if err != nil || string(dat) != fns.String() {
+		t.Fatalf("saved %q %v", dat, err)
+	}
+	if err := ns.Reload(nsf); err != nil {
+		t.Fatalf("reload: %s", err)
+	}
+	printf("reloaded ns is\n%s\n", ns)
+	out = fmt.Sprintf("/\t%s\n/x\tlfs!%s!/a\n/z\t%s\n", tdir, tdir, tdir)
+	if ns.String() != out {
+		t.Fatalf("bad reloaded ns")
+	}
+	if _, err := zx.Stat(ns, "/x/a1"); err != nil {
+		t.Fatalf("stat: %s", err)
+	}
+}
+
+func TestMountOpts(t *testing.T) {
+	os.RemoveAll(tdir)
+	fstest.MkTree(t, tdir)
+	defer os.RemoveAll(tdir)
+	AddLfsPath(tdir, nil)
+	lns := fmt.Sprintf("/\t%s\tro\n/b\tlfs!%s!/a\tcache,ro\n/c\tzx!tcp!h!zx!main!/\tauth=foo\n",
+		tdir, tdir)
+	ns := mkns(t, lns)
+	printf("ns is `%s`\n", ns)
+	if ns.String() != lns || ns.Dup().String() != lns {
+		t.Fatalf("bad ns")
+	}
+	for _, o := range []string{"rx", "auth", "ro,auth=", "ro=1"} {
+		if _, err := Parse("/x\t/tmp\t" + o); err == nil {
+			t.Fatalf("option %s did not fail", o)
+		}
+	}
+	if _, err := zx.Stat(ns, "/a/a1"); err != nil {
+		t.Fatalf("stat: %s", err)
+	}
+	if err := zx.PutAll(ns, "/a/new", []byte("hi")); err == nil {
+		t.Fatalf("put on ro mount did not fail")
+	}
+	dat, err := zx.GetAll(ns, "/b/a1")
+	if err != nil {
+		t.Fatalf("get: %s", err)
+	}
+	if bytes.Compare(dat, fstest.FileData["/a/a1"]) != 0 {
+		t.Fatalf("bad data")
+	}
+	_, ds, err := ns.Resolve("/b/a1")
+	if err != nil {
+		t.Fatalf("resolve: %s", err)
+	}
+	if ds[0]["opts"] != "cache,ro" {
+		t.Fatalf("bad opts")
+	}
+	d := ds[0].Dup()
+	d["opts"] = "cache"
+	fs1, err := DirFs(d)
+	if err != nil {
+		t.Fatalf("dirfs: %s", err)
+	}
+	fs2, err := DirFs(d.Dup())
+	if err != nil {
+		t.Fatalf("dirfs: %s", err)
+	}
+	if _, ok := fs1.(*zxc.Fs); !ok || fs1 != fs2 {
+		t.Fatalf("cache not used")
+	}
+}
+
+func TestUnion(t *testing.T) {
+	tdir2 := tdir + "2"
+	os.RemoveAll(tdir)
+	os.RemoveAll(tdir2)
+	fstest.MkTree(t, tdir)
+	defer os.RemoveAll(tdir)
+	defer os.RemoveAll(tdir2)
+	if err := os.Mkdir(tdir2, 0755); err != nil {
+		t.Fatalf("mkdir: %s", err)
+	}
+	for _, f := range []string{"2", "x"} {
+		if err := ioutil.WriteFile(tdir2+"/"+f, []byte("union "+f), 0644); err != nil {
+			t.Fatalf("write: %s", err)
+		}
+	}
+	AddLfsPath(tdir, nil)
+	AddLfsPath(tdir2, nil)
+	defer delLfsPath(tdir2)
+	ns := mkns(t, fmt.Sprintf("/u\t%s\n/u\t%s\tcreate\n", tdir, tdir2))
+	printf("ns is `%s`\n", ns)
+
+	// stat
+	for _, p := range []string{"/u/a/a1", "/u/x"} {
+		if _, err := zx.Stat(ns, p); err != nil {
+			t.Fatalf("stat %s: %s", p, err)
+		}
+	}
+	if _, err := zx.Stat(ns, "/u/nothere"); err == nil {
+		t.Fatalf("stat did not fail")
+	}
+
+	// get
+	dat, err := zx.GetAll(ns, "/u/2")
+	if err != nil || bytes.Compare(dat, fstest.FileData["/2"]) != 0 {
+		t.Fatalf("get: bad data: %s", err)
+	}
+	dat, err = zx.GetAll(ns, "/u/x")
+	if err != nil || string(dat) != "union x" {
+		t.Fatalf("get: bad data: %s", err)
+	}
+	ds, err := zx.GetDir(ns, "/u")
+	if err != nil {
+		t.Fatalf("getdir: %s", err)
+	}
+	names := []string{}
+	for _, d := range ds {
+		names = append(names, d["name"])
+	}
+	printf("names %v\n", names)
+	if strings.Join(names, " ") != "Ctl 1 2 a d e x" {
+		t.Fatalf("bad union dir %v", names)
+	}
+	ds, err = zx.GetDir(ns, "/u/a")
+	if err != nil || len(ds) != 3 {
+		t.Fatalf("getdir: %v %v", ds, err)
+	}
+
+	// put
+	if err := zx.PutAll(ns, "/u/new", []byte("new")); err != nil {
+		t.Fatalf("put: %s", err)
+	}
+	if _, err := os.Stat(tdir2 + "/new"); err != nil {
+		t.Fatalf("not created at the create tree")
+	}
+	if err := zx.PutAll(ns, "/u/2", []byte("two")); err != nil {
+		t.Fatalf("put: %s", err)
+	}
+	if dat, _ := ioutil.ReadFile(tdir + "/2"); string(dat) != "two" {
+		t.Fatalf("not put where it exists")
+	}
+
+	// remove
+	if err := <-ns.Remove("/u/x"); err != nil {
+		t.Fatalf("remove: %s", err)
+	}
+	if _, err := os.Stat(tdir2 + "/x"); err == nil {
+		t.Fatalf("not removed")
+	}
+	if err := <-ns.Remove("/u/x"); err == nil {
+		t.Fatalf("remove did not fail")
+	}
+
+	// a failing member fails the listing
+	RegisterProto("bad", func(d zx.Dir) (zx.Fs, error) {
+		return nil, errors.New("bad tree")
+	})
+	ns = mkns(t, fmt.Sprintf("/u\t%s\n/u\tbad!x!/\n", tdir))
+	if ds, err := zx.GetDir(ns, "/u"); err == nil {
+		t.Fatalf("getdir did not fail: %v", ds)
+	}
+}
+
 func TestFs(t *testing.T) {
 	runTest(t, nil)
 }
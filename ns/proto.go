@@ -0,0 +1,280 @@
+package ns
+
+import (
+	"clive/net/auth"
+	"clive/u"
+	"clive/zx"
+	"clive/zx/rzx"
+	"clive/zx/zxc"
+	"fmt"
+	fpath "path"
+	"strings"
+	"sync"
+)
+
+// Function used to make (or dial) the tree for a mounted dir of a protocol.
+// It may update the dir addr, as lfs does.
+type DirFsFunc func(d zx.Dir) (zx.Fs, error)
+
+// Function used to complete a partial address for a protocol
+// in the special line formats of Parse.
+// It is given the address split at '!', including the protocol name,
+// and returns the full address.
+type AddrFunc func(els []string) string
+
+struct proto {
+	fs   DirFsFunc
+	addr AddrFunc
+}
+
+var (
+	protos   = map[string]*proto{}
+	protoslk sync.RWMutex
+
+	// caches for mounts using the cache option, by tree addr
+	caches   = map[string]zx.Fs{}
+	cacheslk sync.Mutex
+
+	// mount options known, and if they take a value
+	knownOpts = map[string]bool{"ro": false, "cache": false, "auth": true, "create": false}
+)
+
+func init() {
+	RegisterProto("lfs", lfsDirFs)
+	RegisterProtoAddr("lfs", lfsAddr)
+	RegisterProto("zx", zxDirFs)
+	RegisterProtoAddr("zx", zxAddr)
+}
+
+// Register fn to make the trees for dirs mounted with the given protocol,
+// ie. those with addrs of the form proto!...
+// DirFs relies on this to reach the tree for a mounted dir.
+// Trees that do not implement zx.Finder and zx.FindGetter are
+// mounted as single files, like dirs without addrs are.
+// Registering a protocol again replaces the previous one.
+// The lfs and zx protocols are always registered.
+func RegisterProto(name string, fn DirFsFunc) {
+	if name == "" || strings.ContainsRune(name, '!') || fn == nil {
+		panic("ns: bad RegisterProto")
+	}
+	protoslk.Lock()
+	defer protoslk.Unlock()
+	if p := protos[name]; p != nil {
+		p.fs = fn
+	} else {
+		protos[name] = &proto{fs: fn}
+	}
+}
+
+// Register fn to complete partial addrs for the given (registered) protocol
+// when parsing name spaces.
+// Addrs for protocols without such function are used verbatim.
+func RegisterProtoAddr(name string, fn AddrFunc) {
+	protoslk.Lock()
+	defer protoslk.Unlock()
+	p := protos[name]
+	if p == nil {
+		panic("ns: RegisterProtoAddr for unknown proto " + name)
+	}
+	p.addr = fn
+}
+
+// Return the names of the protocols registered.
+func Protos() []string {
+	protoslk.RLock()
+	defer protoslk.RUnlock()
+	names := make([]string, 0, len(protos))
+	for n := range protos {
+		names = append(names, n)
+	}
+	return names
+}
+
+func lookupProto(name string) *proto {
+	protoslk.RLock()
+	defer protoslk.RUnlock()
+	return protos[name]
+}
+
+// Complete a partial addr using the registered protocols.
+// The zx protocol is implied if the addr does not start with a known one.
+func fillAddr(addr string) string {
+	els := strings.Split(addr, "!")
+	p := lookupProto(els[0])
+	if p == nil {
+		els = append([]string{"zx"}, els...)
+		addr = "zx!" + addr
+		p = lookupProto("zx")
+	}
+	if p.addr == nil {
+		return addr
+	}
+	return p.addr(els)
+}
+
+// Is d a dir for a tree that can be walked (and supports the
+// finder protocol)?
+// Trees for registered protocols are if they implement both
+// zx.Finder and zx.FindGetter.
+func isFinder(d zx.Dir) bool {
+	if d.IsFinder() {
+		return true
+	}
+	if lookupProto(d.Proto()) == nil {
+		return false
+	}
+	fs, err := DirFs(d)
+	if err != nil {
+		return true // and report the error when it's used
+	}
+	_, ok := fs.(zx.Finder)
+	_, ok2 := fs.(zx.FindGetter)
+	return ok && ok2
+}
+
+// Check that the mount options given are known and well formed.
+func chkOpts(opts string) error {
+	if opts == "" {
+		return nil
+	}
+	for _, o := range strings.Split(opts, ",") {
+		toks := strings.SplitN(o, "=", 2)
+		hasval, ok := knownOpts[toks[0]]
+		if !ok {
+			return fmt.Errorf("unknown mount option '%s'", o)
+		}
+		if hasval != (len(toks) == 2) || hasval && toks[1] == "" {
+			return fmt.Errorf("bad mount option '%s'", o)
+		}
+	}
+	return nil
+}
+
+// Return the mount options for d and their values.
+func mntOpts(d zx.Dir) map[string]string {
+	opts := map[string]string{}
+	if d["opts"] == "" {
+		return opts
+	}
+	for _, o := range strings.Split(d["opts"], ",") {
+		toks := strings.SplitN(o, "=", 2)
+		if len(toks) == 1 {
+			toks = append(toks, "")
+		}
+		opts[toks[0]] = toks[1]
+	}
+	return opts
+}
+
+// Dial the server for this dir (if not already dialed) and return it,
+// the dir addr is updated.
+// The protocol in the dir addr must be registered (see RegisterProto).
+// The tree returned is wrapped as said by the mount options in the dir.
+func DirFs(d zx.Dir) (zx.Fs, error) {
+	return dirFsFor(d, nil)
+}
+
+// Like DirFs, but trees that can authenticate users are used as
+// the user for ai, if not nil.
+// Other trees would be used with our credentials, and are refused
+// unless ai is for our user (or elf).
+func dirFsFor(d zx.Dir, ai *auth.Info) (zx.Fs, error) {
+	p := lookupProto(d.Proto())
+	if p == nil {
+		return nil, fmt.Errorf("ns: no tree for addr %q", d["addr"])
+	}
+	fs, err := p.fs(d)
+	if err != nil {
+		return nil, err
+	}
+	opts := mntOpts(d)
+	if _, ok := opts["cache"]; ok {
+		if fs, err = cachedFs(d, fs, opts["auth"]); err != nil {
+			return nil, err
+		}
+	}
+	if ai != nil {
+		afs, ok := fs.(zx.Auther)
+		if !ok && !ai.InGroup(u.Uid) {
+			return nil, fmt.Errorf("%s: %s", d["addr"], zx.ErrPerm)
+		}
+		if ok {
+			if fs, err = afs.Auth(ai); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if _, ok := opts["ro"]; ok {
+		fs = zx.MakeRO(fs)
+	}
+	return fs, nil
+}
+
+// Return the cache for the tree of d, creating it if needed.
+func cachedFs(d zx.Dir, fs zx.Fs, aname string) (zx.Fs, error) {
+	key := d.SAddr() + "|" + aname
+	cacheslk.Lock()
+	defer cacheslk.Unlock()
+	if cfs, ok := caches[key]; ok {
+		return cfs, nil
+	}
+	gfs, ok := fs.(zx.Getter)
+	if !ok {
+		return nil, fmt.Errorf("ns: %s: tree is not a getter", d["addr"])
+	}
+	cfs, err := zxc.New(gfs)
+	if err != nil {
+		return nil, err
+	}
+	caches[key] = cfs
+	return cfs, nil
+}
+
+func lfsDirFs(d zx.Dir) (zx.Fs, error) {
+	addr := d["addr"]
+	toks := strings.Split(d["addr"], "!") // lfs!root!/path
+	if len(toks) != 3 {
+		return nil, fmt.Errorf("ns: no zux tree for addr %q", addr)
+	}
+	fullpath := fpath.Join(toks[1], toks[2])
+	fs, root, rel := Lfs(fullpath)
+	if fs == nil {
+		return nil, fmt.Errorf("ns: no zux tree for addr %q", addr)
+	}
+	d["addr"] = "lfs!" + root + "!" + rel
+	return fs, nil
+}
+
+// lfs!root -> lfs!root!/
+func lfsAddr(els []string) string {
+	if len(els) == 2 {
+		els = append(els, "/")
+	}
+	return strings.Join(els, "!")
+}
+
+func zxDirFs(d zx.Dir) (zx.Fs, error) {
+	addr := d.SAddr()
+	if len(addr) < 3 {
+		panic("DirFs bug")
+	}
+	addr = addr[3:] // remove zx!
+	// rzx does cache dials, no need to do it again here.
+	return rzx.DialAuth(addr, mntOpts(d)["auth"], auth.TLSclient)
+}
+
+func zxAddr(els []string) string {
+	addr := strings.Join(els, "!")
+	switch len(els) {
+	case 6: // zx!unix!localhost!zx!main!/
+	case 5: // zx!unix!localhost!zx!main
+		addr += "!/"
+	case 4: // zx!unix!localhost!zx
+		addr += "!main!/"
+	case 3, 2:
+		oaddr := strings.Join(els[1:], "!")
+		naddr := rzx.FillAddr(oaddr)
+		addr = els[0] + "!" + naddr + "!main!/"
+	}
+	return addr
+}
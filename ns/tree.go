@@ -0,0 +1,280 @@
+package ns
+
+import (
+	"bytes"
+	"clive/net/auth"
+	"clive/u"
+	"clive/zx"
+	"clive/zx/pred"
+	"fmt"
+	fpath "path"
+)
+
+/*
+	A name space exported as a single tree, eg. to serve it using rzx.
+
+	The tree has the files in the name space and a /Ctl file,
+	that replaces any /Ctl found there.
+	Reading the ctl file lists the mounts (in the format of NS.String).
+	Writing the ctl file executes one command per line
+	(see NS.Ctl for the commands understood).
+
+	Views of the tree made by Auth use the trees in the name space
+	as the authenticated user, and only the owner of the tree
+	(or elf) may write its ctl file or use trees that can't
+	authenticate users, like remote ones.
+*/
+struct Tree {
+	*NS
+	Tag string
+}
+
+var (
+	_t  zx.RWFs       = &Tree{}
+	_t2 zx.Finder     = &Tree{}
+	_t3 zx.FindGetter = &Tree{}
+	_t4 zx.Mover      = &Tree{}
+	_t5 zx.Linker     = &Tree{}
+	_t6 zx.Auther     = &Tree{}
+)
+
+// Export the given name space as a single tree.
+// Changes made through the ctl file of the tree are made to ns.
+func NewTree(ns *NS) *Tree {
+	return &Tree{NS: ns, Tag: "ns"}
+}
+
+func (t *Tree) String() string {
+	return t.Tag
+}
+
+// Return a view of the tree for the user authenticated by ai.
+func (t *Tree) Auth(ai *auth.Info) (zx.Fs, error) {
+	return &Tree{NS: t.NS.authFor(ai), Tag: t.Tag}, nil
+}
+
+func (t *Tree) ctlDir() zx.Dir {
+	return zx.Dir{
+		"name":  "Ctl",
+		"path":  "/Ctl",
+		"mode":  "0644",
+		"size":  "0",
+		"mtime": "0",
+		"type":  "c",
+		"uid":   u.Uid,
+		"gid":   u.Uid,
+		"wuid":  u.Uid,
+	}
+}
+
+func isCtl(p string) bool {
+	p, err := zx.UseAbsPath(p)
+	return err == nil && p == "/Ctl"
+}
+
+func (t *Tree) Stat(p string) <-chan zx.Dir {
+	if !isCtl(p) {
+		return t.NS.Stat(p)
+	}
+	c := make(chan zx.Dir, 1)
+	c <- t.ctlDir()
+	close(c)
+	return c
+}
+
+func (t *Tree) Get(p string, off, count int64) <-chan []byte {
+	if !isCtl(p) {
+		return t.NS.Get(p, off, count)
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "ns %s:\n", t.Tag)
+	fmt.Fprintf(&buf, "%s", t.NS)
+	resp := buf.Bytes()
+	if o := int(off); o < len(resp) {
+		resp = resp[o:]
+	} else {
+		resp = nil
+	}
+	if n := int(count); n >= 0 && n < len(resp) {
+		resp = resp[:n]
+	}
+	c := make(chan []byte, 1)
+	if len(resp) > 0 {
+		c <- resp
+	}
+	close(c)
+	return c
+}
+
+func (t *Tree) Put(p string, ud zx.Dir, off int64, dc <-chan []byte) <-chan zx.Dir {
+	if !isCtl(p) {
+		return t.NS.Put(p, ud, off, dc)
+	}
+	if t.ai != nil && !t.ai.InGroup(u.Uid) {
+		err := fmt.Errorf("%s: %s", p, zx.ErrPerm)
+		close(dc, err)
+		return derr(err)
+	}
+	rc := make(chan zx.Dir, 1)
+	go func() {
+		var buf bytes.Buffer
+		for d := range dc {
+			buf.Write(d)
+		}
+		err := cerror(dc)
+		if err == nil {
+			err = t.Ctl(buf.String())
+		}
+		if err == nil {
+			rc <- t.ctlDir()
+		}
+		close(rc, err)
+	}()
+	return rc
+}
+
+func (t *Tree) Wstat(p string, ud zx.Dir) <-chan zx.Dir {
+	if isCtl(p) {
+		return derr(fmt.Errorf("wstat %s: %s", p, zx.ErrPerm))
+	}
+	return t.NS.Wstat(p, ud)
+}
+
+func (t *Tree) Remove(p string) <-chan error {
+	if isCtl(p) {
+		return rerr(fmt.Errorf("remove %s: %s", p, zx.ErrPerm))
+	}
+	return t.NS.Remove(p)
+}
+
+func (t *Tree) RemoveAll(p string) <-chan error {
+	if isCtl(p) {
+		return rerr(fmt.Errorf("remove %s: %s", p, zx.ErrPerm))
+	}
+	return t.NS.RemoveAll(p)
+}
+
+func (t *Tree) Move(from, to string) <-chan error {
+	if isCtl(from) || isCtl(to) {
+		return rerr(fmt.Errorf("move %s: %s", from, zx.ErrPerm))
+	}
+	return t.NS.Move(from, to)
+}
+
+func (t *Tree) Link(oldp, newp string) <-chan error {
+	if isCtl(oldp) || isCtl(newp) {
+		return rerr(fmt.Errorf("link %s: %s", newp, zx.ErrPerm))
+	}
+	return t.NS.Link(oldp, newp)
+}
+
+// Return the ctl dir as reported by a find at name, or nil if it's not
+// to be reported.
+func (t *Tree) findCtl(name, fpred, spref, dpref string, depth0 int) (zx.Dir, error) {
+	name, err := zx.UseAbsPath(name)
+	if err != nil {
+		return nil, err
+	}
+	depth := depth0
+	switch name {
+	case "/":
+		depth++
+	case "/Ctl":
+	default:
+		return nil, nil
+	}
+	x, err := pred.New(fpred)
+	if err != nil {
+		return nil, err
+	}
+	d := t.ctlDir()
+	if v, _, _ := x.EvalAt(d, depth); !v {
+		return nil, nil
+	}
+	if spref != dpref {
+		d["path"] = fpath.Join(dpref, zx.Suffix(d["path"], spref))
+	}
+	return d, nil
+}
+
+func (t *Tree) Find(name, fpred, spref, dpref string, depth0 int) <-chan zx.Dir {
+	cd, err := t.findCtl(name, fpred, spref, dpref, depth0)
+	if err != nil || isCtl(name) {
+		c := make(chan zx.Dir, 1)
+		if cd != nil {
+			c <- cd
+		}
+		close(c, err)
+		return c
+	}
+	if cd == nil {
+		return t.NS.Find(name, fpred, spref, dpref, depth0)
+	}
+	c := make(chan zx.Dir)
+	go func() {
+		rc := t.NS.Find(name, fpred, spref, dpref, depth0)
+		n := 0
+		for d := range rc {
+			if d["path"] == cd["path"] {
+				continue
+			}
+			if ok := c <- d; !ok {
+				close(rc, cerror(c))
+				return
+			}
+			// the ctl goes right after the root
+			if n++; n == 1 {
+				if ok := c <- cd; !ok {
+					close(rc, cerror(c))
+					return
+				}
+			}
+		}
+		close(c, cerror(rc))
+	}()
+	return c
+}
+
+func (t *Tree) FindGet(name, fpred, spref, dpref string, depth0 int) <-chan face{} {
+	cd, err := t.findCtl(name, fpred, spref, dpref, depth0)
+	if err != nil || isCtl(name) {
+		c := make(chan face{}, 1)
+		if cd != nil {
+			c <- cd
+		}
+		close(c, err)
+		return c
+	}
+	if cd == nil {
+		return t.NS.FindGet(name, fpred, spref, dpref, depth0)
+	}
+	c := make(chan face{})
+	go func() {
+		rc := t.NS.FindGet(name, fpred, spref, dpref, depth0)
+		n := 0
+		skip := false
+		for x := range rc {
+			d, ok := x.(zx.Dir)
+			if ok {
+				skip = d["path"] == cd["path"]
+			}
+			if skip {
+				continue // their ctl and its data
+			}
+			if ok := c <- x; !ok {
+				close(rc, cerror(c))
+				return
+			}
+			if d != nil {
+				if n++; n == 1 {
+					if ok := c <- cd; !ok {
+						close(rc, cerror(c))
+						return
+					}
+				}
+			}
+		}
+		close(c, cerror(rc))
+	}()
+	return c
+}
@@ -0,0 +1,282 @@
+package sre
+
+/*
+	A lazy DFA used to see quickly if there's a match at all,
+	before running the NFA to find the match and its groups.
+
+	Each DFA state is the set of NFA instructions reached (before
+	following the empty transitions) and if the previous rune
+	was a \n (or we are at the start of text), which is needed
+	to evaluate ^. States are built as the text asks for them and the
+	transitions are kept in a cache.
+	Because the search is unanchored, the entry of the NFA is always
+	added to the state set when following the empty transitions.
+
+	When the state set is empty, no match may start before the current
+	position, so the NFA may start to run there.
+	If the expression starts with a literal string, the DFA skips
+	the text until the literal is found while the state set is empty.
+*/
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"unicode"
+)
+
+const maxDStates = 4096 // don't use the DFA if it needs more states
+
+struct dstate {
+	pcs     []pinst // NFA instructions
+	nl      bool    // previous rune was \n or at start of text
+	isinit  bool    // no instructions, just the NFA entry
+	m, meol bool    // there's a match here (if the next rune is not/is an eol)
+	ascii   [128]*dstate
+	next    map[rune]*dstate
+}
+
+struct dfa {
+	sync.Mutex
+	states map[string]*dstate
+	busy   bool   // used by a match, others use the NFA
+	failed bool   // too many states, don't use it
+	prefix []rune // literal prefix for matches
+}
+
+type byPc []pinst
+
+func (b byPc) Len() int           { return len(b) }
+func (b byPc) Less(i, j int) bool { return b[i] < b[j] }
+func (b byPc) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+func newDfa(prg *ReProg) *dfa {
+	d := &dfa{states: map[string]*dstate{}}
+	for pc := prg.entry; pc != 0; {
+		x := prg.code[pc]
+		switch {
+		case x.op == tLPAREN || x.op == tRPAREN || x.op == tNOP:
+		case x.op < tOPERATOR:
+			d.prefix = append(d.prefix, x.op)
+		default:
+			return d
+		}
+		pc = x.left
+	}
+	return d
+}
+
+/*
+	Follow the empty transitions from pcs and return the instructions
+	consuming runes and if the end of the expression was reached.
+	bol and eol tell if ^ and $ match.
+*/
+func (prg *ReProg) closure(pcs []pinst, bol, eol bool) (cons []pinst, matched bool) {
+	seen := make([]bool, len(prg.code))
+	var walk func(pinst)
+	walk = func(i pinst) {
+		if i == 0 || seen[i] {
+			return
+		}
+		seen[i] = true
+		x := prg.code[i]
+		switch x.op {
+		case tLPAREN, tRPAREN, tNOP:
+			walk(x.left)
+		case tOR:
+			walk(x.right)
+			walk(x.left)
+		case tBOL:
+			if bol {
+				walk(x.left)
+			}
+		case tEOL:
+			if eol {
+				walk(x.left)
+			}
+		case tEND:
+			matched = true
+		default:
+			cons = append(cons, i)
+		}
+	}
+	for _, pc := range pcs {
+		walk(pc)
+	}
+	return cons, matched
+}
+
+/*
+	See if the instruction consumes c.
+*/
+func (prg *ReProg) consumes(x *inst, c rune) bool {
+	switch x.op {
+	case tANY:
+		return c != '\n' && c != 0
+	case tWORD:
+		return unicode.IsLetter(c) || unicode.IsNumber(c)
+	case tBLANK:
+		return unicode.IsSpace(c) && c != '\n'
+	case tCCLASS:
+		return prg.inClass(x.class, c)
+	case tNCCLASS:
+		return !prg.inClass(x.class, c)
+	}
+	return x.op == c
+}
+
+func isEol(c rune) bool {
+	return c == '\n' || c == 0
+}
+
+/*
+	Return the state for the given instructions, perhaps a new one,
+	or nil if there are too many states.
+*/
+func (d *dfa) state(prg *ReProg, pcs []pinst, nl bool) *dstate {
+	sort.Sort(byPc(pcs))
+	var key []byte
+	n := 0
+	for i, pc := range pcs {
+		if i > 0 && pc == pcs[i-1] {
+			continue
+		}
+		pcs[n] = pc
+		n++
+		key = strconv.AppendInt(key, int64(pc), 10)
+		key = append(key, ' ')
+	}
+	pcs = pcs[:n]
+	if nl {
+		key = append(key, '^')
+	}
+	if s, ok := d.states[string(key)]; ok {
+		return s
+	}
+	if len(d.states) >= maxDStates {
+		return nil
+	}
+	s := &dstate{pcs: pcs, nl: nl, isinit: len(pcs) == 0}
+	_, s.m = prg.closure(s.entry(prg), nl, false)
+	_, s.meol = prg.closure(s.entry(prg), nl, true)
+	d.states[string(key)] = s
+	return s
+}
+
+func (d *dfa) initial(prg *ReProg, nl bool) *dstate {
+	return d.state(prg, nil, nl)
+}
+
+// The state instructions plus the NFA entry
+func (s *dstate) entry(prg *ReProg) []pinst {
+	pcs := make([]pinst, len(s.pcs), len(s.pcs)+1)
+	copy(pcs, s.pcs)
+	return append(pcs, prg.entry)
+}
+
+/*
+	Return the state after consuming c at s, or nil if there
+	are too many states.
+*/
+func (d *dfa) step(prg *ReProg, s *dstate, c rune) *dstate {
+	if c >= 0 && c < 128 {
+		if n := s.ascii[c]; n != nil {
+			return n
+		}
+	} else if n := s.next[c]; n != nil {
+		return n
+	}
+	cons, _ := prg.closure(s.entry(prg), s.nl, isEol(c))
+	var pcs []pinst
+	for _, pc := range cons {
+		if x := prg.code[pc]; prg.consumes(x, c) && x.left != 0 {
+			pcs = append(pcs, x.left)
+		}
+	}
+	n := d.state(prg, pcs, c == '\n')
+	if n == nil {
+		return nil
+	}
+	if c >= 0 && c < 128 {
+		s.ascii[c] = n
+	} else {
+		if s.next == nil {
+			s.next = map[rune]*dstate{}
+		}
+		s.next[c] = n
+	}
+	return n
+}
+
+/*
+	Return the position of the next literal prefix in txt
+	at or after p, or -1 if there's none.
+*/
+func (d *dfa) skip(txt Text, p, end int) int {
+	n := len(d.prefix)
+	for ; p+n <= end; p++ {
+		i := 0
+		for ; i < n && txt.Getc(p+i) == d.prefix[i]; i++ {
+		}
+		if i == n {
+			return p
+		}
+	}
+	return -1
+}
+
+/*
+	Report if there's a match for prg in txt (at start...end), and
+	if the DFA could tell (if it's busy or has too many states it can't).
+	If there's a match, it can't start before the position returned.
+*/
+func (d *dfa) match(prg *ReProg, txt Text, start, end int) (matched bool, from int, ok bool) {
+	d.Lock()
+	if d.busy || d.failed {
+		d.Unlock()
+		return false, start, false
+	}
+	d.busy = true
+	d.Unlock()
+	defer func() {
+		d.Lock()
+		d.busy = false
+		d.Unlock()
+	}()
+	from = start
+	s := d.initial(prg, start == 0 || txt.Getc(start-1) == '\n')
+	for p := start; p < end && s != nil; p++ {
+		if s.isinit {
+			from = p
+			if len(d.prefix) > 0 {
+				np := d.skip(txt, p, end)
+				if np < 0 {
+					return false, start, true
+				}
+				if np > p {
+					p, from = np, np
+					if s = d.initial(prg, txt.Getc(p-1) == '\n'); s == nil {
+						break
+					}
+				}
+			}
+		}
+		c := txt.Getc(p)
+		if isEol(c) && s.meol || !isEol(c) && s.m {
+			return true, from, true
+		}
+		s = d.step(prg, s, c)
+	}
+	if s == nil {
+		d.Lock()
+		d.failed = true
+		d.Unlock()
+		return false, start, false
+	}
+	if s.isinit {
+		from = end
+	}
+	// at the end, ^ matches only if the text is empty.
+	_, m := prg.closure(s.entry(prg), end == 0, true)
+	return m, from, true
+}
@@ -89,6 +89,24 @@ func classMatch(cls []rune, c rune) bool {
 	return false
 }
 
+/*
+	See if c matches the character class or not, considering
+	also other cases for c when folding case.
+*/
+func (prg *ReProg) inClass(cls []rune, c rune) bool {
+	if !prg.fold {
+		return classMatch(cls, c)
+	}
+	for f := c; ; {
+		if classMatch(cls, f) {
+			return true
+		}
+		if f = unicode.SimpleFold(f); f == c {
+			return false
+		}
+	}
+}
+
 /*
 	Like Exec but for strings.
 	See Exec for more details.
@@ -137,6 +155,13 @@ func (prg *ReProg) Exec(txt Text, start int, end int) []Range {
 	if end > txtlen {
 		end = txtlen
 	}
+	if prg.dfa != nil && start <= end {
+		m, from, ok := prg.dfa.match(prg, txt, start, end)
+		if ok && !m {
+			return nil
+		}
+		start = from
+	}
 	statel := &states{}
 	nextl := &states{}
 	sel := make([]Range, prg.cursubid+1)
@@ -226,11 +251,11 @@ func (prg *ReProg) Exec(txt Text, start int, end int) []Range {
 					goto Exec
 				}
 			case tCCLASS:
-				if classMatch(x.class, c) {
+				if prg.inClass(x.class, c) {
 					nextl.add(x.left, s.sel)
 				}
 			case tNCCLASS:
-				if !classMatch(x.class, c) {
+				if !prg.inClass(x.class, c) {
 					nextl.add(x.left, s.sel)
 				}
 			case tOR:
@@ -361,11 +386,11 @@ func (prg *ReProg) execBack(txt Text, start int, end int) []Range {
 					goto Exec
 				}
 			case tCCLASS:
-				if classMatch(x.class, c) {
+				if prg.inClass(x.class, c) {
 					nextl.add(x.left, s.sel)
 				}
 			case tNCCLASS:
-				if !classMatch(x.class, c) {
+				if !prg.inClass(x.class, c) {
 					nextl.add(x.left, s.sel)
 				}
 			case tOR:
@@ -0,0 +1,160 @@
+package sre
+
+import (
+	"bytes"
+	"strconv"
+)
+
+/*
+	A match of a compiled sre in a text.
+	Entry 0 in Rg and Strs is for the whole expression,
+	further entries are for its groups (\1, \2, ...), named or not.
+	Groups not matched have empty strings.
+*/
+struct Matched {
+	Rg    []Range
+	Strs  []string
+	names []string
+}
+
+func newMatched(prg *ReProg, txt Text, rg []Range) *Matched {
+	if len(rg) == 0 {
+		return nil
+	}
+	n := txt.Len()
+	m := &Matched{Rg: rg, names: prg.Names()}
+	m.Strs = make([]string, len(rg))
+	for i, r := range rg {
+		var rs []rune
+		for p := safe(r.P0, n); p < safe(r.P1, n); p++ {
+			rs = append(rs, txt.Getc(p))
+		}
+		m.Strs[i] = string(rs)
+	}
+	return m
+}
+
+/*
+	Like Exec, but returns the match with its groups, or nil if
+	there is no match.
+*/
+func (prg *ReProg) FindText(txt Text, start, end int) *Matched {
+	return newMatched(prg, txt, prg.Exec(txt, start, end))
+}
+
+// Like FindText, for []rune.
+func (prg *ReProg) FindRunes(s []rune, start, end int) *Matched {
+	return prg.FindText(runestr(s), start, end)
+}
+
+// Like FindText, to search the whole string.
+func (prg *ReProg) Find(s string) *Matched {
+	rs := []rune(s)
+	return prg.FindText(runestr(rs), 0, len(rs))
+}
+
+// Like Find, but compiles the sre first.
+func Find(sre, text string) (*Matched, error) {
+	p, err := CompileStr(sre, Fwd)
+	if err != nil {
+		return nil, err
+	}
+	return p.Find(text), nil
+}
+
+// Return the number of groups, not counting the whole match.
+func (m *Matched) NSub() int {
+	return len(m.Strs) - 1
+}
+
+// Return the string for the i-th group (0 is the whole match),
+// or "" if there's no such group.
+func (m *Matched) Group(i int) string {
+	if i < 0 || i >= len(m.Strs) {
+		return ""
+	}
+	return m.Strs[i]
+}
+
+// Return the string for the named group and if there's such group.
+func (m *Matched) Named(name string) (string, bool) {
+	i := m.index(name)
+	if i < 0 {
+		return "", false
+	}
+	return m.Strs[i], true
+}
+
+// Return the range for the named group and if there's such group.
+func (m *Matched) NamedRange(name string) (Range, bool) {
+	i := m.index(name)
+	if i < 0 {
+		return Range{}, false
+	}
+	return m.Rg[i], true
+}
+
+// Return a map from group names to the strings matched.
+func (m *Matched) Map() map[string]string {
+	nm := map[string]string{}
+	for i, n := range m.names {
+		if n != "" && i < len(m.Strs) {
+			nm[n] = m.Strs[i]
+		}
+	}
+	return nm
+}
+
+func (m *Matched) index(name string) int {
+	for i, n := range m.names {
+		if n != "" && n == name && i < len(m.Strs) {
+			return i
+		}
+	}
+	return -1
+}
+
+/*
+	Replace in the given string \n (\0 to \9) with the corresponding
+	group and \<name> with the named group (or numbered one if
+	name is a number).
+	\\ stands for \ and other escaped runes are kept as they are.
+*/
+func (m *Matched) Repl(s string) string {
+	var out bytes.Buffer
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		if r != '\\' || i == len(rs)-1 {
+			out.WriteRune(r)
+			continue
+		}
+		i++
+		switch r = rs[i]; {
+		case r == '\\':
+			out.WriteRune(r)
+		case r >= '0' && r <= '9':
+			out.WriteString(m.Group(int(r - '0')))
+		case r == '<':
+			n := 0
+			for n = i + 1; n < len(rs) && rs[n] != '>'; n++ {
+			}
+			if n == len(rs) {
+				out.WriteString(`\<`)
+				continue
+			}
+			name := string(rs[i+1 : n])
+			i = n
+			if nb, err := strconv.Atoi(name); err == nil {
+				out.WriteString(m.Group(nb))
+			} else {
+				v, _ := m.Named(name)
+				out.WriteString(v)
+			}
+		default:
+			out.WriteRune('\\')
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
@@ -4,6 +4,10 @@
 	Besides those understood by Sam, these ones
 	have \w and \s to match unicode alpha and space runes
 	(can be also used within character classes).
+	Groups may be named using (?P<name>...); named groups
+	are still numbered like the others.
+	A leading (?i) in the expression makes it match ignoring case,
+	like the Fold flag for Compile.
 	Matching does not wrap if no further matches are found.
 
 */
@@ -22,6 +26,10 @@ package sre
 	operator precedence directly to the NFA (with an
 	implicit postfix operator notation.
 
+	For forward searches, a DFA built lazily out of the NFA
+	is used to see if there's a match at all; the NFA runs only
+	when there is one, to find the match and its subexpressions.
+
 	The excelent description made by Russ Cox at
 	http://swtch.com/~rsc/regexp/regexp[12].html
 	is probably the best thing to read before reading the code
@@ -32,6 +40,7 @@ import (
 	"bytes"
 	"fmt"
 	"runtime"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -118,8 +127,12 @@ struct ReProg {
 	expr       []rune  // what's left to be compiled
 	err        error   // during parsing
 	lastwasand bool
-	entry      pinst // entry point to execute the program
-	back       bool  // compiled to search backward
+	entry      pinst    // entry point to execute the program
+	back       bool     // compiled to search backward
+	fold       bool     // case insensitive
+	lit        bool     // the expression is a literal string
+	names      []string // group names by subid, "" if unnamed
+	dfa        *dfa     // to check for matches, for forward search
 }
 
 /*
@@ -258,6 +271,7 @@ func (prg *ReProg) operator(op rune, val []rune) {
 	case tLPAREN:
 		prg.nparen++
 		prg.cursubid++
+		prg.setName(prg.cursubid, string(val))
 		if prg.lastwasand {
 			prg.operator(tCAT, nil)
 		}
@@ -270,6 +284,45 @@ func (prg *ReProg) operator(op rune, val []rune) {
 		op == tSTAR || op == tQUEST || op == tPLUS || op == tRPAREN
 }
 
+/*
+	Record the name for the given group, if any.
+*/
+func (prg *ReProg) setName(subid int, name string) {
+	for len(prg.names) <= subid {
+		prg.names = append(prg.names, "")
+	}
+	if name == "" {
+		return
+	}
+	if prg.SubIndex(name) >= 0 {
+		panic(fmt.Sprintf("duplicate group name '%s'", name))
+	}
+	prg.names[subid] = name
+}
+
+// Return the number of groups in the expression.
+func (prg *ReProg) NSub() int {
+	return prg.cursubid
+}
+
+// Return the names for the groups, indexed by group number
+// (0 is the whole expression); unnamed groups have "" as their name.
+func (prg *ReProg) Names() []string {
+	names := make([]string, prg.cursubid+1)
+	copy(names, prg.names)
+	return names
+}
+
+// Return the group number for the named group, or -1 if there's none.
+func (prg *ReProg) SubIndex(name string) int {
+	for i, n := range prg.names {
+		if n != "" && n == name {
+			return i
+		}
+	}
+	return -1
+}
+
 /*
 	Compile an operand (val is the class for '[]' tokens)
 */
@@ -277,6 +330,11 @@ func (prg *ReProg) operand(op rune, val []rune) {
 	if prg.lastwasand {
 		prg.operator(tCAT, nil) // implicit cat
 	}
+	if prg.fold && op < tOPERATOR {
+		if cls := foldClass(op); len(cls) > 1 {
+			op, val = tCCLASS, cls
+		}
+	}
 	i, x := prg.emit(op)
 	if op == tCCLASS || op == tNCCLASS {
 		x.class = val
@@ -285,6 +343,17 @@ func (prg *ReProg) operand(op rune, val []rune) {
 	prg.lastwasand = true
 }
 
+/*
+	Return the rune and its other cases.
+*/
+func foldClass(c rune) []rune {
+	cls := []rune{c}
+	for f := unicode.SimpleFold(c); f != c; f = unicode.SimpleFold(f) {
+		cls = append(cls, f)
+	}
+	return cls
+}
+
 /*
 	Optimize generated code by jumping directly to the target of nops.
 */
@@ -303,9 +372,12 @@ func (prg *ReProg) eatNops() {
 type Dir int
 
 // Argument to Compile.
+// Fold and Lit may be or-ed to Fwd or Bck.
 const (
-	Fwd = iota // compile for forward search in text
-	Bck        // compile for backward search in text
+	Fwd  Dir = 0 // compile for forward search in text
+	Bck  Dir = 1 // compile for backward search in text
+	Fold Dir = 2 // ignore case (unicode aware) when matching
+	Lit  Dir = 4 // the expression is a literal string, not a regexp
 )
 
 /*
@@ -318,9 +390,15 @@ func CompileStr(re string, dir Dir) (prg *ReProg, err error) {
 
 /*
 	Compile re as a regexp to search forward or backward in text
+	(or a literal string if dir has Lit set), ignoring case if dir
+	has Fold set.
 */
 func Compile(re []rune, dir Dir) (prg *ReProg, err error) {
-	prg = &ReProg{back: dir == Bck}
+	prg = &ReProg{back: dir&Bck != 0, fold: dir&Fold != 0, lit: dir&Lit != 0}
+	if !prg.lit && len(re) >= 4 && string(re[:4]) == "(?i)" {
+		prg.fold = true
+		re = re[4:]
+	}
 	prg.expr = re
 	defer func() {
 		if s := recover(); s != nil {
@@ -351,6 +429,9 @@ func Compile(re []rune, dir Dir) (prg *ReProg, err error) {
 	nd := prg.ndstk[len(prg.ndstk)-1]
 	prg.entry = nd.first
 	prg.eatNops()
+	if !prg.back {
+		prg.dfa = newDfa(prg)
+	}
 	return prg, nil
 }
 
@@ -393,8 +474,22 @@ func (prg *ReProg) Match(text string) []string {
 	return rs
 }
 
+// Return a regexp that matches the literal string s.
+func QuoteMeta(s string) string {
+	var out bytes.Buffer
+	for _, r := range s {
+		switch r {
+		case '\\', '.', '+', '*', '?', '(', ')', '|', '[', ']', '^', '$':
+			out.WriteRune('\\')
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
+
 // Replace in the given string \n with the corresponding entry
 // in matches. Only \0 to \9 accepted.
+// See Matched.Repl to use also named groups.
 func Repl(matches []string, s string) string {
 	var out bytes.Buffer
 	esc := false
@@ -509,14 +604,46 @@ func (prg *ReProg) scanClass() (class []rune, neg bool) {
 }
 
 /*
-	return the next token and the class value for the token (if any),
+	After '(' has been seen and ?P follows, scan the
+	<name> for the group and return it.
+*/
+func (prg *ReProg) scanName() []rune {
+	prg.getc()
+	prg.getc()
+	if prg.getc() != '<' {
+		panic("malformed group name")
+	}
+	var name []rune
+	for c := prg.getc(); c != '>'; c = prg.getc() {
+		if c == tEND || !isNameRune(c) {
+			panic("malformed group name")
+		}
+		name = append(name, c)
+	}
+	if len(name) == 0 {
+		panic("empty group name")
+	}
+	return name
+}
+
+func isNameRune(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsNumber(c)
+}
+
+/*
+	return the next token and the value for the token (if any),
 	or tEND if none.
+	The value is the class for '[]' tokens and the group name
+	for '(' tokens.
 */
 func (prg *ReProg) lex() (rune, []rune) {
 	if len(prg.expr) == 0 {
 		return tEND, nil
 	}
 	c := prg.getc()
+	if prg.lit {
+		return c, nil
+	}
 	switch c {
 	case '\\':
 		switch n := prg.getc(); n {
@@ -543,6 +670,9 @@ func (prg *ReProg) lex() (rune, []rune) {
 		c = tANY
 	case '(':
 		c = tLPAREN
+		if len(prg.expr) > 1 && prg.expr[0] == '?' && prg.expr[1] == 'P' {
+			return c, prg.scanName()
+		}
 	case ')':
 		c = tRPAREN
 	case '^':
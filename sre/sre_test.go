@@ -1,6 +1,7 @@
 package sre
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 )
@@ -248,3 +249,154 @@ func TestExecBack(t *testing.T) {
 		}
 	}
 }
+
+func TestNamed(t *testing.T) {
+	p, err := CompileStr(`(?P<key>[a-z]+)=((?P<val>[0-9]+)|x)`, Fwd)
+	if err != nil {
+		t.Fatalf("compile: %s", err)
+	}
+	if p.NSub() != 3 || p.SubIndex("key") != 1 || p.SubIndex("val") != 3 {
+		t.Fatalf("bad groups %v", p.Names())
+	}
+	m := p.Find("-- ab=12 --")
+	if m == nil {
+		t.Fatalf("no match")
+	}
+	t.Logf("match %v %v", m.Rg, m.Strs)
+	if m.Group(0) != "ab=12" || m.Group(2) != "12" {
+		t.Fatalf("bad match")
+	}
+	if v, ok := m.Named("val"); !ok || v != "12" {
+		t.Fatalf("bad named match")
+	}
+	if _, ok := m.Named("none"); ok {
+		t.Fatalf("bad named match")
+	}
+	if r, ok := m.NamedRange("key"); !ok || r.P0 != 3 || r.P1 != 5 {
+		t.Fatalf("bad named range")
+	}
+	if s := m.Repl(`\<val>:\1:\<1>:\\:\n`); s != `12:ab:ab:\:\n` {
+		t.Fatalf("bad repl %q", s)
+	}
+	if p.Find("AB") != nil {
+		t.Fatalf("bad match")
+	}
+	bad := []string{`(?P<a>x)(?P<a>y)`, `(?P<>x)`, `(?Px)`, `(?P<a x)`}
+	for _, e := range bad {
+		if _, err := CompileStr(e, Fwd); err == nil {
+			t.Fatalf("could compile %s", e)
+		}
+	}
+}
+
+func TestFoldLit(t *testing.T) {
+	txt := "a Straße, STRASSE; x.*y X.*Y"
+	cases := []struct {
+		re  string
+		dir Dir
+		out string
+	}{
+		{`straße`, Fwd, ``},
+		{`straße`, Fwd | Fold, `Straße`},
+		{`(?i)straße`, Fwd, `Straße`},
+		{`(?i)[r-t]+asse`, Fwd, `STRASSE`},
+		{`(?i)s[^s]+SSE`, Fwd, `STRASSE`},
+		{`x.*y`, Lit, `x.*y`},
+		{`x.*y`, Lit | Fold | Bck, `X.*Y`},
+		{`(?i)x`, Lit, ``},
+		{`.*Y`, Lit | Bck, `.*Y`},
+	}
+	for _, c := range cases {
+		p, err := CompileStr(c.re, c.dir)
+		if err != nil {
+			t.Fatalf("%s: compile: %s", c.re, err)
+		}
+		rs := []rune(txt)
+		start := 0
+		if c.dir&Bck != 0 {
+			start = len(rs)
+		}
+		rg := p.ExecRunes(rs, start, len(rs))
+		out := ""
+		if len(rg) > 0 {
+			out = string(rs[rg[0].P0:rg[0].P1])
+		}
+		t.Logf("%s %d: %q", c.re, c.dir, out)
+		if out != c.out {
+			t.Fatalf("%s: got %q", c.re, out)
+		}
+	}
+}
+
+func TestDfa(t *testing.T) {
+	exprs := append(xexprs, `install`, `ins(tall)?\.`, `(?i)INSTALL`, `^$`,
+		`\.$`, `^[a-z]+ `, `x?x?x?y`, `(a|b)*c`, `n[^a-z]`, `swtch|plan9`,
+		`(ta|in|s)*ll`, `r.*n|e`)
+	rs := []rune(xtext)
+	for _, e := range exprs {
+		p, err := CompileStr(e, Fwd)
+		if err != nil {
+			t.Fatalf("%s: compile: %s", e, err)
+		}
+		np, _ := CompileStr(e, Fwd)
+		np.dfa = nil
+		for st := 0; st <= len(rs); st++ {
+			for _, end := range []int{st, st + 1, st + 10, len(rs)} {
+				if end > len(rs) {
+					continue
+				}
+				o := fmt.Sprint(p.ExecRunes(rs, st, end))
+				no := fmt.Sprint(np.ExecRunes(rs, st, end))
+				if o != no {
+					t.Fatalf("%s at %d:%d: dfa %s nfa %s", e, st, end, o, no)
+				}
+			}
+		}
+		if p.dfa.failed {
+			t.Fatalf("%s: dfa failed", e)
+		}
+		t.Logf("%s: prefix %q %d states", e, string(p.dfa.prefix), len(p.dfa.states))
+	}
+}
+
+var btxt []rune
+
+// A few MBs of text with a match only at the end.
+func benchText() []rune {
+	if btxt == nil {
+		var buf bytes.Buffer
+		for i := 0; buf.Len() < 4*1024*1024; i++ {
+			fmt.Fprintf(&buf, "the quick brown fox %d jumps over the lazy dog\n", i)
+		}
+		buf.WriteString("a needle in the haystack, at 42 needles\n")
+		btxt = []rune(buf.String())
+	}
+	return btxt
+}
+
+func benchExec(b *testing.B, re string, dfa bool) {
+	rs := benchText()
+	p, err := CompileStr(re, Fwd)
+	if err != nil {
+		b.Fatalf("compile: %s", err)
+	}
+	if !dfa {
+		p.dfa = nil
+	}
+	b.SetBytes(int64(len(rs)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if rg := p.ExecRunes(rs, 0, len(rs)); len(rg) == 0 {
+			b.Fatalf("no match")
+		}
+	}
+}
+
+func BenchmarkLitNFA(b *testing.B)   { benchExec(b, `needle`, false) }
+func BenchmarkLitDFA(b *testing.B)   { benchExec(b, `needle`, true) }
+func BenchmarkClassNFA(b *testing.B) { benchExec(b, `[0-9]+ needles`, false) }
+func BenchmarkClassDFA(b *testing.B) { benchExec(b, `[0-9]+ needles`, true) }
+func BenchmarkAltNFA(b *testing.B)   { benchExec(b, `(hay|straw)stack`, false) }
+func BenchmarkAltDFA(b *testing.B)   { benchExec(b, `(hay|straw)stack`, true) }
+func BenchmarkFoldNFA(b *testing.B)  { benchExec(b, `(?i)HAYSTACK`, false) }
+func BenchmarkFoldDFA(b *testing.B)  { benchExec(b, `(?i)HAYSTACK`, true) }
@@ -0,0 +1,168 @@
+package txt
+
+import (
+	"bytes"
+	"clive/ch"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+/*
+	Undo/redo history for a text, so it can be kept
+	and restored later on for the same text.
+*/
+struct History {
+	Edits []*Edit // edits, including those undone
+	N     int     // number of edits applied (the rest are for redo)
+	Len   int     // length of the text
+	Sum   string  // checksum of the text
+}
+
+// Error returned when a history does not belong to a text
+var ErrHistory = errors.New("history does not match the text")
+
+// checksum for the text, locked
+func (t *Text) sum() string {
+	h := sha1.New()
+	for _, d := range t.chunks() {
+		h.Write([]byte(string(d)))
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+/*
+	Return a copy of the undo/redo history for the text,
+	or nil if it does not support undo and redo.
+*/
+func (t *Text) History() *History {
+	t.Lock()
+	defer t.Unlock()
+	if t.edits == nil {
+		return nil
+	}
+	h := &History{N: t.nedits, Len: t.sz, Sum: t.sum()}
+	for _, e := range t.edits {
+		ne := *e
+		ne.Data = make([]rune, len(e.Data))
+		copy(ne.Data, e.Data)
+		h.Edits = append(h.Edits, &ne)
+	}
+	return h
+}
+
+/*
+	Replace the undo/redo history of the text with the one given,
+	which must have been taken from a text with the same contents.
+	Otherwise ErrHistory is returned and the text is left as it was.
+*/
+func (t *Text) SetHistory(h *History) error {
+	t.Lock()
+	defer t.Unlock()
+	if t.edits == nil {
+		return errors.New("text has no undo")
+	}
+	if h == nil || h.N < 0 || h.N > len(h.Edits) || h.Len != t.sz || h.Sum != t.sum() {
+		return ErrHistory
+	}
+	t.edits = make([]*Edit, 0, len(h.Edits)+128)
+	for _, e := range h.Edits {
+		ne := *e
+		ne.Data = make([]rune, len(e.Data))
+		copy(ne.Data, e.Data)
+		t.edits = append(t.edits, &ne)
+	}
+	t.nedits = h.N
+	t.contd = false
+	return nil
+}
+
+func (e *Edit) WriteTo(w io.Writer) (n int64, err error) {
+	var hdr [6]byte
+	hdr[0] = byte(e.Op)
+	if e.Contd {
+		hdr[1] = 1
+	}
+	binary.LittleEndian.PutUint32(hdr[2:], uint32(e.Off))
+	if _, err := w.Write(hdr[:]); err != nil {
+		return 0, err
+	}
+	n, err = ch.WriteStringTo(w, string(e.Data))
+	return n + 6, err
+}
+
+func UnpackEdit(b []byte) ([]byte, *Edit, error) {
+	if len(b) < 6 {
+		return b, nil, ch.ErrTooSmall
+	}
+	e := &Edit{Op: Tedit(b[0]), Contd: b[1] != 0}
+	if e.Op != Eins && e.Op != Edel {
+		return b, nil, errors.New("bad edit type")
+	}
+	e.Off = int(binary.LittleEndian.Uint32(b[2:]))
+	b, s, err := ch.UnpackString(b[6:])
+	if err != nil {
+		return b, nil, err
+	}
+	e.Data = []rune(s)
+	return b, e, nil
+}
+
+func (h *History) WriteTo(w io.Writer) (n int64, err error) {
+	var hdr [12]byte
+	binary.LittleEndian.PutUint32(hdr[0:], uint32(h.N))
+	binary.LittleEndian.PutUint32(hdr[4:], uint32(h.Len))
+	binary.LittleEndian.PutUint32(hdr[8:], uint32(len(h.Edits)))
+	if _, err := w.Write(hdr[:]); err != nil {
+		return 0, err
+	}
+	n = 12
+	nw, err := ch.WriteStringTo(w, h.Sum)
+	n += nw
+	if err != nil {
+		return n, err
+	}
+	for _, e := range h.Edits {
+		nw, err := e.WriteTo(w)
+		n += nw
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func UnpackHistory(b []byte) ([]byte, *History, error) {
+	if len(b) < 12 {
+		return b, nil, ch.ErrTooSmall
+	}
+	h := &History{}
+	h.N = int(binary.LittleEndian.Uint32(b[0:]))
+	h.Len = int(binary.LittleEndian.Uint32(b[4:]))
+	ne := int(binary.LittleEndian.Uint32(b[8:]))
+	if h.N > ne {
+		return b, nil, errors.New("bad history")
+	}
+	b, s, err := ch.UnpackString(b[12:])
+	if err != nil {
+		return b, nil, err
+	}
+	h.Sum = s
+	for i := 0; i < ne; i++ {
+		var e *Edit
+		if b, e, err = UnpackEdit(b); err != nil {
+			return b, nil, err
+		}
+		h.Edits = append(h.Edits, e)
+	}
+	return b, h, nil
+}
+
+// Return the history packed as UnpackHistory expects it.
+func (h *History) Bytes() []byte {
+	var buf bytes.Buffer
+	h.WriteTo(&buf)
+	return buf.Bytes()
+}
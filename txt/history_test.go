@@ -0,0 +1,53 @@
+package txt
+
+import (
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	debug = testing.Verbose()
+	tx := NewEditing([]rune("abc\n"))
+	tx.Del(0, 1)
+	tx.Ins([]rune("x"), 0)
+	tx.ContdEdit()
+	tx.Ins([]rune("y\n"), 4)
+	tx.Del(0, 2)
+	tx.Undo()
+	printf("=>\n%s\n", tx.Sprint())
+	h := tx.History()
+	b := h.Bytes()
+	rest, nh, err := UnpackHistory(b)
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("unpack: %v", err)
+	}
+	if nh.N != 4 || len(nh.Edits) != 5 || nh.Len != h.Len || nh.Sum != h.Sum {
+		t.Fatalf("bad history")
+	}
+	for i, e := range nh.Edits {
+		if e.String() != h.Edits[i].String() {
+			t.Fatalf("bad edit %s", e)
+		}
+	}
+
+	nt := NewEditing([]rune("xbc\n"))
+	if err := nt.SetHistory(nh); err != ErrHistory {
+		t.Fatalf("history set for a different text")
+	}
+	nt = NewEditingRope([]rune("xbc\ny\n"))
+	if err := nt.SetHistory(nh); err != nil {
+		t.Fatalf("set: %s", err)
+	}
+	printf("=>\n%s\n", nt.Sprint())
+	if e := nt.Redo(); e == nil || nt.String() != "c\ny\n" {
+		t.Fatalf("bad redo")
+	}
+	nt.Undo()
+	for e := nt.Undo(); e != nil && e.Contd; e = nt.Undo() {
+	}
+	if s := nt.String(); s != "bc\n" {
+		t.Fatalf("bad undo: %q", s)
+	}
+	if _, _, err := UnpackHistory(b[:len(b)-1]); err == nil {
+		t.Fatalf("unpacked truncated history")
+	}
+}
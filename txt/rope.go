@@ -0,0 +1,326 @@
+package txt
+
+/*
+	Large texts may be kept in a rope instead of a series of
+	rune slices: a balanced (AVL) tree with the runes at the leaves
+	and the number of runes and new lines kept at each node.
+	Inserts, deletes, Getc, and line/offset conversions are logarithmic.
+
+	Leaves are never changed once built; splitting a leaf or
+	merging two small ones makes new leaves, and the tree is
+	rebuilt along the path changed.
+*/
+
+const maxLeaf = 512 // max runes in a leaf, but for huge inserts
+
+struct rnode {
+	left, right *rnode
+	rs          []rune // runes for leaves
+	n, nl, h    int    // # of runes, new lines, and height
+}
+
+struct rope {
+	root *rnode
+	leaf *rnode // last leaf used by getc
+	loff int    // offset for leaf
+}
+
+func height(n *rnode) int {
+	if n == nil {
+		return 0
+	}
+	return n.h
+}
+
+func newLeaf(rs []rune) *rnode {
+	if len(rs) == 0 {
+		return nil
+	}
+	n := &rnode{rs: rs, n: len(rs), h: 1}
+	for _, r := range rs {
+		if r == '\n' {
+			n.nl++
+		}
+	}
+	return n
+}
+
+func mknode(l, r *rnode) *rnode {
+	if l == nil {
+		return r
+	}
+	if r == nil {
+		return l
+	}
+	h := l.h
+	if r.h > h {
+		h = r.h
+	}
+	return &rnode{left: l, right: r, n: l.n + r.n, nl: l.nl + r.nl, h: h + 1}
+}
+
+// Build a balanced tree for rs
+func build(rs []rune) *rnode {
+	if len(rs) <= maxLeaf {
+		return newLeaf(rs)
+	}
+	nleaves := (len(rs) + maxLeaf - 1) / maxLeaf
+	mid := (nleaves / 2) * maxLeaf
+	return mknode(build(rs[:mid:mid]), build(rs[mid:]))
+}
+
+func rotl(n *rnode) *rnode {
+	r := n.right
+	return mknode(mknode(n.left, r.left), r.right)
+}
+
+func rotr(n *rnode) *rnode {
+	l := n.left
+	return mknode(l.left, mknode(l.right, n.right))
+}
+
+// Restore the AVL invariant at n, if its children are balanced.
+func balance(n *rnode) *rnode {
+	if n == nil || n.h == 1 {
+		return n
+	}
+	lh, rh := height(n.left), height(n.right)
+	switch {
+	case lh > rh+1:
+		l := n.left
+		if height(l.left) < height(l.right) {
+			n = mknode(rotl(l), n.right)
+		}
+		return rotr(n)
+	case rh > lh+1:
+		r := n.right
+		if height(r.right) < height(r.left) {
+			n = mknode(n.left, rotr(r))
+		}
+		return rotl(n)
+	}
+	return n
+}
+
+// Return the tree for l followed by r.
+func join(l, r *rnode) *rnode {
+	switch {
+	case l == nil:
+		return r
+	case r == nil:
+		return l
+	case l.h == 1 && r.h == 1 && l.n+r.n <= maxLeaf:
+		rs := make([]rune, 0, l.n+r.n)
+		rs = append(rs, l.rs...)
+		return newLeaf(append(rs, r.rs...))
+	case l.h > r.h+1:
+		return balance(mknode(l.left, join(l.right, r)))
+	case r.h > l.h+1:
+		return balance(mknode(join(l, r.left), r.right))
+	}
+	return mknode(l, r)
+}
+
+// Return the trees for the runes before and after off.
+func split(n *rnode, off int) (*rnode, *rnode) {
+	switch {
+	case n == nil:
+		return nil, nil
+	case off <= 0:
+		return nil, n
+	case off >= n.n:
+		return n, nil
+	case n.h == 1:
+		return newLeaf(n.rs[:off:off]), newLeaf(n.rs[off:])
+	case off < n.left.n:
+		l, r := split(n.left, off)
+		return l, join(r, n.right)
+	default:
+		l, r := split(n.right, off-n.left.n)
+		return join(n.left, l), r
+	}
+}
+
+func (n *rnode) runes(rs []rune) []rune {
+	if n == nil {
+		return rs
+	}
+	if n.h == 1 {
+		return append(rs, n.rs...)
+	}
+	return n.right.runes(n.left.runes(rs))
+}
+
+func newRope(rs []rune) *rope {
+	return &rope{root: build(rs)}
+}
+
+func (r *rope) len() int {
+	if r.root == nil {
+		return 0
+	}
+	return r.root.n
+}
+
+// Insert data at off (which must be within the text)
+func (r *rope) ins(data []rune, off int) {
+	r.leaf = nil
+	nd := make([]rune, len(data))
+	copy(nd, data)
+	left, right := split(r.root, off)
+	r.root = join(join(left, build(nd)), right)
+}
+
+// Delete n runes at off and return them.
+func (r *rope) del(off, n int) []rune {
+	r.leaf = nil
+	left, right := split(r.root, off)
+	mid, right := split(right, n)
+	r.root = join(left, right)
+	return mid.runes(make([]rune, 0, 64))
+}
+
+func (r *rope) getc(off int) rune {
+	if l := r.leaf; l != nil && off >= r.loff && off < r.loff+l.n {
+		return l.rs[off-r.loff]
+	}
+	n := r.root
+	if n == nil || off < 0 || off >= n.n {
+		return 0
+	}
+	loff := 0
+	for n.h > 1 {
+		if off-loff < n.left.n {
+			n = n.left
+		} else {
+			loff += n.left.n
+			n = n.right
+		}
+	}
+	r.leaf, r.loff = n, loff
+	return n.rs[off-loff]
+}
+
+// Call fn for the slices holding n runes at off, until it returns false.
+func (r *rope) get(off, cnt int, fn func([]rune) bool) {
+	var walk func(n *rnode, off int) bool
+	walk = func(n *rnode, off int) bool {
+		switch {
+		case n == nil || cnt <= 0:
+			return false
+		case off >= n.n:
+			return true
+		case n.h == 1:
+			rs := n.rs[off:]
+			if len(rs) > cnt {
+				rs = rs[:cnt]
+			}
+			cnt -= len(rs)
+			return fn(rs)
+		case off >= n.left.n:
+			return walk(n.right, off-n.left.n)
+		}
+		return walk(n.left, off) && walk(n.right, 0)
+	}
+	walk(r.root, off)
+}
+
+// Call fn for each leaf
+func (r *rope) leaves(fn func([]rune)) {
+	var walk func(n *rnode)
+	walk = func(n *rnode) {
+		if n == nil {
+			return
+		}
+		if n.h == 1 {
+			fn(n.rs)
+			return
+		}
+		walk(n.left)
+		walk(n.right)
+	}
+	walk(r.root)
+}
+
+// Return the number of new lines before off.
+func (r *rope) nlines(off int) int {
+	nl := 0
+	for n := r.root; n != nil && off > 0; {
+		if off >= n.n {
+			return nl + n.nl
+		}
+		if n.h == 1 {
+			for _, c := range n.rs[:off] {
+				if c == '\n' {
+					nl++
+				}
+			}
+			return nl
+		}
+		if off <= n.left.n {
+			n = n.left
+		} else {
+			nl += n.left.nl
+			off -= n.left.n
+			n = n.right
+		}
+	}
+	return nl
+}
+
+// Return the offset after the k-th new line, or -1 if there's no such line.
+func (r *rope) nloff(k int) int {
+	n := r.root
+	if k <= 0 || n == nil || k > n.nl {
+		return -1
+	}
+	off := 0
+	for n.h > 1 {
+		if k <= n.left.nl {
+			n = n.left
+		} else {
+			k -= n.left.nl
+			off += n.left.n
+			n = n.right
+		}
+	}
+	for i, c := range n.rs {
+		if c == '\n' {
+			if k--; k == 0 {
+				return off + i + 1
+			}
+		}
+	}
+	return -1 // can't happen
+}
+
+// Line numbers for the given range, as Text.LinesAt.
+func (r *rope) linesAt(p0, p1 int) (int, int) {
+	sz := r.len()
+	if p0 > sz {
+		p0 = sz
+	}
+	if p1 > sz {
+		p1 = sz
+	}
+	ln0, ln1 := r.nlines(p0)+1, r.nlines(p1)+1
+	if ln1 > ln0 && p1 > 0 && r.getc(p1-1) == '\n' {
+		ln1--
+	}
+	return ln0, ln1
+}
+
+// Offsets for the given line range, as Text.LinesOffs.
+func (r *rope) linesOffs(ln0, ln1 int) (int, int) {
+	off1 := r.nloff(ln1)
+	if off1 < 0 {
+		off1 = r.len()
+	}
+	off0 := off1
+	if ln0 == 1 {
+		off0 = 0
+	} else if ln0 > 1 && r.nloff(ln0) >= 0 {
+		off0 = r.nloff(ln0 - 1)
+	}
+	return off0, off1
+}
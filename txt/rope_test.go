@@ -0,0 +1,156 @@
+package txt
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestRopeSuite(t *testing.T) {
+	newText = NewEditingRope
+	defer func() { newText = NewEditing }()
+	TestLineAt(t)
+	TestLineOff(t)
+	TestInsDel(t)
+	TestMark(t)
+}
+
+func checkRope(t *testing.T, n *rnode) (int, int) {
+	if n == nil {
+		return 0, 0
+	}
+	if n.h == 1 {
+		if len(n.rs) == 0 || n.n != len(n.rs) {
+			t.Fatalf("bad leaf")
+		}
+		return n.n, 1
+	}
+	ln, lh := checkRope(t, n.left)
+	rn, rh := checkRope(t, n.right)
+	if n.n != ln+rn || n.nl != n.left.nl+n.right.nl {
+		t.Fatalf("bad counts")
+	}
+	if lh > rh+1 || rh > lh+1 || n.h != max(lh, rh)+1 {
+		t.Fatalf("not balanced")
+	}
+	return n.n, n.h
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Compare a rope text with a plain one after random edits.
+func TestRopeRand(t *testing.T) {
+	debug = testing.Verbose()
+	words := []string{"a", "bc\n", "\n", "def", strings.Repeat("long\n", 120)}
+	rnd := rand.New(rand.NewSource(1))
+	tx, rx := NewEditing(nil), NewEditingRope(nil)
+	for i := 0; i < 3000; i++ {
+		if n := tx.Len(); n > 0 && rnd.Intn(3) == 0 {
+			off := rnd.Intn(n)
+			cnt := rnd.Intn(n-off) % 700
+			ts, rs := tx.Del(off, cnt), rx.Del(off, cnt)
+			if string(ts) != string(rs) {
+				t.Fatalf("%d: del %d %d: %q vs %q", i, off, cnt, string(ts), string(rs))
+			}
+		} else {
+			w := []rune(words[rnd.Intn(len(words))])
+			off := rnd.Intn(tx.Len() + 1)
+			tx.Ins(w, off)
+			rx.Ins(w, off)
+		}
+		if tx.Len() != rx.Len() {
+			t.Fatalf("%d: bad len", i)
+		}
+		checkRope(t, rx.rope.root)
+		if i%100 != 0 {
+			continue
+		}
+		if tx.String() != rx.String() {
+			t.Fatalf("%d: texts differ", i)
+		}
+		for p := 0; p <= tx.Len(); p += 1 + rnd.Intn(50) {
+			if tx.Getc(p) != rx.Getc(p) {
+				t.Fatalf("%d: getc %d differs", i, p)
+			}
+			if tx.LineAt(p) != rx.LineAt(p) {
+				t.Fatalf("%d: line at %d differs", i, p)
+			}
+		}
+		nln := tx.LineAt(tx.Len())
+		for j := 0; j < 50; j++ {
+			ln := rnd.Intn(nln + 2)
+			ln1 := ln + rnd.Intn(5)
+			a0, a1 := tx.LinesOffs(ln, ln1)
+			b0, b1 := rx.LinesOffs(ln, ln1)
+			if a0 != b0 || a1 != b1 {
+				t.Fatalf("%d: lines %d,%d: %d,%d vs %d,%d", i, ln, ln1, a0, a1, b0, b1)
+			}
+		}
+	}
+	for e := tx.Undo(); e != nil; e = tx.Undo() {
+		rx.Undo()
+	}
+	if rx.Len() != 0 || rx.Undo() != nil {
+		t.Fatalf("bad undo")
+	}
+	printf("rope height %d\n", height(rx.rope.root))
+}
+
+func TestRopeGet(t *testing.T) {
+	s := strings.Repeat("0123456789", 200)
+	rx := NewRope([]rune(s))
+	for _, off := range []int{0, 1, 511, 512, 1000, 1999, 2000} {
+		for _, n := range []int{0, 1, 600, -1} {
+			var got []rune
+			for rs := range rx.Get(off, n) {
+				got = append(got, rs...)
+			}
+			exp := s[off:]
+			if n >= 0 && n < len(exp) {
+				exp = exp[:n]
+			}
+			if string(got) != exp {
+				t.Fatalf("get %d %d: got %d runes", off, n, len(got))
+			}
+		}
+	}
+	rx.SetRope(false)
+	if rx.IsRope() || rx.String() != s {
+		t.Fatalf("bad set rope")
+	}
+	rx.SetRope(true)
+	if !rx.IsRope() || rx.String() != s {
+		t.Fatalf("bad set rope")
+	}
+}
+
+func benchText(b *testing.B, mk func([]rune) *Text) {
+	ln := []rune(strings.Repeat("x", 70) + "\n")
+	var rs []rune
+	for len(rs) < 8*1024*1024 {
+		rs = append(rs, ln...)
+	}
+	tx := mk(rs)
+	nln := tx.LineAt(tx.Len())
+	rnd := rand.New(rand.NewSource(1))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		off := tx.LineOff(1 + rnd.Intn(nln))
+		tx.Ins([]rune("y"), off)
+		tx.Getc(off + 1)
+		tx.Del(off, 1)
+	}
+}
+
+func BenchmarkText(b *testing.B) {
+	benchText(b, New)
+}
+
+func BenchmarkRope(b *testing.B) {
+	benchText(b, NewRope)
+}
@@ -0,0 +1,191 @@
+package sam
+
+import (
+	"clive/sre"
+	"errors"
+)
+
+var (
+	errRange  = errors.New("sam: address out of range")
+	errSearch = errors.New("sam: no match for regexp")
+	errOrder  = errors.New("sam: addresses out of order")
+)
+
+// Evaluate the address starting at a, as sam does.
+func (s *Sam) address(ap *addr, a sre.Range, sign int) sre.Range {
+	for ; ap != nil; ap = ap.next {
+		switch ap.op {
+		case 'l':
+			a = s.lineaddr(ap.n, a, sign)
+		case '#':
+			a = s.charaddr(ap.n, a, sign)
+		case '.':
+			a = s.Dot
+		case '$':
+			n := s.t.Len()
+			a = sre.Range{P0: n, P1: n}
+		case '?', '/':
+			if ap.op == '?' {
+				if sign = -sign; sign == 0 {
+					sign = -1
+				}
+			}
+			if sign >= 0 {
+				a = s.nextmatch(ap.fre, a.P1, sign)
+			} else {
+				a = s.nextmatch(ap.bre, a.P0, sign)
+			}
+		case ',', ';':
+			var a1, a2 sre.Range
+			if ap.left != nil {
+				a1 = s.address(ap.left, a, 0)
+			}
+			if ap.op == ';' {
+				a = a1
+				s.Dot = a1
+			}
+			if ap.next != nil {
+				a2 = s.address(ap.next, a, 0)
+			} else {
+				n := s.t.Len()
+				a2 = sre.Range{P0: n, P1: n}
+			}
+			a = sre.Range{P0: a1.P0, P1: a2.P1}
+			if a.P1 < a.P0 {
+				panic(errOrder)
+			}
+			return a
+		case '+', '-':
+			sign = 1
+			if ap.op == '-' {
+				sign = -1
+			}
+			if n := ap.next; n == nil || n.op == '+' || n.op == '-' {
+				a = s.lineaddr(1, a, sign)
+			}
+		}
+	}
+	return a
+}
+
+func (s *Sam) charaddr(l int, a sre.Range, sign int) sre.Range {
+	switch {
+	case sign == 0:
+		a.P0, a.P1 = l, l
+	case sign < 0:
+		a.P0 -= l
+		a.P1 = a.P0
+	default:
+		a.P1 += l
+		a.P0 = a.P1
+	}
+	if a.P0 < 0 || a.P1 > s.t.Len() {
+		panic(errRange)
+	}
+	return a
+}
+
+func (s *Sam) lineaddr(l int, addr sre.Range, sign int) sre.Range {
+	t := s.t
+	nc := t.Len()
+	var a sre.Range
+	if sign >= 0 {
+		var p int
+		if l == 0 {
+			if sign == 0 || addr.P1 == 0 {
+				return a
+			}
+			a.P0 = addr.P1
+			p = addr.P1 - 1
+		} else {
+			n := 1
+			if sign != 0 && addr.P1 != 0 {
+				p = addr.P1 - 1
+				if t.Getc(p) != '\n' {
+					n = 0
+				}
+				p++
+			}
+			for n < l {
+				if p >= nc {
+					panic(errRange)
+				}
+				if t.Getc(p) == '\n' {
+					n++
+				}
+				p++
+			}
+			a.P0 = p
+		}
+		for p < nc {
+			p++
+			if t.Getc(p-1) == '\n' {
+				break
+			}
+		}
+		a.P1 = p
+		return a
+	}
+	p := addr.P0
+	if l == 0 {
+		a.P1 = addr.P0
+	} else {
+		for n := 0; n < l; {
+			if p == 0 {
+				if n++; n != l {
+					panic(errRange)
+				}
+			} else if t.Getc(p-1) != '\n' {
+				p--
+			} else if n++; n != l {
+				p--
+			}
+		}
+		a.P1 = p
+		if p > 0 {
+			p--
+		}
+	}
+	for p > 0 && t.Getc(p-1) != '\n' {
+		p--
+	}
+	a.P0 = p
+	return a
+}
+
+// Search re starting at p, wrapping around the text.
+func (s *Sam) search(re *sre.ReProg, p, sign int) []sre.Range {
+	n := s.t.Len()
+	rg := re.Exec(s.t, p, n)
+	if len(rg) == 0 {
+		if sign >= 0 {
+			rg = re.Exec(s.t, 0, n)
+		} else {
+			rg = re.Exec(s.t, n, n)
+		}
+	}
+	return rg
+}
+
+func (s *Sam) nextmatch(re *sre.ReProg, p, sign int) sre.Range {
+	n := s.t.Len()
+	rg := s.search(re, p, sign)
+	if len(rg) == 0 {
+		panic(errSearch)
+	}
+	m := rg[0]
+	if m.P0 == m.P1 && (sign >= 0 && m.P0 == p || sign < 0 && m.P1 == p) {
+		if sign >= 0 {
+			if p++; p > n {
+				p = 0
+			}
+		} else if p--; p < 0 {
+			p = n
+		}
+		if rg = s.search(re, p, sign); len(rg) == 0 {
+			panic(errSearch)
+		}
+		m = rg[0]
+	}
+	return m
+}
@@ -0,0 +1,329 @@
+package sam
+
+import (
+	"clive/sre"
+	"fmt"
+	"runtime"
+	"strconv"
+	"unicode"
+)
+
+// address
+struct addr {
+	op       rune // '#', 'l', '/', '?', '$', '.', '+', '-', ',', ';'
+	n        int
+	fre, bre *sre.ReProg // forward and backward regexps for '/' and '?'
+	left     *addr       // for ',' and ';'
+	next     *addr       // next address, or right one for ',' and ';'
+}
+
+// command
+struct cmd {
+	addr *addr
+	op   rune        // 0 if just an address
+	re   *sre.ReProg // for x, y, g, v, s
+	text string      // for a, i, c, and the s replacement
+	glob bool        // for s
+	sub  []*cmd      // for loops and blocks
+}
+
+// A compiled list of commands.
+struct Prog {
+	cmds []*cmd
+}
+
+struct parser {
+	src []rune
+	p   int
+}
+
+func (ps *parser) peek() rune {
+	if ps.p >= len(ps.src) {
+		return 0
+	}
+	return ps.src[ps.p]
+}
+
+func (ps *parser) get() rune {
+	c := ps.peek()
+	if c != 0 {
+		ps.p++
+	}
+	return c
+}
+
+func (ps *parser) errorf(f string, args ...face{}) {
+	panic(fmt.Errorf("sam: "+f, args...))
+}
+
+func (ps *parser) blanks() {
+	for c := ps.peek(); c == ' ' || c == '\t'; c = ps.peek() {
+		ps.p++
+	}
+}
+
+// skip blanks and command separators
+func (ps *parser) seps() {
+	for c := ps.peek(); unicode.IsSpace(c) || c == ';'; c = ps.peek() {
+		ps.p++
+	}
+}
+
+/*
+	Compile src as a list of sam commands,
+	separated by new lines or ';'.
+*/
+func Compile(src string) (prg *Prog, err error) {
+	ps := &parser{src: []rune(src)}
+	defer func() {
+		if x := recover(); x != nil {
+			e, ok := x.(error)
+			if _, isrt := x.(runtime.Error); !ok || isrt {
+				panic(x)
+			}
+			prg, err = nil, e
+		}
+	}()
+	prg = &Prog{cmds: ps.cmds()}
+	if ps.peek() != 0 {
+		ps.errorf("unexpected '%c'", ps.peek())
+	}
+	return prg, nil
+}
+
+func (ps *parser) cmds() []*cmd {
+	var cs []*cmd
+	for {
+		ps.seps()
+		if c := ps.peek(); c == 0 || c == '}' {
+			return cs
+		}
+		cs = append(cs, ps.cmd())
+	}
+}
+
+func (ps *parser) cmd() *cmd {
+	c := &cmd{addr: ps.addr()}
+	ps.blanks()
+	switch c.op = ps.peek(); c.op {
+	case 0, '\n', ';', '}':
+		c.op = 0
+		if c.addr == nil {
+			ps.errorf("missing command")
+		}
+		return c
+	}
+	ps.get()
+	switch c.op {
+	case 'p', '=', 'd':
+	case 'a', 'i', 'c':
+		c.text = ps.text(ps.delim(true))
+	case 's':
+		d := ps.delim(true)
+		c.re = ps.regexp(d, sre.Fwd)
+		c.text = ps.rhs(d)
+		if ps.peek() == 'g' {
+			ps.get()
+			c.glob = true
+		}
+	case 'x', 'y', 'g', 'v':
+		if d := ps.delim(c.op == 'g' || c.op == 'v'); d != 0 {
+			c.re = ps.regexp(d, sre.Fwd)
+		} else {
+			c.re = ps.compile(`.*\n`, sre.Fwd)
+		}
+		ps.blanks()
+		switch ps.peek() {
+		case 0, '\n', ';', '}':
+			c.sub = []*cmd{&cmd{op: 'p'}}
+		default:
+			c.sub = []*cmd{ps.cmd()}
+		}
+	case '{':
+		c.sub = ps.cmds()
+		if ps.get() != '}' {
+			ps.errorf("missing '}'")
+		}
+	default:
+		ps.errorf("unknown command '%c'", c.op)
+	}
+	return c
+}
+
+// Return the delimiter for a regexp or text, or 0 if there's none
+// and it's not needed.
+func (ps *parser) delim(needed bool) rune {
+	ps.blanks()
+	c := ps.peek()
+	if c == 0 || c == '\n' || c == ';' || c == '{' || c == '}' || c == '\\' ||
+		unicode.IsLetter(c) || unicode.IsDigit(c) || unicode.IsSpace(c) {
+		if needed {
+			ps.errorf("missing delimiter")
+		}
+		return 0
+	}
+	return ps.get()
+}
+
+// Scan up to the delim (or the end of line) and return the text,
+// \d is d in the text.
+func (ps *parser) scan(d rune) string {
+	var rs []rune
+	for {
+		c := ps.peek()
+		if c == 0 || c == '\n' {
+			return string(rs)
+		}
+		ps.get()
+		if c == d {
+			return string(rs)
+		}
+		if c == '\\' && ps.peek() == d {
+			c = ps.get()
+		} else if c == '\\' && ps.peek() != 0 {
+			rs = append(rs, c)
+			c = ps.get()
+		}
+		rs = append(rs, c)
+	}
+}
+
+// Scan a text argument, \n is a new line, \t a tab, and \\ is \.
+func (ps *parser) text(d rune) string {
+	var rs []rune
+	src := []rune(ps.scan(d))
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\\' && i < len(src)-1 {
+			switch src[i+1] {
+			case 'n':
+				rs = append(rs, '\n')
+				i++
+				continue
+			case 't':
+				rs = append(rs, '\t')
+				i++
+				continue
+			case '\\':
+				i++
+			}
+		}
+		rs = append(rs, src[i])
+	}
+	return string(rs)
+}
+
+/*
+	Scan the replacement for s, \n is a new line, & is the
+	text matched and \& is &.
+	Groups are left as \0...\9 and \<name> for sre.Matched.Repl.
+*/
+func (ps *parser) rhs(d rune) string {
+	var rs []rune
+	src := []rune(ps.scan(d))
+	for i := 0; i < len(src); i++ {
+		switch {
+		case src[i] == '&':
+			rs = append(rs, '\\', '0')
+		case src[i] == '\\' && i < len(src)-1:
+			i++
+			switch src[i] {
+			case 'n':
+				rs = append(rs, '\n')
+			case '&':
+				rs = append(rs, '&')
+			default:
+				rs = append(rs, '\\', src[i])
+			}
+		default:
+			rs = append(rs, src[i])
+		}
+	}
+	return string(rs)
+}
+
+func (ps *parser) compile(re string, dir sre.Dir) *sre.ReProg {
+	prg, err := sre.CompileStr(re, dir)
+	if err != nil {
+		ps.errorf("regexp: %s", err)
+	}
+	return prg
+}
+
+func (ps *parser) regexp(d rune, dir sre.Dir) *sre.ReProg {
+	re := ps.scan(d)
+	if re == "" {
+		ps.errorf("empty regexp")
+	}
+	return ps.compile(re, dir)
+}
+
+func (ps *parser) number() int {
+	p0 := ps.p
+	for unicode.IsDigit(ps.peek()) {
+		ps.p++
+	}
+	n, err := strconv.Atoi(string(ps.src[p0:ps.p]))
+	if err != nil {
+		ps.errorf("bad number")
+	}
+	return n
+}
+
+/*
+	A simple address, perhaps followed by more simple addresses
+	to be applied in sequence.
+	A missing + between addresses is added.
+*/
+func (ps *parser) simple() *addr {
+	ps.blanks()
+	a := &addr{}
+	switch c := ps.peek(); {
+	case c == '#':
+		ps.get()
+		if !unicode.IsDigit(ps.peek()) {
+			ps.errorf("bad address")
+		}
+		a.op, a.n = '#', ps.number()
+	case unicode.IsDigit(c):
+		a.op, a.n = 'l', ps.number()
+	case c == '/' || c == '?':
+		a.op = ps.get()
+		re := ps.scan(a.op)
+		if re == "" {
+			ps.errorf("empty regexp")
+		}
+		a.fre = ps.compile(re, sre.Fwd)
+		a.bre = ps.compile(re, sre.Bck)
+	case c == '$' || c == '.' || c == '+' || c == '-':
+		a.op = ps.get()
+	default:
+		return nil
+	}
+	if a.next = ps.simple(); a.next != nil {
+		switch a.next.op {
+		case '.', '$':
+			ps.errorf("bad address")
+		case 'l', '#', '/', '?':
+			if a.op != '+' && a.op != '-' {
+				a.next = &addr{op: '+', next: a.next}
+			}
+		}
+	}
+	return a
+}
+
+// A compound address: simple [(','|';') compound]
+func (ps *parser) addr() *addr {
+	left := ps.simple()
+	ps.blanks()
+	c := ps.peek()
+	if c != ',' && c != ';' {
+		return left
+	}
+	ps.get()
+	a := &addr{op: c, left: left, next: ps.addr()}
+	if n := a.next; n != nil && (n.op == ',' || n.op == ';') && n.left == nil {
+		ps.errorf("bad address")
+	}
+	return a
+}
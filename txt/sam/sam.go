@@ -0,0 +1,323 @@
+/*
+	Sam's command language on txt.Interface texts.
+
+	Commands are separated by new lines or ';' and each one
+	may be preceded by an address.
+	Addresses are those of sam:
+
+		#n	the empty string after rune n
+		n	line n
+		/re/	the next match of re (wrapping around)
+		?re?	the previous match of re (wrapping around)
+		$	the empty string at the end of text
+		.	dot
+		a1+a2	a2 evaluated forward from a1 (+ alone is +1)
+		a1-a2	a2 evaluated backward from a1 (- alone is -1)
+		a1,a2	from the start of a1 to the end of a2
+		a1;a2	like a1,a2, but a2 is evaluated with dot set to a1
+
+	A missing left address in , or ; is 0 and a missing
+	right one is $. Juxtaposed addresses have an implicit +.
+
+	Commands are:
+
+		a/text/	append text after dot
+		i/text/	insert text before dot
+		c/text/	change dot to text
+		d	delete dot
+		s/re/repl/[g]	substitute (& is the match, \0 to \9 and \<name>
+			its groups)
+		p	print dot
+		=	print the address of dot
+		x/re/ cmd	run cmd with dot set to each match of re in dot
+		y/re/ cmd	like x, but for the text between matches
+		g/re/ cmd	run cmd if dot matches re
+		v/re/ cmd	run cmd if dot does not match re
+		{ cmds }	run each cmd with dot set to the block's dot
+
+	In texts, \n is a new line, \t a tab, and \\ is \.
+	The regexp for x defaults to .*\n and the command for loops
+	defaults to p.
+
+	As in sam, changes are made in the text only after all the
+	commands have run, and addresses and loops refer to the text
+	as it was before changes. Changes must not overlap.
+*/
+package sam
+
+import (
+	"clive/sre"
+	"clive/txt"
+	"clive/zx"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+)
+
+// A change of text[p0:p1] to text
+struct edit {
+	p0, p1 int
+	text   []rune
+}
+
+type byPos []edit
+
+func (b byPos) Len() int      { return len(b) }
+func (b byPos) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byPos) Less(i, j int) bool {
+	return b[i].p0 < b[j].p0 || b[i].p0 == b[j].p0 && b[i].p1 < b[j].p1
+}
+
+/*
+	A text edited using sam commands.
+*/
+struct Sam {
+	Dot  sre.Range // dot (selection) in text
+	Out  io.Writer // where p and = write, if not nil
+	Name string    // name reported by =
+
+	t     txt.Interface
+	edits []edit
+	nest  int
+}
+
+var errSeq = errors.New("sam: changes not in sequence")
+
+// Prepare to edit the text with sam commands, with an empty dot at 0.
+func New(t txt.Interface) *Sam {
+	return &Sam{t: t}
+}
+
+// Compile and execute the commands in src.
+func (s *Sam) Run(src string) error {
+	p, err := Compile(src)
+	if err != nil {
+		return err
+	}
+	return s.Exec(p)
+}
+
+/*
+	Execute the commands in p and then make the changes they ask for.
+	Dot is left selecting the changes made, if any, or as set by the
+	last command otherwise.
+*/
+func (s *Sam) Exec(p *Prog) (err error) {
+	s.edits, s.nest = nil, 0
+	defer func() {
+		if x := recover(); x != nil {
+			e, ok := x.(error)
+			if _, isrt := x.(runtime.Error); !ok || isrt {
+				panic(x)
+			}
+			s.edits = nil
+			err = e
+		}
+	}()
+	for _, c := range p.cmds {
+		s.exec(c)
+	}
+	return s.apply()
+}
+
+// Return the text in rg
+func (s *Sam) get(rg sre.Range) []rune {
+	if rg.P1 <= rg.P0 {
+		return nil
+	}
+	var rs []rune
+	for r := range s.t.Get(rg.P0, rg.P1-rg.P0) {
+		rs = append(rs, r...)
+	}
+	return rs
+}
+
+func (s *Sam) printf(f string, args ...face{}) {
+	if s.Out == nil {
+		return
+	}
+	if _, err := fmt.Fprintf(s.Out, f, args...); err != nil {
+		panic(err)
+	}
+}
+
+// Return the line range for rg, as txt.Text.LinesAt does.
+func (s *Sam) lines(rg sre.Range) (int, int) {
+	ln0, ln1 := 1, 1
+	for p := 0; p < rg.P1; p++ {
+		if s.t.Getc(p) == '\n' {
+			if p < rg.P0 {
+				ln0++
+			}
+			ln1++
+		}
+	}
+	if ln1 > ln0 && rg.P1 > 0 && s.t.Getc(rg.P1-1) == '\n' {
+		ln1--
+	}
+	return ln0, ln1
+}
+
+func (s *Sam) change(p0, p1 int, text string) {
+	s.edits = append(s.edits, edit{p0, p1, []rune(text)})
+}
+
+func (s *Sam) exec(c *cmd) {
+	a := s.Dot
+	if c.addr != nil {
+		a = s.address(c.addr, s.Dot, 0)
+	}
+	switch c.op {
+	case 0:
+	case 'p':
+		s.printf("%s", string(s.get(a)))
+	case '=':
+		ln0, ln1 := s.lines(a)
+		addr := zx.Addr{Name: s.Name, Ln0: ln0, Ln1: ln1, P0: a.P0, P1: a.P1}
+		s.printf("%s\n", addr)
+	case 'a':
+		s.change(a.P1, a.P1, c.text)
+	case 'i':
+		s.change(a.P0, a.P0, c.text)
+	case 'c':
+		s.change(a.P0, a.P1, c.text)
+	case 'd':
+		s.change(a.P0, a.P1, "")
+	case 's':
+		s.subst(c, a)
+	case 'x', 'y':
+		s.loop(c, a)
+	case 'g', 'v':
+		if m := c.re.Exec(s.t, a.P0, a.P1); len(m) > 0 != (c.op == 'v') {
+			s.Dot = a
+			for _, sc := range c.sub {
+				s.exec(sc)
+			}
+		}
+		return
+	case '{':
+		for _, sc := range c.sub {
+			s.Dot = a
+			s.exec(sc)
+		}
+		return
+	}
+	s.Dot = a
+}
+
+func (s *Sam) execs(cs []*cmd) {
+	s.nest++
+	defer func() { s.nest-- }()
+	for _, c := range cs {
+		s.exec(c)
+	}
+}
+
+func (s *Sam) subst(c *cmd, a sre.Range) {
+	op := -1
+	did := false
+	for p := a.P0; p <= a.P1; {
+		m := c.re.FindText(s.t, p, a.P1)
+		if m == nil {
+			break
+		}
+		r := m.Rg[0]
+		if r.P0 == r.P1 {
+			if r.P0 == op {
+				p++
+				continue
+			}
+			p = r.P1 + 1
+		} else {
+			p = r.P1
+		}
+		op = r.P1
+		s.change(r.P0, r.P1, m.Repl(c.text))
+		did = true
+		if !c.glob {
+			break
+		}
+	}
+	if !did && s.nest == 0 {
+		panic(errors.New("sam: no substitution"))
+	}
+}
+
+// x and y loops
+func (s *Sam) loop(c *cmd, a sre.Range) {
+	isx := c.op == 'x'
+	op := a.P0
+	if isx {
+		op = -1
+	}
+	for p := a.P0; p <= a.P1; {
+		rg := c.re.Exec(s.t, p, a.P1)
+		if len(rg) == 0 {
+			if isx || op > a.P1 {
+				break
+			}
+			s.Dot = sre.Range{P0: op, P1: a.P1}
+			p = a.P1 + 1
+		} else {
+			r := rg[0]
+			if r.P0 == r.P1 {
+				if r.P0 == op {
+					p++
+					continue
+				}
+				p = r.P1 + 1
+			} else {
+				p = r.P1
+			}
+			if isx {
+				s.Dot = r
+			} else {
+				s.Dot = sre.Range{P0: op, P1: r.P0}
+			}
+			op = r.P1
+		}
+		s.execs(c.sub)
+	}
+}
+
+// Make the changes, from the last one to the first one.
+func (s *Sam) apply() error {
+	es := s.edits
+	s.edits = nil
+	if len(es) == 0 {
+		return nil
+	}
+	sort.Stable(byPos(es))
+	delta := 0
+	for i, e := range es {
+		if i > 0 && e.p0 < es[i-1].p1 {
+			return errSeq
+		}
+		delta += len(e.text) - (e.p1 - e.p0)
+	}
+	ed, _ := s.t.(txt.Edition)
+	contd := false
+	for i := len(es) - 1; i >= 0; i-- {
+		e := es[i]
+		if e.p1 > e.p0 {
+			if ed != nil && contd {
+				ed.ContdEdit()
+			}
+			s.t.Del(e.p0, e.p1-e.p0)
+			contd = true
+		}
+		if len(e.text) > 0 {
+			if ed != nil && contd {
+				ed.ContdEdit()
+			}
+			if err := s.t.Ins(e.text, e.p0); err != nil {
+				return err
+			}
+			contd = true
+		}
+	}
+	s.Dot = sre.Range{P0: es[0].p0, P1: es[len(es)-1].p1 + delta}
+	return nil
+}
@@ -0,0 +1,96 @@
+package sam
+
+import (
+	"bytes"
+	"clive/txt"
+	"testing"
+)
+
+struct samTest {
+	cmds  string
+	out   string // text after the commands
+	pout  string // printed output
+	fails bool
+}
+
+const samTxt = `one fish
+two fish
+red fish
+blue fish
+`
+
+var samTests = []samTest{
+	{cmds: `2p`, out: samTxt, pout: "two fish\n"},
+	{cmds: `2,3p`, out: samTxt, pout: "two fish\nred fish\n"},
+	{cmds: `$-1p`, out: samTxt, pout: "blue fish\n"},
+	{cmds: `/red/p`, out: samTxt, pout: "red"},
+	{cmds: `/red/+p`, out: samTxt, pout: "blue fish\n"},
+	{cmds: `$?two?p`, out: samTxt, pout: "two"},
+	{cmds: `#4,#8p`, out: samTxt, pout: "fish"},
+	{cmds: `/two/;/fish/p`, out: samTxt, pout: "two fish"},
+	{cmds: `,x/fish/p`, out: samTxt, pout: "fishfishfishfish"},
+	{cmds: `3=`, out: samTxt, pout: "x:3:#18,#27\n"},
+	{cmds: `5p`, out: samTxt},
+	{cmds: `6p`, out: samTxt, fails: true},
+	{cmds: `/cat/p`, out: samTxt, fails: true},
+	{cmds: `2d`, out: "one fish\nred fish\nblue fish\n"},
+	{cmds: `1a/a fish\n/`, out: "one fish\na fish\ntwo fish\nred fish\nblue fish\n"},
+	{cmds: `1i/a fish\n/`, out: "a fish\none fish\ntwo fish\nred fish\nblue fish\n"},
+	{cmds: `/red/c/green/`, out: "one fish\ntwo fish\ngreen fish\nblue fish\n"},
+	{cmds: `,x/fish/c/cat/`, out: "one cat\ntwo cat\nred cat\nblue cat\n"},
+	{cmds: `,x g/o/ d`, out: "red fish\nblue fish\n"},
+	{cmds: `,x v/o/ d`, out: "one fish\ntwo fish\n"},
+	{cmds: `,y/fish/c/-/`, out: "-fish-fish-fish-fish-"},
+	{cmds: `,s/fish/cat/`, out: "one cat\ntwo fish\nred fish\nblue fish\n"},
+	{cmds: `,s/(\w+) fish/& \1/g`,
+		out: "one fish one\ntwo fish two\nred fish red\nblue fish blue\n"},
+	{cmds: `,s/(?P<w>\w+) fish/\<w>\&/g`, out: "one&\ntwo&\nred&\nblue&\n"},
+	{cmds: `,s/cat/dog/`, out: samTxt, fails: true},
+	{cmds: `,x/.*\n/ s/cat/dog/`, out: samTxt},
+	{cmds: `,x/fish/ {i/[/; a/]/}`, out: "one [fish]\ntwo [fish]\nred [fish]\nblue [fish]\n"},
+	{cmds: "1,2x/\\w+/ {\n\tg/fish/ c/cat/\n\tv/fish/ p\n}",
+		out: "one cat\ntwo cat\nred fish\nblue fish\n", pout: "onetwo"},
+	{cmds: `,x/(one|two)/ x/o/ c/0/`, out: "0ne fish\ntw0 fish\nred fish\nblue fish\n"},
+	{cmds: `1d; 1,2c/x/`, out: samTxt, fails: true},
+	{cmds: `0a/zero\n/`, out: "zero\n" + samTxt},
+	{cmds: `$a/five fish\n/`, out: samTxt + "five fish\n"},
+	{cmds: `,x`, out: samTxt, pout: samTxt},
+	{cmds: `2k`, out: samTxt, fails: true},
+}
+
+func TestSam(t *testing.T) {
+	for i, st := range samTests {
+		tx := txt.NewEditing([]rune(samTxt))
+		s := New(tx)
+		var b bytes.Buffer
+		s.Out = &b
+		s.Name = "x"
+		err := s.Run(st.cmds)
+		t.Logf("%d: %q: err %v", i, st.cmds, err)
+		if (err != nil) != st.fails {
+			t.Fatalf("%d: %q: err %v", i, st.cmds, err)
+		}
+		if out := tx.String(); out != st.out {
+			t.Fatalf("%d: %q: text is %q", i, st.cmds, out)
+		}
+		if pout := b.String(); pout != st.pout {
+			t.Fatalf("%d: %q: printed %q", i, st.cmds, pout)
+		}
+	}
+}
+
+func TestSamUndo(t *testing.T) {
+	tx := txt.NewEditing([]rune(samTxt))
+	s := New(tx)
+	if err := s.Run(`,x/fish/c/cat/`); err != nil {
+		t.Fatal(err)
+	}
+	if s.Dot.P0 != 4 || s.Dot.P1 != tx.Len()-1 {
+		t.Fatalf("bad dot %v", s.Dot)
+	}
+	for e := tx.Undo(); e != nil && e.Contd; e = tx.Undo() {
+	}
+	if out := tx.String(); out != samTxt {
+		t.Fatalf("undo: text is %q", out)
+	}
+}
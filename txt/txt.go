@@ -73,6 +73,7 @@ interface Edition {
 */
 struct Text {
 	data   [][]rune
+	rope   *rope // if not nil, the text is kept here and not in data
 	edits  []*Edit
 	nedits int // edits applied in edits
 	sz     int
@@ -190,6 +191,70 @@ func New(txt []rune) *Text {
 	return t
 }
 
+/*
+	Like NewEditing, but keeps the text in a rope,
+	which is better for large texts.
+*/
+func NewEditingRope(txt []rune) *Text {
+	t := NewEditing(nil)
+	t.SetRope(true)
+	if len(txt) > 0 {
+		t.Ins(txt, 0)
+	}
+	return t
+}
+
+/*
+	Like New, but keeps the text in a rope,
+	which is better for large texts.
+*/
+func NewRope(txt []rune) *Text {
+	t := New(nil)
+	t.SetRope(true)
+	if len(txt) > 0 {
+		t.Ins(txt, 0)
+	}
+	return t
+}
+
+/*
+	Keep the text in a rope (or not), moving the text
+	if it's kept elsewhere.
+	Edits and marks are preserved.
+*/
+func (t *Text) SetRope(on bool) {
+	t.Lock()
+	defer t.Unlock()
+	if on == (t.rope != nil) {
+		return
+	}
+	t.seek.off = -2
+	if on {
+		var rs []rune
+		for _, d := range t.data {
+			rs = append(rs, d...)
+		}
+		t.rope = newRope(rs)
+		t.data = make([][]rune, 0, 128)
+		return
+	}
+	rs := t.rope.root.runes(nil)
+	t.rope = nil
+	t.data = make([][]rune, 0, 128)
+	if len(rs) > 0 {
+		t.data = append(t.data, rs)
+	}
+}
+
+/*
+	Report if the text is kept in a rope.
+*/
+func (t *Text) IsRope() bool {
+	t.Lock()
+	defer t.Unlock()
+	return t.rope != nil
+}
+
 /*
 	Discard all the edits (drop undo/redo entries).
 */
@@ -296,6 +361,11 @@ func (t *Text) ins(data []rune, off int) error {
 	if off > t.sz {
 		return errors.New("text can't have holes")
 	}
+	if t.rope != nil {
+		t.rope.ins(data, off)
+		t.sz += len(data)
+		return nil
+	}
 	if off == t.sz {
 		if len(d) > 0 {
 			i := len(d) - 1
@@ -343,6 +413,11 @@ func (t *Text) del(off int, n int) []rune {
 	if off >= t.sz {
 		return b
 	}
+	if t.rope != nil {
+		b = t.rope.del(off, n)
+		t.sz -= len(b)
+		return b
+	}
 	var i int
 	for i = 0; i < len(d); i++ {
 		if off < len(d[i]) {
@@ -568,6 +643,13 @@ func (t *Text) Get(off int, n int) <-chan []rune {
 			c <- []rune{}
 			return
 		}
+		if t.rope != nil {
+			t.rope.get(off, n, func(rs []rune) bool {
+				ok := c <- rs
+				return ok
+			})
+			return
+		}
 		var i int
 		for i = 0; i < len(d); i++ {
 			if off < len(d[i]) {
@@ -597,6 +679,9 @@ func (t *Text) Get(off int, n int) <-chan []rune {
 func (t *Text) Getc(off int) rune {
 	t.Lock()
 	defer t.Unlock()
+	if t.rope != nil {
+		return t.rope.getc(off)
+	}
 	d := t.data
 	switch off {
 	case t.seek.off:
@@ -671,6 +756,9 @@ func (t *Text) LinesAt(p0, p1 int) (int, int) {
 	t.Lock()
 	defer t.Unlock()
 	p0, p1 = dot(p0, p1)
+	if t.rope != nil {
+		return t.rope.linesAt(p0, p1)
+	}
 	tot, ln := 0, 1
 	ln0, ln1 := 1, 1
 	wasnl := false
@@ -717,6 +805,9 @@ func (t *Text) LinesOffs(ln0, ln1 int) (int, int) {
 	if ln1 <= 1 {
 		return 0, 0
 	}
+	if t.rope != nil {
+		return t.rope.linesOffs(ln0, ln1)
+	}
 	lnoff, ln := 0, 1
 	off0, off1 := -1, -1
 	tot := 0
@@ -754,12 +845,24 @@ Loop:
 */
 func (t *Text) String() string {
 	var w bytes.Buffer
-	for _, d := range t.data {
+	for _, d := range t.chunks() {
 		w.WriteString(string(d))
 	}
 	return w.String()
 }
 
+// The slices holding the text.
+func (t *Text) chunks() [][]rune {
+	if t.rope == nil {
+		return t.data
+	}
+	var d [][]rune
+	t.rope.leaves(func(rs []rune) {
+		d = append(d, rs)
+	})
+	return d
+}
+
 /*
 	Debug: print the tag followed by the state of text
 */
@@ -779,7 +882,8 @@ func (t *Text) sprint(markstoo bool) string {
 	var w bytes.Buffer
 	fmt.Fprintf(&w, "%d runes\n", t.sz)
 	off := 0
-	for i, d := range t.data {
+	data := t.chunks()
+	for i, d := range data {
 		fmt.Fprintf(&w, "%d[%d]: [%d]'", i, off, len(d))
 		for j := 0; j < len(d); j++ {
 			if markstoo {
@@ -799,7 +903,7 @@ func (t *Text) sprint(markstoo bool) string {
 				fmt.Fprintf(&w, "%c", d[j])
 			}
 			off++
-			if markstoo && j == len(d)-1 && i == len(t.data)-1 {
+			if markstoo && j == len(d)-1 && i == len(data)-1 {
 				for _, p := range t.marks {
 					if p.Off == off {
 						fmt.Fprintf(&w, "<%s>", p.Name)
@@ -17,8 +17,9 @@ struct test {
 }
 
 var (
-	debug  bool
-	printf = dbg.FlagPrintf(&debug)
+	debug   bool
+	printf  = dbg.FlagPrintf(&debug)
+	newText = NewEditing
 
 	tests = []test{
 		test{
@@ -59,7 +60,7 @@ struct pair {
 
 func TestLineAt(t *testing.T) {
 	debug = testing.Verbose()
-	tx := NewEditing([]rune("abc\ndef\n\nghi\n"))
+	tx := newText([]rune("abc\ndef\n\nghi\n"))
 	nbs := [...]int{1, 1, 1, 1, 2, 2, 2, 2, 3, 4, 4, 4, 4, 5}
 	printf("=>\n%s\n", tx.Sprint())
 	for o := 0; o <= tx.Len(); o++ {
@@ -82,7 +83,7 @@ func TestLineAt(t *testing.T) {
 
 func TestLineOff(t *testing.T) {
 	debug = testing.Verbose()
-	tx := NewEditing([]rune("abc\ndef\n\nghi\n"))
+	tx := newText([]rune("abc\ndef\n\nghi\n"))
 	offs := [...]int{0, 0, 4, 8, 9, 13, 13}
 	lns := [...]int{1, 1, 2, 3, 4, 5, 5}
 	printf("=>\n%s\n", tx.Sprint())
@@ -111,7 +112,7 @@ func TestLineOff(t *testing.T) {
 func TestInsDel(t *testing.T) {
 	debug = testing.Verbose()
 
-	tx := NewEditing(nil)
+	tx := newText(nil)
 	for _, tst := range tests {
 		var err error
 		if tst.op == Eins {
@@ -207,7 +208,7 @@ var tests2 = []test{
 func TestMark(t *testing.T) {
 	debug = testing.Verbose()
 
-	tx := NewEditing(nil)
+	tx := newText(nil)
 	for _, tst := range tests {
 		if tst.op != Eins {
 			break
@@ -21,6 +21,7 @@
 		`~~regexp`	idem for attrs path (val == /...) or name (val != /...)
 		`attr≈"regexp"	true if dir[attr] matches "regexp" (not globbing)
 		`≈regexp`	idem for attrs path (val == /...) or name (val != /...)
+				// a leading (?i) in regexp ignores case
 		`attr>value`	true if dir.Int64(attr) >= value
 				// you can use also >=, <, and <=, ≤, ≥
 		`prune`		false, and indicates that the tree can be pruned
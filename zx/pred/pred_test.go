@@ -157,6 +157,9 @@ func TestMatch(t *testing.T) {
 		mTest{`~/*b`, `/a/b`, false, true},
 		mTest{`~/*/b/*/*/b`, `/a/b`, false, false},
 		mTest{`~/*/c/*/*/b`, `/a/b`, false, true},
+		mTest{`name~~"^B$"`, `/a/b`, false, false},
+		mTest{`name~~"(?i)^B$"`, `/a/b`, true, false},
+		mTest{`name≈"(?i)^[A-B]$"`, `/a/b`, true, false},
 	}
 	for _, pr := range preds {
 		p, err := New(pr.pred)
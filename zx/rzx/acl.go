@@ -0,0 +1,138 @@
+package rzx
+
+import (
+	"clive/net/auth"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Access granted to a tree by an ACL
+type Access int
+
+const (
+	NoAccess Access = iota
+	ROAccess
+	RWAccess
+)
+
+struct aclEnt {
+	who string // user or group name, or "*" for everyone
+	acc Access
+}
+
+/*
+	Access control lists for the trees served.
+
+	The textual form has one line per entry, with the tree name,
+	the access granted (ro or rw), and the users or groups granted
+	such access, or "*" for all users:
+
+		# tree	access	users or groups
+		main	rw	nemo sys
+		pub	ro	*
+
+	Empty lines and lines starting with # are ignored.
+	A user is granted the maximum access of the entries that match it, and
+	trees not listed are not available to anyone.
+*/
+struct ACL {
+	trees map[string][]aclEnt
+}
+
+func (a Access) String() string {
+	switch a {
+	case NoAccess:
+		return "none"
+	case ROAccess:
+		return "ro"
+	case RWAccess:
+		return "rw"
+	default:
+		return fmt.Sprintf("Access<%d>", int(a))
+	}
+}
+
+// Parse the textual form of an ACL.
+func ParseACL(txt string) (*ACL, error) {
+	acl := &ACL{trees: map[string][]aclEnt{}}
+	for i, ln := range strings.Split(txt, "\n") {
+		toks := strings.Fields(ln)
+		if len(toks) == 0 || strings.HasPrefix(toks[0], "#") {
+			continue
+		}
+		if len(toks) < 3 {
+			return nil, fmt.Errorf("acl: line %d: too few fields", i+1)
+		}
+		var acc Access
+		switch toks[1] {
+		case "ro":
+			acc = ROAccess
+		case "rw":
+			acc = RWAccess
+		default:
+			return nil, fmt.Errorf("acl: line %d: bad access '%s'", i+1, toks[1])
+		}
+		for _, who := range toks[2:] {
+			acl.trees[toks[0]] = append(acl.trees[toks[0]], aclEnt{who, acc})
+		}
+	}
+	return acl, nil
+}
+
+// Load an ACL from the given file.
+func LoadACL(file string) (*ACL, error) {
+	dat, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParseACL(string(dat))
+}
+
+// Return the access granted to the user for the tree.
+// A nil ACL grants rw access to everyone.
+func (acl *ACL) Access(tree string, ai *auth.Info) Access {
+	if acl == nil {
+		return RWAccess
+	}
+	acc := NoAccess
+	for _, e := range acl.trees[tree] {
+		if e.acc > acc && (e.who == "*" || ai.InGroup(e.who)) {
+			acc = e.acc
+		}
+	}
+	return acc
+}
+
+func (acl *ACL) String() string {
+	if acl == nil {
+		return "none"
+	}
+	ts := []string{}
+	for t := range acl.trees {
+		ts = append(ts, t)
+	}
+	sort.Sort(sort.StringSlice(ts))
+	lns := []string{}
+	for _, t := range ts {
+		for _, e := range acl.trees[t] {
+			lns = append(lns, fmt.Sprintf("%s\t%s\t%s", t, e.acc, e.who))
+		}
+	}
+	return strings.Join(lns, "\n")
+}
+
+// Set the ACL used to grant access to the trees served.
+// It applies to clients authenticated from now on.
+// A nil ACL grants rw access to all trees for every user, which is the default.
+func (s *Server) SetACL(acl *ACL) {
+	s.Lock()
+	defer s.Unlock()
+	s.acl = acl
+}
+
+// Is the named tree read-only for the user of this (per-user) server?
+func (s *Server) readOnly(name string) bool {
+	return s.rdonly || s.sess != nil && s.sess.ro[name]
+}
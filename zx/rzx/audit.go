@@ -0,0 +1,179 @@
+package rzx
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of audit events
+const (
+	AuditAuth      = "auth"      // user authenticated
+	AuditAuthFail  = "authfail"  // auth failed
+	AuditThrottled = "throttled" // conn refused due to previous auth failures
+	AuditMount     = "mount"     // first request for a tree by a client
+	AuditCtl       = "ctl"       // write of a Ctl file
+	AuditGone      = "gone"      // client is gone
+)
+
+// An entry in the audit log, written as a JSON object per line.
+struct AuditEv {
+	Time time.Time `json:"time"`
+	Ev   string    `json:"ev"`
+	Addr string    `json:"addr"`
+	Uid  string    `json:"uid,omitempty"`
+	Tree string    `json:"tree,omitempty"`
+	Path string    `json:"path,omitempty"`
+	Data string    `json:"data,omitempty"`
+	Err  string    `json:"err,omitempty"`
+}
+
+struct audit {
+	sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+// Per source address auth failures
+struct failure {
+	n     int       // consecutive failures
+	last  time.Time // last failure
+	until time.Time // refuse conns until then
+}
+
+struct throttle {
+	sync.Mutex
+	nfails          int           // failures allowed before throttling (0: disabled)
+	delay, maxdelay time.Duration // initial and max back-off
+	addrs           map[string]*failure
+}
+
+var (
+	// Default back-off values for the server after auth failures
+	// (see Server.Throttle).
+	ThrottleFails    = 3
+	ThrottleDelay    = time.Second
+	ThrottleMaxDelay = 5 * time.Minute
+
+	ErrThrottled = errors.New("too many auth failures")
+)
+
+func (a *audit) log(ev *AuditEv) {
+	if a == nil {
+		return
+	}
+	ev.Time = time.Now()
+	a.Lock()
+	defer a.Unlock()
+	if a.w != nil {
+		a.enc.Encode(ev)
+	}
+}
+
+func (a *audit) setOut(w io.Writer) {
+	a.Lock()
+	defer a.Unlock()
+	a.w = w
+	if w != nil {
+		a.enc = json.NewEncoder(w)
+	}
+}
+
+// Source address for a client tag, without the port.
+func srcAddr(tag string) string {
+	if n := strings.LastIndexByte(tag, '!'); n > 0 {
+		return tag[:n]
+	}
+	return tag
+}
+
+// Return how long conns from addr are still refused.
+func (t *throttle) wait(addr string) time.Duration {
+	t.Lock()
+	defer t.Unlock()
+	f := t.addrs[srcAddr(addr)]
+	if t.nfails <= 0 || f == nil {
+		return 0
+	}
+	if d := f.until.Sub(time.Now()); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Record an auth failure from addr and return the back-off in place, if any.
+// The back-off doubles for each failure past the allowed ones.
+func (t *throttle) failed(addr string) time.Duration {
+	t.Lock()
+	defer t.Unlock()
+	if t.nfails <= 0 {
+		return 0
+	}
+	t.gc()
+	addr = srcAddr(addr)
+	f := t.addrs[addr]
+	if f == nil {
+		f = &failure{}
+		t.addrs[addr] = f
+	}
+	f.n++
+	f.last = time.Now()
+	if f.n < t.nfails {
+		return 0
+	}
+	d := t.delay
+	for i := t.nfails; i < f.n && d < t.maxdelay; i++ {
+		d *= 2
+	}
+	if d > t.maxdelay {
+		d = t.maxdelay
+	}
+	f.until = f.last.Add(d)
+	return d
+}
+
+// Forget about failures from addr.
+func (t *throttle) ok(addr string) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.addrs, srcAddr(addr))
+}
+
+// Forget about addresses that did not fail for a while.
+// Called with the lock held.
+func (t *throttle) gc() {
+	now := time.Now()
+	for a, f := range t.addrs {
+		if now.Sub(f.last) > 2*t.maxdelay && now.After(f.until) {
+			delete(t.addrs, a)
+		}
+	}
+}
+
+// Write an audit log to w, with an entry per auth success or failure,
+// tree mount, and Ctl write.
+// A nil w disables the audit log.
+func (s *Server) AuditTo(w io.Writer) {
+	s.audit.setOut(w)
+}
+
+// Refuse conns from a source address for a while after nfails consecutive
+// auth failures from there.
+// The back-off starts with delay and doubles on each further failure,
+// up to maxdelay.
+// A zero nfails disables throttling.
+func (s *Server) Throttle(nfails int, delay, maxdelay time.Duration) {
+	t := s.throttle
+	t.Lock()
+	defer t.Unlock()
+	t.nfails, t.delay, t.maxdelay = nfails, delay, maxdelay
+	if t.delay <= 0 {
+		t.delay = ThrottleDelay
+	}
+	if t.maxdelay < t.delay {
+		t.maxdelay = t.delay
+	}
+}
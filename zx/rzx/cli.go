@@ -20,6 +20,7 @@ struct Fs {
 	Verb       bool
 	addr       string
 	raddr      string // addr used to cache dials
+	aname      string // auth domain
 	tc         *tls.Config
 	ai         *auth.Info
 	trees      map[string]bool
@@ -87,21 +88,32 @@ func splitaddr(addr string) (string, string) {
 // the caller might call Redial() to re-create the FS or
 // Close() to cease its operation.
 func Dial(addr string, tlscfg ...*tls.Config) (*Fs, error) {
+	return DialAuth(addr, "", tlscfg...)
+}
+
+// Like Dial, but authenticates using the keys for the auth domain aname
+// (the default one if aname is "").
+// Dials using different auth domains are considered different dials.
+func DialAuth(addr, aname string, tlscfg ...*tls.Config) (*Fs, error) {
 	var tc *tls.Config
 	if len(tlscfg) > 0 {
 		tc = tlscfg[0]
 	}
 	addr = FillAddr(addr)
-	if fs, ok := dialed(addr); ok {
+	raddr := addr
+	if aname != "" {
+		raddr += "|" + aname
+	}
+	if fs, ok := dialed(raddr); ok {
 		return fs, nil
 	}
-	raddr := addr
 	addr, fsys := splitaddr(addr)
 	fs := &Fs{
 		Flag:    &dbg.Flag{},
 		Flags:   &zx.Flags{},
 		addr:    addr,
 		raddr:   raddr,
+		aname:   aname,
 		tc:      tc,
 		trees:   map[string]bool{},
 		fsys:    fsys,
@@ -140,7 +152,7 @@ func (fs *Fs) Redial() error {
 		return err
 	}
 	call := m.Rpc()
-	ai, err := auth.AtClient(call, "", "zx")
+	ai, err := auth.AtClient(call, fs.aname, "zx")
 	if err != nil {
 		if !strings.Contains(err.Error(), "auth disabled") {
 			m.Close()
@@ -4,6 +4,7 @@
 package rzx
 
 import (
+	"bytes"
 	"clive/ch"
 	"clive/dbg"
 	"clive/net"
@@ -27,16 +28,28 @@ struct clients {
 	set map[string]client
 }
 
+// Per-client data for a Server
+struct session {
+	sync.Mutex
+	addr, uid string
+	mounted   map[string]bool
+	ro        map[string]bool // trees read-only for the user
+}
+
 struct Server {
 	*dbg.Flag
 	*sync.Mutex
-	fs      map[string]zx.Fs // file trees served
-	addr    string           // where served
-	rdonly  bool
-	noauth  bool
-	inc     <-chan *ch.Mux
-	endc    chan bool
-	clients *clients
+	fs       map[string]zx.Fs // file trees served
+	addr     string           // where served
+	rdonly   bool
+	noauth   bool
+	inc      <-chan *ch.Mux
+	endc     chan bool
+	clients  *clients
+	audit    *audit
+	throttle *throttle
+	acl      *ACL
+	sess     *session // nil but for the per-user copy
 	// when we auth a user, we make a new copy of the Server
 	// struct, with local copies of everything that's not a pointer,
 	// and a new ai for the user.
@@ -105,6 +118,13 @@ func newServer(addr string, tc *tls.Config, ro bool) (*Server, error) {
 		rdonly:  ro,
 		fs:      map[string]zx.Fs{},
 		clients: &clients{set: map[string]client{}},
+		audit:   &audit{},
+		throttle: &throttle{
+			nfails:   ThrottleFails,
+			delay:    ThrottleDelay,
+			maxdelay: ThrottleMaxDelay,
+			addrs:    map[string]*failure{},
+		},
 	}
 	s.Tag = addr
 	go s.loop()
@@ -170,8 +190,8 @@ func (s *Server) trees(c ch.Conn, m *Msg, fs zx.Fs) error {
 		ts = append(ts, t)
 	}
 	s.Unlock()
-	for s := range s.fs {
-		if ok := c.Out <- s; !ok {
+	for _, t := range ts {
+		if ok := c.Out <- t; !ok {
 			return cerror(c.Out)
 		}
 	}
@@ -216,7 +236,7 @@ func (s *Server) get(c ch.Conn, m *Msg, fs zx.Fs) error {
 }
 
 func (s *Server) put(c ch.Conn, m *Msg, fs zx.Fs) error {
-	if s.rdonly {
+	if s.readOnly(m.Fsys) {
 		return fmt.Errorf("%s: %s", s.addr, zx.ErrRO)
 	}
 	xfs, ok := fs.(zx.Putter)
@@ -224,6 +244,8 @@ func (s *Server) put(c ch.Conn, m *Msg, fs zx.Fs) error {
 		return zx.ErrBug
 	}
 	ic := make(chan []byte)
+	isctl := m.Path == "/Ctl"
+	var ctl bytes.Buffer
 	if m.D["type"] == "d" {
 		close(ic)
 	} else {
@@ -231,6 +253,9 @@ func (s *Server) put(c ch.Conn, m *Msg, fs zx.Fs) error {
 			for m := range c.In {
 				switch m := m.(type) {
 				case []byte:
+					if isctl && ctl.Len() < 1024 {
+						ctl.Write(m)
+					}
 					ok := ic <- m
 					if !ok {
 						close(c.In, cerror(ic))
@@ -248,7 +273,11 @@ func (s *Server) put(c ch.Conn, m *Msg, fs zx.Fs) error {
 	}
 	rc := xfs.Put(m.Path, m.D, m.Off, ic)
 	rd := <-rc
-	if err := cerror(rc); err != nil {
+	err := cerror(rc)
+	if isctl {
+		s.log(AuditCtl, m.Fsys, m.Path, ctl.String(), err)
+	}
+	if err != nil {
 		return err
 	}
 	s.mkaddr(rd, m.Fsys)
@@ -259,7 +288,7 @@ func (s *Server) put(c ch.Conn, m *Msg, fs zx.Fs) error {
 }
 
 func (s *Server) move(c ch.Conn, m *Msg, fs zx.Fs) error {
-	if s.rdonly {
+	if s.readOnly(m.Fsys) {
 		return fmt.Errorf("%s: %s", s.addr, zx.ErrRO)
 	}
 	xfs, ok := fs.(zx.Mover)
@@ -270,7 +299,7 @@ func (s *Server) move(c ch.Conn, m *Msg, fs zx.Fs) error {
 }
 
 func (s *Server) link(c ch.Conn, m *Msg, fs zx.Fs) error {
-	if s.rdonly {
+	if s.readOnly(m.Fsys) {
 		return fmt.Errorf("%s: %s", s.addr, zx.ErrRO)
 	}
 	xfs, ok := fs.(zx.Linker)
@@ -281,7 +310,7 @@ func (s *Server) link(c ch.Conn, m *Msg, fs zx.Fs) error {
 }
 
 func (s *Server) remove(c ch.Conn, m *Msg, fs zx.Fs) error {
-	if s.rdonly {
+	if s.readOnly(m.Fsys) {
 		return fmt.Errorf("%s: %s", s.addr, zx.ErrRO)
 	}
 	xfs, ok := fs.(zx.Remover)
@@ -334,7 +363,7 @@ func (s *Server) findget(c ch.Conn, m *Msg, fs zx.Fs) error {
 }
 
 func (s *Server) wstat(c ch.Conn, m *Msg, fs zx.Fs) error {
-	if s.rdonly {
+	if s.readOnly(m.Fsys) {
 		return fmt.Errorf("%s: %s", s.addr, zx.ErrRO)
 	}
 	xfs, ok := fs.(zx.Wstater)
@@ -374,6 +403,7 @@ func (s *Server) req(c ch.Conn) {
 			rerr = fmt.Errorf("no fsys '%s'", m.Fsys)
 			break
 		}
+		s.mounted(m.Fsys)
 		switch m.Op {
 		case Tstat:
 			rerr = s.stat(c, m, fs)
@@ -383,6 +413,8 @@ func (s *Server) req(c ch.Conn) {
 			rerr = s.put(c, m, fs)
 		case Tmove:
 			rerr = s.move(c, m, fs)
+		case Tlink:
+			rerr = s.link(c, m, fs)
 		case Tremove, Tremoveall:
 			rerr = s.remove(c, m, fs)
 		case Tfind:
@@ -409,8 +441,16 @@ func (s *Server) authFor(ai *auth.Info) *Server {
 	defer s.Unlock()
 	ns := &Server{}
 	*ns = *s
+	ns.sess = &session{uid: ai.Uid, mounted: map[string]bool{}, ro: map[string]bool{}}
 	ns.fs = map[string]zx.Fs{}
 	for n, fs := range s.fs {
+		switch s.acl.Access(n, ai) {
+		case NoAccess:
+			s.Dprintf("user %s: tree %s: no access\n", ai.Uid, n)
+			continue
+		case ROAccess:
+			ns.sess.ro[n] = true
+		}
 		if afs, ok := fs.(zx.Auther); ok {
 			fs, err := afs.Auth(ai)
 			if err != nil {
@@ -437,6 +477,15 @@ func (s *Server) client(mx *ch.Mux) {
 			dbg.Warn("%s: no auth rpc", s.addr)
 			continue
 		}
+		if d := s.throttle.wait(mx.Tag); d > 0 {
+			dbg.Warn("%s: %s: %s", s.addr, mx.Tag, ErrThrottled)
+			s.audit.log(&AuditEv{Ev: AuditThrottled, Addr: mx.Tag,
+				Err: fmt.Sprintf("%s for %v", ErrThrottled, d)})
+			close(c.In, ErrThrottled)
+			close(c.Out, ErrThrottled)
+			ai = nil
+			break
+		}
 		if s.noauth {
 			ai, err = auth.NoneAtServer(c, "", "zx")
 			if ai != nil && err != nil && err.Error() == "auth disabled" {
@@ -447,6 +496,15 @@ func (s *Server) client(mx *ch.Mux) {
 		}
 		if err != nil {
 			dbg.Warn("%s: %s: %s", s.addr, mx.Tag, err)
+			ev := &AuditEv{Ev: AuditAuthFail, Addr: mx.Tag, Err: err.Error()}
+			if ai != nil {
+				ev.Uid = ai.Uid
+			}
+			s.audit.log(ev)
+			if d := s.throttle.failed(mx.Tag); d > 0 {
+				dbg.Warn("%s: %s: throttled for %v", s.addr, mx.Tag, d)
+			}
+			ai = nil
 			continue
 		}
 		if ai == nil {
@@ -461,12 +519,42 @@ func (s *Server) client(mx *ch.Mux) {
 		return
 	}
 	s.Dprintf("%s auth as %s\n", mx.Tag, ai.Uid)
+	s.throttle.ok(mx.Tag)
+	s.audit.log(&AuditEv{Ev: AuditAuth, Addr: mx.Tag, Uid: ai.Uid})
 	s.clients.add(mx.Tag, ai.Uid)
 	ns := s.authFor(ai)
+	ns.sess.addr = mx.Tag
 	for c := range mx.In {
 		go ns.req(c)
 	}
 	ns.clients.del(mx.Tag)
+	s.audit.log(&AuditEv{Ev: AuditGone, Addr: mx.Tag, Uid: ai.Uid})
+}
+
+// Log an audit event for the user of this (per-user) server.
+func (s *Server) log(ev, tree, path, data string, err error) {
+	e := &AuditEv{Ev: ev, Tree: tree, Path: path, Data: data}
+	if s.sess != nil {
+		e.Addr, e.Uid = s.sess.addr, s.sess.uid
+	}
+	if err != nil {
+		e.Err = err.Error()
+	}
+	s.audit.log(e)
+}
+
+// Note that the user is using the named tree, logging the first time it does so.
+func (s *Server) mounted(name string) {
+	if s.sess == nil {
+		return
+	}
+	s.sess.Lock()
+	old := s.sess.mounted[name]
+	s.sess.mounted[name] = true
+	s.sess.Unlock()
+	if !old {
+		s.log(AuditMount, name, "", "", nil)
+	}
 }
 
 func (s *Server) loop() {
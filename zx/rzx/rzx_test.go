@@ -1,6 +1,7 @@
 package rzx
 
 import (
+	"bytes"
 	"clive/ch"
 	"clive/net"
 	"clive/net/auth"
@@ -8,9 +9,12 @@ import (
 	"clive/zx"
 	"clive/zx/fstest"
 	"clive/zx/zux"
+	"encoding/json"
 	"io"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 struct tb {
@@ -193,3 +197,100 @@ func TestMoves(t *testing.T) {
 func TestAsAFile(t *testing.T) {
 	runTest(t, fstest.AsAFile)
 }
+
+func TestThrottle(t *testing.T) {
+	th := &throttle{nfails: 2, delay: time.Second, maxdelay: 4 * time.Second,
+		addrs: map[string]*failure{}}
+	if d := th.failed("1.2.3.4!333"); d != 0 {
+		t.Fatalf("throttled after 1 failure")
+	}
+	if th.wait("1.2.3.4!334") != 0 {
+		t.Fatalf("throttled before nfails")
+	}
+	ds := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second}
+	for _, xd := range ds {
+		if d := th.failed("1.2.3.4!333"); d != xd {
+			t.Fatalf("back-off %v; expected %v", d, xd)
+		}
+	}
+	if th.wait("1.2.3.4!335") == 0 {
+		t.Fatalf("not throttled")
+	}
+	if th.wait("1.2.3.5!333") != 0 {
+		t.Fatalf("other addr throttled")
+	}
+	th.ok("1.2.3.4!333")
+	if th.wait("1.2.3.4!333") != 0 {
+		t.Fatalf("throttled after ok")
+	}
+}
+
+func TestAudit(t *testing.T) {
+	var buf bytes.Buffer
+	a := &audit{}
+	a.log(&AuditEv{Ev: AuditAuth, Addr: "local!1", Uid: "nemo"})
+	a.setOut(&buf)
+	a.log(&AuditEv{Ev: AuditAuthFail, Addr: "local!2", Uid: "nemo", Err: "auth failed"})
+	a.log(&AuditEv{Ev: AuditCtl, Addr: "local!1", Uid: "nemo", Tree: "main",
+		Path: "/Ctl", Data: "debug on"})
+	lns := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lns) != 2 {
+		t.Fatalf("got %d entries", len(lns))
+	}
+	var ev AuditEv
+	if err := json.Unmarshal([]byte(lns[1]), &ev); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("audit: %s", buf.String())
+	if ev.Ev != AuditCtl || ev.Tree != "main" || ev.Data != "debug on" {
+		t.Fatalf("bad audit entry %v", ev)
+	}
+}
+
+struct aclTest {
+	tree string
+	ai   *auth.Info
+	acc  Access
+}
+
+var (
+	nemo  = &auth.Info{Uid: "nemo", Gids: map[string]bool{}, Ok: true}
+	sys   = &auth.Info{Uid: "elvis", Gids: map[string]bool{"sys": true}, Ok: true}
+	other = &auth.Info{Uid: "other", Gids: map[string]bool{}, Ok: true}
+
+	aclTests = []aclTest{
+		{"main", nemo, RWAccess},
+		{"main", sys, RWAccess},
+		{"main", other, ROAccess},
+		{"priv", nemo, RWAccess},
+		{"priv", sys, NoAccess},
+		{"pub", other, ROAccess},
+		{"none", nemo, NoAccess},
+	}
+)
+
+func TestACL(t *testing.T) {
+	acl, err := ParseACL(`
+		# tree	access	users
+		main	rw	nemo sys
+		main	ro	*
+		priv	rw	nemo
+		pub	ro	*
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("acl:\n%s", acl)
+	for _, tc := range aclTests {
+		if acc := acl.Access(tc.tree, tc.ai); acc != tc.acc {
+			t.Fatalf("%s for %s: got %s; expected %s", tc.tree, tc.ai.Uid, acc, tc.acc)
+		}
+	}
+	if _, err := ParseACL("main rx nemo"); err == nil {
+		t.Fatal("bad access did not fail")
+	}
+	var nilacl *ACL
+	if nilacl.Access("any", other) != RWAccess {
+		t.Fatal("nil acl must grant rw")
+	}
+}